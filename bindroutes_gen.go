@@ -12,7 +12,6 @@ import (
 func bindRoutes(router *mux.Router, env *infra.Environment) {
 	router.NotFoundHandler = http.HandlerFunc(NotFoundHandler)
 	router.MethodNotAllowedHandler = http.HandlerFunc(MethodNotAllowedHandler)
-	router.HandleFunc(`/v1/user/email/{sessid}`, VerifyEmailHandler(env)).Methods(http.MethodGet)
 	router.HandleFunc(`/v1/auth/totp`, AuthByTOTPHandler(env)).Methods(http.MethodPost)
 	router.HandleFunc(`/v1/auth/password`, AuthByPasswordHandler(env)).Methods(http.MethodPost)
 	router.HandleFunc(`/v1/publickey`, GetPublicKeyHandler(env)).Methods(http.MethodGet)
@@ -21,4 +20,42 @@ func bindRoutes(router *mux.Router, env *infra.Environment) {
 	router.HandleFunc(`/v1/user/totp`, TOTPQRCodeHandler(env)).Methods(http.MethodGet)
 	router.HandleFunc(`/v1/user/totp`, VerifyTOTPHandler(env)).Methods(http.MethodPut)
 	router.HandleFunc(`/v1/user/email`, UpdateEmailHandler(env)).Methods(http.MethodPut)
+	router.HandleFunc(`/v1/user/phone`, UpdatePhoneNumberHandler(env)).Methods(http.MethodPut)
+	router.HandleFunc(`/v1/user/phone/verify`, VerifyPhoneNumberHandler(env)).Methods(http.MethodPut)
+	router.HandleFunc(`/v1/auth/sms/code`, SendSMSCodeHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(`/v1/auth/sms`, AuthBySMSHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(`/v1/user/totp/enroll`, EnrollTOTPHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(`/v1/auth/totp/reset`, AuthForTOTPResetHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(`/v1/admin/user/{user_id}/totp/reset`, ResetTOTPHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(`/v1/admin/user/{user_id}/unlock`, UnlockUserHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(`/v1/auth/token`, IssueTokenHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(`/v1/admin/user/{user_id}/roles/{role}`, GrantRoleHandler(env)).Methods(http.MethodPut)
+	router.HandleFunc(`/v1/admin/user/{user_id}/roles/{role}`, RevokeRoleHandler(env)).Methods(http.MethodDelete)
+	router.HandleFunc(`/v1/admin/users`, ListUsersHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(`/v1/admin/user/{user_id}`, GetUserHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(`/v1/admin/user/{user_id}`, DeleteUserHandler(env)).Methods(http.MethodDelete)
+	router.HandleFunc(`/v1/user`, DeleteAccountHandler(env)).Methods(http.MethodDelete)
+	router.HandleFunc(`/v1/admin/user/{user_id}/suspend`, SuspendUserHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(`/v1/admin/user/{user_id}/reactivate`, ReactivateUserHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(`/v1/admin/user/{user_id}/profile`, GetUserProfileHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(`/v1/admin/user/{user_id}/profile`, UpdateUserProfileHandler(env)).Methods(http.MethodPatch)
+	router.HandleFunc(`/v1/user/profile`, GetProfileHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(`/v1/user/profile`, UpdateProfileHandler(env)).Methods(http.MethodPatch)
+	router.HandleFunc(`/v1/admin/group/{group}/members/{user_id}`, AddGroupMemberHandler(env)).Methods(http.MethodPut)
+	router.HandleFunc(`/v1/admin/group/{group}/members/{user_id}`, RemoveGroupMemberHandler(env)).Methods(http.MethodDelete)
+	router.HandleFunc(`/v1/admin/group/{group}/roles/{role}`, GrantGroupRoleHandler(env)).Methods(http.MethodPut)
+	router.HandleFunc(`/v1/admin/group/{group}/roles/{role}`, RevokeGroupRoleHandler(env)).Methods(http.MethodDelete)
+	router.HandleFunc(`/v1/admin/role/{role}/inherits/{inherited_role}`, AddRoleInheritanceHandler(env)).Methods(http.MethodPut)
+	router.HandleFunc(`/v1/admin/role/{role}/inherits/{inherited_role}`, RemoveRoleInheritanceHandler(env)).Methods(http.MethodDelete)
+	router.HandleFunc(`/v1/admin/group/{group}`, GetGroupHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(`/v1/admin/invitation`, CreateInvitationHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(`/v1/admin/invitation/{code}`, RevokeInvitationHandler(env)).Methods(http.MethodDelete)
+	router.HandleFunc(`/v1/admin/registrations`, ListRegistrationsHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(`/v1/admin/registration/{user_id}/approve`, ApproveRegistrationHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(`/v1/admin/registration/{user_id}/reject`, RejectRegistrationHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(`/v1/user/email/revert/{user_id}/{code}`, RevertEmailHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(`/v1/user/email/verify/{user_id}/{token}`, VerifyEmailHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(`/v1/user/email/verification`, GetEmailVerificationHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(`/v1/user/email/verification`, ResendEmailVerificationHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(`/v1/user/resume`, ResumeRegistrationHandler(env)).Methods(http.MethodPost)
 }
@@ -18,6 +18,9 @@ type options struct {
 func main() { os.Exit(exec()) }
 
 func exec() int {
+	if len(os.Args) > 1 && os.Args[1] == "users" {
+		return execUsers(os.Args[2:])
+	}
 	var opts options
 	if _, err := flags.Parse(&opts); err != nil {
 		if fe, ok := err.(*flags.Error); ok && fe.Type == flags.ErrHelp {
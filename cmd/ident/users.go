@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	flags "github.com/jessevdk/go-flags"
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/infra/database"
+)
+
+// usersOptions is options for `ident users` subcommands.
+type usersOptions struct {
+	Storage infra.StorageConfig
+	Redis   infra.RedisConfig
+	Key     infra.KeyConfig
+	UserID  infra.UserIDConfig
+	Email   infra.EmailConfig
+
+	Export exportCommand `command:"export" description:"export users who have completed registration. profiles, roles and groups are not exported"`
+	Import importCommand `command:"import" description:"import users as they have completed registration. profiles, roles and groups are not imported"`
+
+	Reconcile reconcileCommand `command:"reconcile" description:"complete registrations interrupted while being moved from Redis to MySQL"`
+	Rekey     rekeyCommand     `command:"rekey" description:"re-encrypt secrets of all users under the current key-encryption key"`
+	CheckIDs  checkIDsCommand  `command:"check-ids" description:"find user IDs which are not found by themselves under the user ID policy"`
+}
+
+var usersOpts usersOptions
+
+// execUsers runs `ident users` subcommands.
+func execUsers(args []string) int {
+	parser := flags.NewParser(&usersOpts, flags.Default)
+	parser.Name = "ident users"
+	if _, err := parser.ParseArgs(args); err != nil {
+		if fe, ok := err.(*flags.Error); ok && fe.Type == flags.ErrHelp {
+			return 0
+		}
+		log.Print(err)
+		return 1
+	}
+	return 0
+}
+
+type exportCommand struct {
+	Format    string `long:"format" choice:"jsonl" choice:"csv" default:"jsonl"`
+	Output    string `short:"o" long:"output" value-name:"FILE" description:"output file. stdout if not specified"`
+	BatchSize int    `long:"batch-size" default:"500" description:"number of users read in a query"`
+}
+
+// Execute implements flags.Commander.
+// Exported records contain password hashes and plaintext TOTP secrets,
+// so the output must be handled as a secret.
+// Only the fields of record are exported; profiles, roles and group
+// memberships must be migrated separately.
+func (cmd *exportCommand) Execute([]string) error {
+	keyring, err := usersOpts.Key.LoadKeyring()
+	if err != nil {
+		return err
+	}
+	rdb, err := usersOpts.Storage.Open()
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+
+	out := os.Stdout
+	if cmd.Output != "" {
+		if out, err = os.OpenFile(cmd.Output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	w := newRecordWriter(out, cmd.Format)
+	n, err := database.ExportUsers(context.Background(), rdb, keyring, cmd.BatchSize, func(u entity.User) error {
+		return w.Write(newRecord(u))
+	})
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	log.Printf("%d users exported", n)
+	return err
+}
+
+type importCommand struct {
+	Format    string `long:"format" choice:"jsonl" choice:"csv" default:"jsonl"`
+	Input     string `short:"i" long:"input" value-name:"FILE" description:"input file. stdin if not specified"`
+	Conflict  string `long:"conflict" choice:"skip" choice:"overwrite" choice:"fail" default:"fail" description:"how to handle users which already exist"`
+	BatchSize int    `long:"batch-size" default:"500" description:"number of users imported in a transaction"`
+	DryRun    bool   `long:"dry-run" description:"roll back all changes"`
+}
+
+// Execute implements flags.Commander.
+func (cmd *importCommand) Execute([]string) error {
+	keyring, err := usersOpts.Key.LoadKeyring()
+	if err != nil {
+		return err
+	}
+	policy, err := usersOpts.UserID.Policy()
+	if err != nil {
+		return err
+	}
+	emailPolicy, err := usersOpts.Email.Policy()
+	if err != nil {
+		return err
+	}
+	rdb, err := usersOpts.Storage.Open()
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+	kvs, err := redis.Dial("tcp", usersOpts.Redis.Addr)
+	if err != nil {
+		return err
+	}
+	defer kvs.Close()
+
+	in := os.Stdin
+	if cmd.Input != "" {
+		if in, err = os.Open(cmd.Input); err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+	r, err := newRecordReader(in, cmd.Format)
+	if err != nil {
+		return err
+	}
+	var line int
+	next := func() (entity.User, error) {
+		line++
+		rec, err := r.Read()
+		if err != nil {
+			if err != io.EOF {
+				err = fmt.Errorf("record %d: %s", line, err)
+			}
+			return entity.User{}, err
+		}
+		u, err := rec.user()
+		if err != nil {
+			return u, fmt.Errorf("record %d: %s", line, err)
+		}
+		return u, nil
+	}
+	prefix := ""
+	if cmd.DryRun {
+		prefix = "(dry run) "
+	}
+	report := func(r database.ImportResult) {
+		log.Printf("%s%d created, %d updated, %d skipped", prefix, r.Created, r.Updated, r.Skipped)
+	}
+	result, err := database.ImportUsers(context.Background(), rdb, kvs, keyring, next, database.ImportOptions{
+		Conflict:  database.ConflictStrategy(cmd.Conflict),
+		BatchSize: cmd.BatchSize,
+		DryRun:    cmd.DryRun,
+		Progress:  report,
+		Policy:    policy,
+
+		EmailPolicy: emailPolicy,
+	})
+	if err != nil {
+		return err
+	}
+	report(result)
+	return nil
+}
+
+type reconcileCommand struct {
+	Grace  time.Duration `long:"grace" default:"5m" description:"registrations marked longer than this ago are regarded as interrupted"`
+	DryRun bool          `long:"dry-run" description:"only report registrations to be reconciled"`
+}
+
+// Execute implements flags.Commander.
+// This is safe to run periodically, e.g. by cron.
+func (cmd *reconcileCommand) Execute([]string) error {
+	rdb, err := usersOpts.Storage.Open()
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+	kvs, err := redis.Dial("tcp", usersOpts.Redis.Addr)
+	if err != nil {
+		return err
+	}
+	defer kvs.Close()
+
+	result, err := database.ReconcilePromotions(context.Background(), rdb, kvs, cmd.Grace, cmd.DryRun)
+	prefix := ""
+	if cmd.DryRun {
+		prefix = "(dry run) "
+	}
+	log.Printf("%s%d promoted, %d cleaned, %d discarded, %d skipped", prefix, result.Promoted, result.Cleaned, result.Discarded, result.Skipped)
+	return err
+}
+
+type rekeyCommand struct{}
+
+// Execute implements flags.Commander.
+// The key file must contain both old and new key-encryption keys,
+// and the new one should be specified with --kek-id.
+func (cmd *rekeyCommand) Execute([]string) error {
+	if usersOpts.Key.KEKFile == "" {
+		return errors.New("--kek-file is required")
+	}
+	keyring, err := usersOpts.Key.LoadKeyring()
+	if err != nil {
+		return err
+	}
+	rdb, err := usersOpts.Storage.Open()
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+	kvs, err := redis.Dial("tcp", usersOpts.Redis.Addr)
+	if err != nil {
+		return err
+	}
+	defer kvs.Close()
+
+	n, err := database.Rekey(context.Background(), rdb, kvs, keyring)
+	log.Printf("%d users re-encrypted with key %s", n, keyring.CurrentID())
+	return err
+}
+
+type checkIDsCommand struct{}
+
+// Execute implements flags.Commander.
+// This should be run before changing the user ID policy, e.g. enabling
+// case mapping, and fails if any user IDs conflict with the policy.
+func (cmd *checkIDsCommand) Execute([]string) error {
+	policy, err := usersOpts.UserID.Policy()
+	if err != nil {
+		return err
+	}
+	rdb, err := usersOpts.Storage.Open()
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+	kvs, err := redis.Dial("tcp", usersOpts.Redis.Addr)
+	if err != nil {
+		return err
+	}
+	defer kvs.Close()
+
+	conflicts, err := database.CheckUserIDs(context.Background(), rdb, kvs, policy)
+	if err != nil {
+		return err
+	}
+	for _, c := range conflicts {
+		switch {
+		case c.Normalized == "":
+			fmt.Printf("%s: cannot be normalized\n", strings.Join(c.UserIDs, ", "))
+		case len(c.UserIDs) > 1:
+			fmt.Printf("%s: collide as %s\n", strings.Join(c.UserIDs, ", "), c.Normalized)
+		default:
+			fmt.Printf("%s: normalized to %s\n", c.UserIDs[0], c.Normalized)
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%d conflicts with the user ID policy found", len(conflicts))
+	}
+	return nil
+}
+
+// record is a user in export files.
+// Times are formatted in RFC 3339.
+type record struct {
+	UserID            string `json:"user_id"`
+	PasswordHash      string `json:"password_hash"`
+	TOTPSecret        string `json:"totp_secret,omitempty"`
+	TOTPResetRequired bool   `json:"totp_reset_required,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     bool   `json:"email_verified,omitempty"`
+	PhoneNumber       string `json:"phone_number,omitempty"`
+	PhoneVerified     bool   `json:"phone_verified,omitempty"`
+	Status            string `json:"status,omitempty"`
+	StatusReason      string `json:"status_reason,omitempty"`
+	StatusExpiresAt   string `json:"status_expires_at,omitempty"`
+	CreatedAt         string `json:"created_at,omitempty"`
+}
+
+// csvHeader is the header row of CSV files.
+var csvHeader = []string{"user_id", "password_hash", "totp_secret", "totp_reset_required", "email", "email_verified", "phone_number", "phone_verified", "status", "status_reason", "status_expires_at", "created_at"}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func newRecord(u entity.User) record {
+	return record{
+		UserID:            u.ID,
+		PasswordHash:      u.Password,
+		TOTPSecret:        u.TOTPSecret,
+		TOTPResetRequired: u.TOTPResetRequired,
+		Email:             u.Email,
+		EmailVerified:     u.EmailVerified,
+		PhoneNumber:       u.PhoneNumber,
+		PhoneVerified:     u.PhoneVerified,
+		Status:            string(u.Status),
+		StatusReason:      u.StatusReason,
+		StatusExpiresAt:   formatTime(u.StatusExpiresAt),
+		CreatedAt:         formatTime(u.CreatedAt),
+	}
+}
+
+func (rec record) user() (entity.User, error) {
+	u := entity.User{
+		ID:                rec.UserID,
+		Password:          rec.PasswordHash,
+		TOTPSecret:        rec.TOTPSecret,
+		TOTPResetRequired: rec.TOTPResetRequired,
+		Email:             rec.Email,
+		EmailVerified:     rec.EmailVerified,
+		PhoneNumber:       rec.PhoneNumber,
+		PhoneVerified:     rec.PhoneVerified,
+		StatusReason:      rec.StatusReason,
+		TOTPVerified:      rec.TOTPSecret != "",
+	}
+	var err error
+	if rec.Status != "" {
+		if u.Status, err = entity.ParseUserStatus(rec.Status); err != nil {
+			return u, err
+		}
+	}
+	if u.StatusExpiresAt, err = parseTime(rec.StatusExpiresAt); err != nil {
+		return u, err
+	}
+	if u.CreatedAt, err = parseTime(rec.CreatedAt); err != nil {
+		return u, err
+	}
+	return u, nil
+}
+
+func (rec record) csv() []string {
+	return []string{
+		rec.UserID,
+		rec.PasswordHash,
+		rec.TOTPSecret,
+		strconv.FormatBool(rec.TOTPResetRequired),
+		rec.Email,
+		strconv.FormatBool(rec.EmailVerified),
+		rec.PhoneNumber,
+		strconv.FormatBool(rec.PhoneVerified),
+		rec.Status,
+		rec.StatusReason,
+		rec.StatusExpiresAt,
+		rec.CreatedAt,
+	}
+}
+
+type recordWriter interface {
+	Write(record) error
+	Flush() error
+}
+
+func newRecordWriter(w io.Writer, format string) recordWriter {
+	if format == "csv" {
+		return &csvWriter{w: csv.NewWriter(w)}
+	}
+	bw := bufio.NewWriter(w)
+	return &jsonlWriter{w: bw, enc: json.NewEncoder(bw)}
+}
+
+type jsonlWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w *jsonlWriter) Write(rec record) error { return w.enc.Encode(rec) }
+func (w *jsonlWriter) Flush() error           { return w.w.Flush() }
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvWriter) Write(rec record) error {
+	if !w.headerWritten {
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	return w.w.Write(rec.csv())
+}
+
+func (w *csvWriter) Flush() error {
+	if !w.headerWritten {
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type recordReader interface {
+	// Read returns io.EOF if no more records.
+	Read() (record, error)
+}
+
+func newRecordReader(r io.Reader, format string) (recordReader, error) {
+	if format != "csv" {
+		return &jsonlReader{dec: json.NewDecoder(r)}, nil
+	}
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"user_id", "password_hash"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("column %s is required", required)
+		}
+	}
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+type jsonlReader struct {
+	dec *json.Decoder
+}
+
+func (r *jsonlReader) Read() (record, error) {
+	var rec record
+	err := r.dec.Decode(&rec)
+	return rec, err
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func (r *csvReader) Read() (record, error) {
+	var rec record
+	row, err := r.r.Read()
+	if err != nil {
+		return rec, err
+	}
+	get := func(name string) string {
+		if i, ok := r.columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	parseBool := func(name string) (bool, error) {
+		if s := get(name); s != "" {
+			return strconv.ParseBool(s)
+		}
+		return false, nil
+	}
+	rec.UserID = get("user_id")
+	rec.PasswordHash = get("password_hash")
+	rec.TOTPSecret = get("totp_secret")
+	rec.Email = get("email")
+	rec.PhoneNumber = get("phone_number")
+	rec.Status = get("status")
+	rec.StatusReason = get("status_reason")
+	rec.StatusExpiresAt = get("status_expires_at")
+	rec.CreatedAt = get("created_at")
+	if rec.TOTPResetRequired, err = parseBool("totp_reset_required"); err != nil {
+		return rec, err
+	}
+	if rec.EmailVerified, err = parseBool("email_verified"); err != nil {
+		return rec, err
+	}
+	if rec.PhoneVerified, err = parseBool("phone_verified"); err != nil {
+		return rec, err
+	}
+	return rec, nil
+}
@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"sort"
+	"time"
+)
+
+// AuthMethod is an authentication method reference,
+// used as a value of amr claim (RFC 8176).
+type AuthMethod string
+
+// authentication methods
+const (
+	AuthMethodPassword AuthMethod = "pwd"
+	AuthMethodOTP      AuthMethod = "otp"
+	AuthMethodSMS      AuthMethod = "sms"
+
+	// AuthMethodMFA is added to amr claim when
+	// multiple factors have been satisfied.
+	AuthMethodMFA AuthMethod = "mfa"
+)
+
+// authentication context class references
+const (
+	ACRSingleFactor = "1"
+	ACRMultiFactor  = "2"
+)
+
+// AuthContext holds the authentication methods satisfied in a session
+// and when each of them was satisfied.
+type AuthContext map[AuthMethod]time.Time
+
+// AMR returns the authentication methods as a value of amr claim.
+func (ac AuthContext) AMR() []string {
+	amr := make([]string, 0, len(ac)+1)
+	for m := range ac {
+		amr = append(amr, string(m))
+	}
+	sort.Strings(amr)
+	if len(ac) > 1 {
+		amr = append(amr, string(AuthMethodMFA))
+	}
+	return amr
+}
+
+// ACR returns the authentication context class reference.
+func (ac AuthContext) ACR() string {
+	if len(ac) > 1 {
+		return ACRMultiFactor
+	}
+	return ACRSingleFactor
+}
+
+// AuthTime returns the time when the latest authentication occurred.
+func (ac AuthContext) AuthTime() time.Time {
+	var t time.Time
+	for _, at := range ac {
+		if at.After(t) {
+			t = at
+		}
+	}
+	return t
+}
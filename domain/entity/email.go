@@ -0,0 +1,128 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode"
+
+	"github.com/nasa9084/ident/util"
+)
+
+// max length of email addresses (RFC 5321)
+const (
+	MaxEmailLength      = 254
+	maxEmailLocalLength = 64
+)
+
+// ParseEmail parses the email address, which must be an addr-spec
+// (RFC 5322) without display name, and returns the address whose domain
+// is converted to ASCII in lower case (RFC 5890).
+// The local part is returned as is, since it may be case sensitive.
+func ParseEmail(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", errors.New("email address is required")
+	}
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return "", fmt.Errorf("email address is invalid: %s", err)
+	}
+	if addr.Name != "" || strings.ContainsAny(s, "<>") {
+		return "", errors.New("email address must not have display name")
+	}
+	i := strings.LastIndex(addr.Address, "@")
+	local, domain := addr.Address[:i], addr.Address[i+1:]
+	if len(local) > maxEmailLocalLength {
+		return "", errors.New("local part of email address is too long")
+	}
+	if strings.HasPrefix(domain, "[") {
+		return "", errors.New("email address with IP address literal is not allowed")
+	}
+	domain, err = util.ToASCIIDomain(domain)
+	if err != nil {
+		return "", fmt.Errorf("domain of email address is invalid: %s", err)
+	}
+	if !strings.Contains(domain, ".") {
+		return "", errors.New("domain of email address must be fully qualified")
+	}
+	email := quoteLocal(local) + "@" + domain
+	if len(email) > MaxEmailLength {
+		return "", errors.New("email address is too long")
+	}
+	return email, nil
+}
+
+// quoteLocal quotes the local part unquoted by net/mail
+// if it is not a dot-atom (RFC 5322, RFC 6532).
+func quoteLocal(local string) string {
+	if isDotAtom(local) {
+		return local
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(local) + `"`
+}
+
+func isDotAtom(s string) bool {
+	if s == "" || strings.HasPrefix(s, ".") || strings.HasSuffix(s, ".") || strings.Contains(s, "..") {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r > unicode.MaxASCII && unicode.IsPrint(r) && !unicode.IsSpace(r):
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case strings.ContainsRune("!#$%&'*+-/=?^_`{|}~.", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// emailDomain returns the domain of the parsed email address.
+func emailDomain(email string) string {
+	return email[strings.LastIndex(email, "@")+1:]
+}
+
+// EmailPolicy is a policy of email addresses which users can set.
+// Domains in the lists match the domain itself and its subdomains,
+// and must have been converted by util.ToASCIIDomain.
+// The zero value allows any valid address.
+type EmailPolicy struct {
+	// AllowedDomains are the only domains allowed if not empty.
+	AllowedDomains []string
+	DeniedDomains  []string
+	// DisposableDomains are domains of disposable email services.
+	DisposableDomains []string
+}
+
+// Normalize parses the email address with ParseEmail,
+// and returns an error if its domain is not allowed.
+func (p EmailPolicy) Normalize(s string) (string, error) {
+	email, err := ParseEmail(s)
+	if err != nil {
+		return "", err
+	}
+	domain := emailDomain(email)
+	if len(p.AllowedDomains) > 0 && !matchDomain(domain, p.AllowedDomains) {
+		return "", fmt.Errorf("email address in domain %s is not allowed", domain)
+	}
+	if matchDomain(domain, p.DeniedDomains) {
+		return "", fmt.Errorf("email address in domain %s is denied", domain)
+	}
+	if matchDomain(domain, p.DisposableDomains) {
+		return "", errors.New("disposable email address is not allowed")
+	}
+	return email, nil
+}
+
+// matchDomain returns whether the domain is one of the domains
+// or their subdomains.
+func matchDomain(domain string, domains []string) bool {
+	for _, d := range domains {
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultAuthFlow is the authentication flow used if not configured:
+// TOTP or SMS passcode first, then password.
+var DefaultAuthFlow = AuthFlow{
+	{AuthMethodOTP, AuthMethodSMS},
+	{AuthMethodPassword},
+}
+
+// AuthFlow is a policy of authentication, which consists of ordered steps.
+// Each step is satisfied by one of its authentication methods.
+type AuthFlow [][]AuthMethod
+
+// ParseAuthFlow parses authentication flow from a string.
+// Steps are separated by comma and alternative methods in a step
+// are separated by "|", e.g. "otp|sms,pwd".
+func ParseAuthFlow(s string) (AuthFlow, error) {
+	var flow AuthFlow
+	for _, step := range strings.Split(s, ",") {
+		var methods []AuthMethod
+		for _, m := range strings.Split(step, "|") {
+			method := AuthMethod(strings.TrimSpace(m))
+			switch method {
+			case AuthMethodPassword, AuthMethodOTP, AuthMethodSMS:
+			default:
+				return nil, fmt.Errorf("unknown authentication method in flow: %q", method)
+			}
+			methods = append(methods, method)
+		}
+		flow = append(flow, methods)
+	}
+	if len(flow) == 0 {
+		return nil, errors.New("authentication flow is empty")
+	}
+	return flow, nil
+}
+
+// Next returns the methods of the first step which is not satisfied
+// in given authentication context. nil is returned if all steps
+// have been completed.
+func (f AuthFlow) Next(ac AuthContext) []AuthMethod {
+	for _, step := range f {
+		if !ac.satisfies(step) {
+			return step
+		}
+	}
+	return nil
+}
+
+// Completed returns whether all steps have been completed.
+func (f AuthFlow) Completed(ac AuthContext) bool {
+	return f.Next(ac) == nil
+}
+
+// Allows returns whether the method can be used in given context.
+// Only the methods of the next step are allowed while the flow is in
+// progress, and any method in the flow is allowed after completion
+// to re-authenticate.
+func (f AuthFlow) Allows(ac AuthContext, method AuthMethod) bool {
+	if next := f.Next(ac); next != nil {
+		return containsMethod(next, method)
+	}
+	for _, step := range f {
+		if containsMethod(step, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ac AuthContext) satisfies(methods []AuthMethod) bool {
+	for _, m := range methods {
+		if _, ok := ac[m]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsMethod(methods []AuthMethod, method AuthMethod) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
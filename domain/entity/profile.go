@@ -0,0 +1,216 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Profile is a set of profile attributes of a user, keyed by attribute name.
+// Values are stored as string and interpreted by the type of the attribute.
+type Profile map[string]string
+
+// AttributeType is a type of profile attribute.
+type AttributeType string
+
+// profile attribute types
+const (
+	AttributeString   AttributeType = "string"
+	AttributeInt      AttributeType = "int"
+	AttributeBool     AttributeType = "bool"
+	AttributeURL      AttributeType = "url"
+	AttributeLocale   AttributeType = "locale"
+	AttributeTimezone AttributeType = "timezone"
+)
+
+// Visibility decides who can read and write a profile attribute.
+// Admin can always read and write all attributes.
+type Visibility string
+
+// profile attribute visibilities
+const (
+	// VisibilityUser attribute can be read and written by the user.
+	VisibilityUser Visibility = "user"
+	// VisibilityReadOnly attribute can be read by the user.
+	VisibilityReadOnly Visibility = "readonly"
+	// VisibilityAdmin attribute is hidden from the user.
+	VisibilityAdmin Visibility = "admin"
+)
+
+// limits of profile attributes
+const (
+	MaxAttributeNameLength = 64
+	MaxAttributeLength     = 1024
+)
+
+// reservedClaims are claims which cannot be used as profile attribute.
+var reservedClaims = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true, "iat": true, "jti": true,
+	"user_id": true, "amr": true, "acr": true, "auth_time": true, "roles": true, "groups": true,
+}
+
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// ProfileAttribute is a definition of a profile attribute.
+type ProfileAttribute struct {
+	Type       AttributeType
+	Visibility Visibility
+	// MaxLength is the max length of the value in characters.
+	// zero means MaxAttributeLength.
+	MaxLength int
+	// Claim is true if the attribute is included in JWT token.
+	Claim bool
+}
+
+// Validate returns an error if the value is not valid for the attribute.
+func (a ProfileAttribute) Validate(value string) error {
+	maxLength := a.MaxLength
+	if maxLength <= 0 || MaxAttributeLength < maxLength {
+		maxLength = MaxAttributeLength
+	}
+	if utf8.RuneCountInString(value) > maxLength {
+		return fmt.Errorf("length must be less than or equal to %d", maxLength)
+	}
+	switch a.Type {
+	case AttributeString:
+	case AttributeInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return errors.New("must be an integer")
+		}
+	case AttributeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return errors.New("must be true or false")
+		}
+	case AttributeURL:
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("must be an http or https URL")
+		}
+	case AttributeLocale:
+		if !localePattern.MatchString(value) {
+			return errors.New("must be a BCP 47 language tag")
+		}
+	case AttributeTimezone:
+		if _, err := time.LoadLocation(value); err != nil || value == "" || value == "Local" {
+			return errors.New("must be an IANA time zone name")
+		}
+	default:
+		return fmt.Errorf("unknown attribute type: %s", a.Type)
+	}
+	return nil
+}
+
+// value returns the value converted to the type of the attribute.
+func (a ProfileAttribute) value(s string) interface{} {
+	switch a.Type {
+	case AttributeInt:
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case AttributeBool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+	return s
+}
+
+// ProfileSchema is a set of profile attribute definitions,
+// keyed by attribute name.
+type ProfileSchema map[string]ProfileAttribute
+
+// DefaultProfileSchema returns the built-in profile attributes.
+func DefaultProfileSchema() ProfileSchema {
+	return ProfileSchema{
+		"display_name": {Type: AttributeString, Visibility: VisibilityUser, MaxLength: 128},
+		"locale":       {Type: AttributeLocale, Visibility: VisibilityUser, MaxLength: 35},
+		"timezone":     {Type: AttributeTimezone, Visibility: VisibilityUser, MaxLength: 64},
+		"avatar_url":   {Type: AttributeURL, Visibility: VisibilityUser},
+	}
+}
+
+// Check returns an error if the definitions are not valid.
+func (schema ProfileSchema) Check() error {
+	for name, a := range schema {
+		if name == "" || MaxAttributeNameLength < len(name) {
+			return fmt.Errorf("invalid attribute name: %q", name)
+		}
+		if a.Claim && reservedClaims[name] {
+			return fmt.Errorf("attribute %s cannot be a claim: reserved name", name)
+		}
+		switch a.Type {
+		case AttributeString, AttributeInt, AttributeBool, AttributeURL, AttributeLocale, AttributeTimezone:
+		default:
+			return fmt.Errorf("unknown type of attribute %s: %q", name, a.Type)
+		}
+		switch a.Visibility {
+		case VisibilityUser, VisibilityReadOnly, VisibilityAdmin:
+		default:
+			return fmt.Errorf("unknown visibility of attribute %s: %q", name, a.Visibility)
+		}
+	}
+	return nil
+}
+
+// ValidateUpdate returns an error if the update is not allowed.
+// An empty value removes the attribute.
+// If admin is false, only attributes with VisibilityUser can be updated.
+func (schema ProfileSchema) ValidateUpdate(p Profile, admin bool) error {
+	for name, value := range p {
+		a, ok := schema[name]
+		if !ok || (!admin && a.Visibility == VisibilityAdmin) {
+			return fmt.Errorf("unknown attribute: %s", name)
+		}
+		if !admin && a.Visibility != VisibilityUser {
+			return fmt.Errorf("attribute %s is read-only", name)
+		}
+		if value == "" {
+			continue
+		}
+		if err := a.Validate(value); err != nil {
+			return fmt.Errorf("%s %s", name, err)
+		}
+	}
+	return nil
+}
+
+// Visible returns the attributes which can be read.
+// Attributes not defined in the schema are omitted.
+// If admin is false, attributes with VisibilityAdmin are also omitted.
+func (schema ProfileSchema) Visible(p Profile, admin bool) Profile {
+	visible := Profile{}
+	for name, value := range p {
+		a, ok := schema[name]
+		if !ok || (!admin && a.Visibility == VisibilityAdmin) {
+			continue
+		}
+		visible[name] = value
+	}
+	return visible
+}
+
+// HasClaims returns true if any attribute is included in JWT token.
+func (schema ProfileSchema) HasClaims() bool {
+	for _, a := range schema {
+		if a.Claim {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims returns the attributes to be included in JWT token,
+// converted to the type of each attribute.
+func (schema ProfileSchema) Claims(p Profile) map[string]interface{} {
+	claims := map[string]interface{}{}
+	for name, value := range p {
+		if a, ok := schema[name]; ok && a.Claim {
+			claims[name] = a.value(value)
+		}
+	}
+	return claims
+}
@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// RegistrationMode is a policy of who can register.
+type RegistrationMode string
+
+// registration modes
+const (
+	// RegistrationOpen allows anyone to register.
+	RegistrationOpen RegistrationMode = "open"
+	// RegistrationInvite requires an invitation code issued by admin.
+	RegistrationInvite RegistrationMode = "invite"
+	// RegistrationApproval makes completed registrations wait for
+	// approval by admin.
+	RegistrationApproval RegistrationMode = "approval"
+)
+
+// ParseRegistrationMode parses given string as a RegistrationMode.
+// Empty string means RegistrationOpen.
+func ParseRegistrationMode(s string) (RegistrationMode, error) {
+	switch mode := RegistrationMode(s); mode {
+	case RegistrationOpen, RegistrationInvite, RegistrationApproval:
+		return mode, nil
+	case "":
+		return RegistrationOpen, nil
+	}
+	return "", fmt.Errorf("unknown registration mode: %q", s)
+}
+
+// Invitation is an invitation to register, issued by admin.
+type Invitation struct {
+	Code string
+	// MaxUses is the number of registrations allowed with the code.
+	// Zero means unlimited.
+	MaxUses int
+	Uses    int
+	// ExpiresAt is the time the code expires. Zero means never.
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,81 @@
+package entity
+
+import (
+	"sort"
+	"strings"
+)
+
+// RoleAdmin is the role allowed to use administrative API.
+const RoleAdmin = "admin"
+
+// AudienceSeparator separates the audience and the name of
+// an audience-scoped role or group, e.g. "billing:viewer".
+// Names without the separator are global.
+const AudienceSeparator = ":"
+
+// Group is a set of users which share roles.
+type Group struct {
+	Name    string
+	Roles   []string
+	Members []string
+}
+
+// RoleInheritance maps a role to the roles inherited by the role.
+type RoleInheritance map[string][]string
+
+// Expand returns given roles and all roles inherited from them, sorted.
+func (ri RoleInheritance) Expand(roles []string) []string {
+	seen := map[string]bool{}
+	queue := append([]string{}, roles...)
+	for len(queue) > 0 {
+		role := queue[0]
+		queue = queue[1:]
+		if seen[role] {
+			continue
+		}
+		seen[role] = true
+		queue = append(queue, ri[role]...)
+	}
+	expanded := make([]string, 0, len(seen))
+	for role := range seen {
+		expanded = append(expanded, role)
+	}
+	sort.Strings(expanded)
+	return expanded
+}
+
+// Authorization is the roles and groups of a user.
+type Authorization struct {
+	Roles  []string
+	Groups []string
+}
+
+// HasRole returns true if the user has the role.
+func (a Authorization) HasRole(role string) bool {
+	for _, r := range a.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ForAudience returns the global roles and groups, and the ones scoped to
+// the audience. Roles and groups scoped to other audiences are omitted.
+func (a Authorization) ForAudience(audience string) Authorization {
+	return Authorization{
+		Roles:  filterByAudience(a.Roles, audience),
+		Groups: filterByAudience(a.Groups, audience),
+	}
+}
+
+func filterByAudience(names []string, audience string) []string {
+	filtered := []string{}
+	for _, name := range names {
+		if !strings.Contains(name, AudienceSeparator) ||
+			(audience != "" && strings.HasPrefix(name, audience+AudienceSeparator)) {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,51 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// UserStatus is the status of a user account.
+type UserStatus string
+
+// user statuses
+const (
+	StatusActive UserStatus = "active"
+	// StatusSuspended is set by admin to block the user.
+	StatusSuspended UserStatus = "suspended"
+	// StatusLocked is set by admin to block the user
+	// for security reasons such as compromised credentials.
+	StatusLocked UserStatus = "locked"
+	// StatusPending is the status while the registration
+	// has not been completed.
+	StatusPending UserStatus = "pending"
+)
+
+// ParseUserStatus parses given string as a user status.
+func ParseUserStatus(s string) (UserStatus, error) {
+	switch status := UserStatus(s); status {
+	case StatusActive, StatusSuspended, StatusLocked, StatusPending:
+		return status, nil
+	}
+	return "", fmt.Errorf("unknown user status: %q", s)
+}
+
+// Blocked returns true if the user with the status
+// is not allowed to authenticate.
+func (s UserStatus) Blocked() bool {
+	return s == StatusSuspended || s == StatusLocked
+}
+
+// StatusAt returns the status of the user at given time.
+// Suspension and lock are lifted after StatusExpiresAt if it is set.
+func (u User) StatusAt(t time.Time) UserStatus {
+	switch {
+	case u.Pending:
+		return StatusPending
+	case u.Status == "":
+		return StatusActive
+	case u.Status.Blocked() && !u.StatusExpiresAt.IsZero() && !t.Before(u.StatusExpiresAt):
+		return StatusActive
+	}
+	return u.Status
+}
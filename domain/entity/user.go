@@ -1,5 +1,7 @@
 package entity
 
+import "time"
+
 // User entity object.
 type User struct {
 	ID         string
@@ -7,5 +9,41 @@ type User struct {
 	TOTPSecret string
 	Email      string
 
-	TOTPVerified bool
+	// PendingTOTPSecret is a new TOTP secret being enrolled.
+	// It replaces TOTPSecret after it is verified.
+	PendingTOTPSecret string
+
+	PhoneNumber string
+
+	TOTPVerified  bool
+	PhoneVerified bool
+	// EmailVerified is true if the user has proved the ownership of
+	// the email address. Verified email addresses are unique, and
+	// users can authenticate with them instead of user IDs.
+	EmailVerified bool
+	// PendingEmail is a new email address waiting for verification.
+	// Email is kept until PendingEmail is verified.
+	PendingEmail string
+	// PreviousEmail is the email address replaced by the last change.
+	// The owner of the address can revert the change.
+	PreviousEmail string
+
+	// TOTPResetRequired is true when TOTP has been reset by admin and
+	// the user must enroll a new TOTP device at next login.
+	TOTPResetRequired bool
+
+	// Pending is true while the registration has not been completed.
+	Pending bool
+	// AwaitingApproval is true while the pending registration is
+	// waiting for approval by admin.
+	AwaitingApproval bool
+
+	// Status is the account status set by admin.
+	// StatusReason and StatusExpiresAt are the metadata of the status.
+	// Use StatusAt to get the effective status.
+	Status          UserStatus
+	StatusReason    string
+	StatusExpiresAt time.Time
+
+	CreatedAt time.Time
 }
@@ -0,0 +1,141 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/secure/precis"
+)
+
+// UserIDProfile is a PRECIS profile (RFC 8265) applied to user IDs.
+type UserIDProfile string
+
+// user ID profiles
+const (
+	// UserIDCaseMapped maps user IDs to lower case,
+	// so that "Alice" and "alice" are the same user.
+	UserIDCaseMapped UserIDProfile = "casemapped"
+	// UserIDCasePreserved preserves case of user IDs.
+	UserIDCasePreserved UserIDProfile = "casepreserved"
+	// UserIDRaw does not apply PRECIS. User IDs are only validated.
+	UserIDRaw UserIDProfile = "none"
+)
+
+// MaxUserIDLength is the maximum length of user IDs which can be stored.
+const MaxUserIDLength = 128
+
+// userIDKeySeparator is the separator of Redis keys such as "user:"+ID,
+// which is never allowed in user IDs.
+const userIDKeySeparator = ":"
+
+// UserIDPolicy is a policy to normalize and validate user IDs.
+type UserIDPolicy struct {
+	Profile UserIDProfile
+	// MinLength and MaxLength are the length in characters.
+	MinLength int
+	MaxLength int
+	// ASCIIOnly allows only ASCII letters and digits.
+	ASCIIOnly bool
+	// Symbols are characters allowed in addition to letters and digits.
+	Symbols string
+	// Reserved are user IDs which cannot be registered.
+	Reserved []string
+}
+
+// DefaultUserIDPolicy returns the default user ID policy.
+func DefaultUserIDPolicy() UserIDPolicy {
+	return UserIDPolicy{
+		Profile:   UserIDCaseMapped,
+		MinLength: 1,
+		MaxLength: 64,
+		Symbols:   "._-+",
+		Reserved:  []string{"admin", "administrator", "root", "system", "ident", "support", "me"},
+	}
+}
+
+// Check returns an error if the policy is invalid.
+func (p UserIDPolicy) Check() error {
+	switch p.Profile {
+	case UserIDCaseMapped, UserIDCasePreserved, UserIDRaw:
+	default:
+		return fmt.Errorf("unknown user ID profile: %q", p.Profile)
+	}
+	if p.MinLength < 1 || p.MaxLength < p.MinLength || MaxUserIDLength < p.MaxLength {
+		return fmt.Errorf("user ID length must be between 1 and %d", MaxUserIDLength)
+	}
+	if strings.Contains(p.Symbols, userIDKeySeparator) {
+		return fmt.Errorf("%q cannot be allowed in user IDs", userIDKeySeparator)
+	}
+	return nil
+}
+
+func (p UserIDPolicy) enforce(id string) (string, error) {
+	switch p.Profile {
+	case UserIDCaseMapped:
+		return precis.UsernameCaseMapped.String(id)
+	case UserIDCasePreserved:
+		return precis.UsernameCasePreserved.String(id)
+	}
+	return id, nil
+}
+
+// Normalize returns the user ID normalized by the PRECIS profile.
+// User IDs must be normalized before compared or stored.
+// Normalize does not validate the ID with other rules,
+// so that existing users can be found even if the policy has changed.
+func (p UserIDPolicy) Normalize(id string) (string, error) {
+	if id == "" {
+		return "", errors.New("user ID is required")
+	}
+	normalized, err := p.enforce(id)
+	if err != nil {
+		return "", errors.New("user ID contains disallowed characters")
+	}
+	return normalized, nil
+}
+
+// Validate returns an error if the normalized user ID cannot be registered.
+func (p UserIDPolicy) Validate(id string) error {
+	n := utf8.RuneCountInString(id)
+	if n < p.MinLength || p.MaxLength < n {
+		return fmt.Errorf("length of user ID must be between %d and %d", p.MinLength, p.MaxLength)
+	}
+	for _, r := range id {
+		if !p.allows(r) {
+			return fmt.Errorf("user ID cannot contain %q", r)
+		}
+	}
+	for _, reserved := range p.Reserved {
+		if r, err := p.enforce(reserved); err == nil && strings.EqualFold(id, r) {
+			return fmt.Errorf("user ID %q is reserved", id)
+		}
+	}
+	return nil
+}
+
+// NormalizeNew normalizes and validates the user ID to be registered.
+func (p UserIDPolicy) NormalizeNew(id string) (string, error) {
+	normalized, err := p.Normalize(id)
+	if err != nil {
+		return "", err
+	}
+	if err := p.Validate(normalized); err != nil {
+		return "", err
+	}
+	return normalized, nil
+}
+
+func (p UserIDPolicy) allows(r rune) bool {
+	switch {
+	case string(r) == userIDKeySeparator:
+		return false
+	case strings.ContainsRune(p.Symbols, r):
+		return true
+	case p.ASCIIOnly && r > unicode.MaxASCII:
+		return false
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -9,5 +9,13 @@ func (e Error) Error() string {
 
 // Error constants for this package.
 const (
-	ErrUserExists Error = "given user ID has been used"
+	ErrUserExists  Error = "given user ID has been used"
+	ErrUserDeleted Error = "given user ID has been deleted"
+	ErrEmailExists Error = "given email address has been used by another user"
+
+	ErrGroupNotFound Error = "given group is not found"
+	ErrGroupExists   Error = "given group already exists"
+
+	ErrInvitationInvalid  Error = "invitation code is invalid, expired or used up"
+	ErrInvitationNotFound Error = "given invitation is not found"
 )
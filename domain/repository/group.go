@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nasa9084/ident/domain/entity"
+)
+
+// GroupRepository is an interface of operations with groups.
+// A group exists if it has been created explicitly, or while it has
+// members or roles.
+type GroupRepository interface {
+	// ListGroups returns names of all groups, sorted.
+	ListGroups(ctx context.Context) ([]string, error)
+	FindGroup(ctx context.Context, name string) (entity.Group, error)
+	CreateGroup(ctx context.Context, name string) error
+	// DeleteGroup deletes the group with its memberships and roles.
+	DeleteGroup(ctx context.Context, name string) error
+	// FindGroups returns groups which the user is a member of.
+	FindGroups(ctx context.Context, userID string) ([]string, error)
+	// FindGroupRoles returns roles granted to the user through groups.
+	FindGroupRoles(ctx context.Context, userID string) ([]string, error)
+
+	AddMember(ctx context.Context, group, userID string) error
+	RemoveMember(ctx context.Context, group, userID string) error
+	AddGroupRole(ctx context.Context, group, role string) error
+	RemoveGroupRole(ctx context.Context, group, role string) error
+}
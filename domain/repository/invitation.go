@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nasa9084/ident/domain/entity"
+)
+
+// InvitationRepository is an interface of operations with invitations.
+type InvitationRepository interface {
+	CreateInvitation(context.Context, entity.Invitation) error
+	// UseInvitation consumes a use of the invitation.
+	// ErrInvitationInvalid is returned if the invitation cannot be used.
+	UseInvitation(ctx context.Context, code string) error
+	DeleteInvitation(ctx context.Context, code string) error
+}
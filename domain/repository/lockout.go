@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// LockoutRepository is an interface of operations with authentication
+// failure counters and temporary lockouts.
+type LockoutRepository interface {
+	RecordFailure(ctx context.Context, key string, ttl time.Duration) (failures int, err error)
+	Lock(ctx context.Context, key string, d time.Duration) error
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	Reset(ctx context.Context, key string) error
+}
@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// OTPRepository is an interface of operations with one-time passcodes.
+type OTPRepository interface {
+	SaveOTP(ctx context.Context, purpose, subject, code string, ttl time.Duration) error
+	ConsumeOTP(ctx context.Context, purpose, subject, code string) (ok bool, err error)
+	DeleteOTP(ctx context.Context, purpose, subject string) error
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nasa9084/ident/domain/entity"
+)
+
+// ProfileRepository is an interface of operations with profiles of users.
+type ProfileRepository interface {
+	FindProfile(ctx context.Context, userID string) (entity.Profile, error)
+	// UpdateProfile sets given attributes of the user.
+	// Attributes with empty value are removed.
+	UpdateProfile(ctx context.Context, userID string, p entity.Profile) error
+}
@@ -0,0 +1,11 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// RateLimitRepository is an interface of operations with rate limit counters.
+type RateLimitRepository interface {
+	Incr(ctx context.Context, key string, window time.Duration) (count int, err error)
+}
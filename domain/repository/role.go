@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nasa9084/ident/domain/entity"
+)
+
+// RoleRepository is an interface of operations with roles of users.
+type RoleRepository interface {
+	FindRoles(ctx context.Context, userID string) ([]string, error)
+	AddRole(ctx context.Context, userID, role string) error
+	RemoveRole(ctx context.Context, userID, role string) error
+
+	// FindInheritance returns all inheritance relations between roles.
+	FindInheritance(ctx context.Context) (entity.RoleInheritance, error)
+	AddInheritance(ctx context.Context, role, inherited string) error
+	RemoveInheritance(ctx context.Context, role, inherited string) error
+}
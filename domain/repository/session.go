@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/nasa9084/ident/domain/entity"
+)
+
+// SessionRepository is an interface of operations with
+// sessions and their authentication context.
+type SessionRepository interface {
+	AddAuthMethod(ctx context.Context, sessionID string, method entity.AuthMethod, at time.Time) error
+	FindAuthContext(ctx context.Context, sessionID string) (entity.AuthContext, error)
+	DeleteUserSessions(ctx context.Context, userID string) error
+}
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/nasa9084/ident/domain/entity"
 )
@@ -9,10 +11,69 @@ import (
 // UserRepository is an interface of operations with user.
 type UserRepository interface {
 	ExistsUser(ctx context.Context, userID string) (exists bool, err error)
-	CreateUser(ctx context.Context, userID, password string) (sessionID string, err error)
+	// CreateUser creates a pending registration which expires after ttl.
+	CreateUser(ctx context.Context, userID, password string, ttl time.Duration) (sessionID string, err error)
+	// ResumeRegistration extends the expiry of the pending registration
+	// to ttl from now, and creates a new session for it.
+	ResumeRegistration(ctx context.Context, u entity.User, ttl time.Duration) (sessionID string, err error)
 	FindUserBySessionID(ctx context.Context, sessionID string) (entity.User, error)
 	FindUserByID(ctx context.Context, userID string) (entity.User, error)
+	// FindUserByEmail finds the user who has verified the email address.
+	FindUserByEmail(ctx context.Context, email string) (entity.User, error)
 	UpdateUser(context.Context, entity.User) error
 	Verify(context.Context, entity.User) error
-	CreateSession(entity.User) (sessionID string, err error)
+	// AwaitApproval keeps the pending registration until admin
+	// approves it with Verify or rejects it with DeleteRegistration.
+	AwaitApproval(context.Context, entity.User) error
+	// DeleteRegistration deletes the pending registration.
+	// Unlike DeleteUser, the user ID can be registered again.
+	DeleteRegistration(context.Context, entity.User) error
+	// CreateSession creates a login session which expires after ttl.
+	CreateSession(u entity.User, ttl time.Duration) (sessionID string, err error)
+	ListUsers(context.Context, UserFilter) ([]entity.User, error)
+	// CountUsers counts users matching the filter.
+	// Pagination fields of the filter are not used.
+	CountUsers(context.Context, UserFilter) (int, error)
+	DeleteUser(context.Context, entity.User) error
+}
+
+// UserFilter is a condition to list users.
+// Zero value fields are not used as condition.
+type UserFilter struct {
+	ID       string
+	IDPrefix string
+	Email    string
+	// Verified lists verified users if true, and pending registrations
+	// if false. Both are listed if nil.
+	Verified      *bool
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// After is a cursor for pagination.
+	// Users whose ID is greater than After are listed.
+	After string
+	// Offset is the number of users skipped from the beginning.
+	Offset int
+	Limit  int
+}
+
+// Match returns whether the user matches the filter.
+func (f UserFilter) Match(u entity.User) bool {
+	switch {
+	case f.ID != "" && u.ID != f.ID:
+		return false
+	case !strings.HasPrefix(u.ID, f.IDPrefix):
+		return false
+	case f.Email != "" && u.Email != f.Email:
+		return false
+	case f.Verified != nil && *f.Verified == u.Pending:
+		return false
+	case !f.CreatedAfter.IsZero() && u.CreatedAt.Before(f.CreatedAfter):
+		return false
+	case !f.CreatedBefore.IsZero() && !u.CreatedAt.Before(f.CreatedBefore):
+		return false
+	case f.After != "" && u.ID <= f.After:
+		return false
+	}
+	return true
 }
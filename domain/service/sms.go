@@ -0,0 +1,6 @@
+package service
+
+// SMS interface represents SMS client.
+type SMS interface {
+	Send(to, body string) error
+}
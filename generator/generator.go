@@ -2,10 +2,15 @@ package generator
 
 import (
 	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/google/uuid"
+	"github.com/nasa9084/ident/domain/entity"
 	"github.com/nasa9084/ident/util"
 )
 
@@ -13,13 +18,33 @@ import (
 // for testing, this function is overridable.
 var TimeFunc = time.Now
 
-// NewToken returns a new signed JSON Web Token.
-func NewToken(privKey *ecdsa.PrivateKey, userID string) (string, error) {
+// NewToken returns a new signed JSON Web Token for the audience.
+// The token describes how the user has been authenticated
+// with amr, acr and auth_time claims, and roles and groups of the user
+// with roles and groups claims, filtered for the audience.
+// aud claim is omitted if audience is empty.
+// extra claims such as profile attributes are also included,
+// but they never override the claims above.
+func NewToken(privKey *ecdsa.PrivateKey, userID, audience string, ac entity.AuthContext, authz entity.Authorization, extra map[string]interface{}) (string, error) {
 	now := TimeFunc()
+	authz = authz.ForAudience(audience)
 	claims := jwt.MapClaims{
-		"iat":     now.Unix(),
-		"exp":     now.Add(1 * time.Hour).Unix(),
-		"user_id": userID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(1 * time.Hour).Unix(),
+		"user_id":   userID,
+		"amr":       ac.AMR(),
+		"acr":       ac.ACR(),
+		"auth_time": ac.AuthTime().Unix(),
+		"roles":     authz.Roles,
+		"groups":    authz.Groups,
+	}
+	if audience != "" {
+		claims["aud"] = audience
+	}
+	for k, v := range extra {
+		if _, ok := claims[k]; !ok {
+			claims[k] = v
+		}
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
 	return token.SignedString(privKey)
@@ -29,3 +54,37 @@ func NewToken(privKey *ecdsa.PrivateKey, userID string) (string, error) {
 func NewSecret() string {
 	return util.SHA512Digest(uuid.New().String())
 }
+
+// NewOTP generates a new 6-digit one-time passcode.
+func NewOTP() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// NewInvitationCode generates a new random invitation code.
+func NewInvitationCode() (string, error) {
+	return randomHex(16)
+}
+
+// NewVerificationToken generates a new random token for the link
+// to verify the email address.
+func NewVerificationToken() (string, error) {
+	return randomHex(16)
+}
+
+// NewRevertCode generates a new random code for the link
+// to revert the change of email address.
+func NewRevertCode() (string, error) {
+	return randomHex(16)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -17,7 +17,7 @@ import (
 )
 
 func parseRequest(r *http.Request, dest input.Request) error {
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodGet && r.Body != http.NoBody {
 		if err := json.NewDecoder(r.Body).Decode(dest); err != nil {
 			return errors.Wrap(err, "parsing request body")
 		}
@@ -29,9 +29,27 @@ func parseRequest(r *http.Request, dest input.Request) error {
 		}
 		sessReq.SetSessionID(authorization)
 	}
+	if adReq, ok := dest.(input.AdminRequest); ok {
+		adReq.SetAdminToken(r.Header.Get("X-ADMIN-TOKEN"))
+	}
 	if arReq, ok := dest.(input.PathArgsRequest); ok {
 		arReq.SetPathArgs(mux.Vars(r))
 	}
+	if qaReq, ok := dest.(input.QueryArgsRequest); ok {
+		args := map[string]string{}
+		query := r.URL.Query()
+		for k := range query {
+			args[k] = query.Get(k)
+		}
+		qaReq.SetQueryArgs(args)
+	}
+	if haReq, ok := dest.(input.HeaderArgsRequest); ok {
+		args := map[string]string{}
+		for k := range r.Header {
+			args[k] = r.Header.Get(k)
+		}
+		haReq.SetHeaderArgs(args)
+	}
 	return dest.Validate()
 }
 
@@ -155,3 +173,410 @@ func AuthByPasswordHandler(env *infra.Environment) http.HandlerFunc {
 		usecase.AuthByPassword(r.Context(), req, env).Render(w)
 	}
 }
+
+func UpdatePhoneNumberHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.UpdatePhoneNumberRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.UpdatePhoneNumber(r.Context(), req, env).Render(w)
+	}
+}
+
+func VerifyPhoneNumberHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.VerifyPhoneNumberRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.VerifyPhoneNumber(r.Context(), req, env).Render(w)
+	}
+}
+
+func SendSMSCodeHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.SendSMSCodeRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.SendSMSCode(r.Context(), req, env).Render(w)
+	}
+}
+
+func AuthBySMSHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.AuthBySMSRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.AuthBySMS(r.Context(), req, env).Render(w)
+	}
+}
+
+func EnrollTOTPHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.EnrollTOTPRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.EnrollTOTP(r.Context(), req, env).Render(w)
+	}
+}
+
+func AuthForTOTPResetHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.AuthForTOTPResetRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.AuthForTOTPReset(r.Context(), req, env).Render(w)
+	}
+}
+
+func ResetTOTPHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.ResetTOTPRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.ResetTOTP(r.Context(), req, env).Render(w)
+	}
+}
+
+func UnlockUserHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.UnlockUserRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.UnlockUser(r.Context(), req, env).Render(w)
+	}
+}
+
+func IssueTokenHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.IssueTokenRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.IssueToken(r.Context(), req, env).Render(w)
+	}
+}
+
+func ListUsersHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.ListUsersRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.ListUsers(r.Context(), req, env).Render(w)
+	}
+}
+
+func GetUserHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.GetUserRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.GetUser(r.Context(), req, env).Render(w)
+	}
+}
+
+func GrantRoleHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.GrantRoleRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.GrantRole(r.Context(), req, env).Render(w)
+	}
+}
+
+func RevokeRoleHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.RevokeRoleRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.RevokeRole(r.Context(), req, env).Render(w)
+	}
+}
+
+func DeleteUserHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.DeleteUserRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.DeleteUser(r.Context(), req, env).Render(w)
+	}
+}
+
+func DeleteAccountHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.DeleteAccountRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.DeleteAccount(r.Context(), req, env).Render(w)
+	}
+}
+
+func SuspendUserHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.SuspendUserRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.SuspendUser(r.Context(), req, env).Render(w)
+	}
+}
+
+func ReactivateUserHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.ReactivateUserRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.ReactivateUser(r.Context(), req, env).Render(w)
+	}
+}
+
+func GetProfileHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.GetProfileRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.GetProfile(r.Context(), req, env).Render(w)
+	}
+}
+
+func UpdateProfileHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.UpdateProfileRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.UpdateProfile(r.Context(), req, env).Render(w)
+	}
+}
+
+func GetUserProfileHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.GetUserProfileRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.GetUserProfile(r.Context(), req, env).Render(w)
+	}
+}
+
+func UpdateUserProfileHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.UpdateUserProfileRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.UpdateUserProfile(r.Context(), req, env).Render(w)
+	}
+}
+
+func GetGroupHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.GetGroupRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.GetGroup(r.Context(), req, env).Render(w)
+	}
+}
+
+func AddGroupMemberHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.AddGroupMemberRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.AddGroupMember(r.Context(), req, env).Render(w)
+	}
+}
+
+func RemoveGroupMemberHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.RemoveGroupMemberRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.RemoveGroupMember(r.Context(), req, env).Render(w)
+	}
+}
+
+func GrantGroupRoleHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.GrantGroupRoleRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.GrantGroupRole(r.Context(), req, env).Render(w)
+	}
+}
+
+func RevokeGroupRoleHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.RevokeGroupRoleRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.RevokeGroupRole(r.Context(), req, env).Render(w)
+	}
+}
+
+func AddRoleInheritanceHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.AddRoleInheritanceRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.AddRoleInheritance(r.Context(), req, env).Render(w)
+	}
+}
+
+func RemoveRoleInheritanceHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.RemoveRoleInheritanceRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.RemoveRoleInheritance(r.Context(), req, env).Render(w)
+	}
+}
+
+func RevokeInvitationHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.RevokeInvitationRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.RevokeInvitation(r.Context(), req, env).Render(w)
+	}
+}
+
+func ApproveRegistrationHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.ApproveRegistrationRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.ApproveRegistration(r.Context(), req, env).Render(w)
+	}
+}
+
+func RejectRegistrationHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.RejectRegistrationRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.RejectRegistration(r.Context(), req, env).Render(w)
+	}
+}
+
+func CreateInvitationHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.CreateInvitationRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.CreateInvitation(r.Context(), req, env).Render(w)
+	}
+}
+
+func ListRegistrationsHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.ListRegistrationsRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.ListRegistrations(r.Context(), req, env).Render(w)
+	}
+}
+
+func RevertEmailHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.RevertEmailRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.RevertEmail(r.Context(), req, env).Render(w)
+	}
+}
+
+func GetEmailVerificationHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.GetEmailVerificationRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.GetEmailVerification(r.Context(), req, env).Render(w)
+	}
+}
+
+func ResendEmailVerificationHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.ResendEmailVerificationRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.ResendEmailVerification(r.Context(), req, env).Render(w)
+	}
+}
+
+func ResumeRegistrationHandler(env *infra.Environment) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req input.ResumeRegistrationRequest
+		if err := parseRequest(r, &req); err != nil {
+			renderErr(w, err)
+			return
+		}
+		usecase.ResumeRegistration(r.Context(), req, env).Render(w)
+	}
+}
@@ -3,11 +3,15 @@ package ident
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/scim"
+	"github.com/nasa9084/ident/usecase"
 	"github.com/nasa9084/syg"
 )
 
@@ -25,11 +29,12 @@ func NewServer(addr string, privKeyPath string, cfg infra.Config) (*Server, erro
 	}
 	router := mux.NewRouter()
 	bindRoutes(router, env)
+	scim.BindRoutes(router.PathPrefix("/scim/v2").Subrouter(), env)
 
 	s := &Server{
 		server: &http.Server{
 			Addr:    addr,
-			Handler: router,
+			Handler: withClientIP(router, cfg.Lockout.TrustForwardedFor),
 		},
 		closed: make(chan struct{}),
 	}
@@ -54,3 +59,28 @@ func (s *Server) Shutdown(os.Signal) {
 
 	s.server.Shutdown(context.Background())
 }
+
+// withClientIP stores the client IP address into the request context.
+func withClientIP(h http.Handler, trustForwardedFor bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIPOf(r, trustForwardedFor)
+		h.ServeHTTP(w, r.WithContext(usecase.WithClientIP(r.Context(), ip)))
+	})
+}
+
+// clientIPOf returns the client IP address of the request.
+// If trustForwardedFor is true, the last address of X-Forwarded-For
+// header, which is appended by the proxy in front of the server,
+// is used as the client IP. Earlier addresses are given by the client
+// and cannot be trusted.
+func clientIPOf(r *http.Request, trustForwardedFor bool) string {
+	if xff := r.Header.Get("X-Forwarded-For"); trustForwardedFor && xff != "" {
+		addrs := strings.Split(xff, ",")
+		return strings.TrimSpace(addrs[len(addrs)-1])
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
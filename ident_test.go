@@ -0,0 +1,31 @@
+package ident
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPOf(t *testing.T) {
+	candidates := []struct {
+		label             string
+		xff               string
+		trustForwardedFor bool
+		expected          string
+	}{
+		{"no header", "", true, "192.0.2.1"},
+		{"untrusted", "198.51.100.1", false, "192.0.2.1"},
+		{"single", "198.51.100.1", true, "198.51.100.1"},
+		{"spoofed", "203.0.113.1, 198.51.100.1", true, "198.51.100.1"},
+	}
+	for _, c := range candidates {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		if c.xff != "" {
+			r.Header.Set("X-Forwarded-For", c.xff)
+		}
+		if ip := clientIPOf(r, c.trustForwardedFor); ip != c.expected {
+			t.Errorf("%s: %s != %s", c.label, ip, c.expected)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/infra/database"
+	"github.com/nasa9084/ident/infra/envelope"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// openRDB opens the database for tests.
+// PostgreSQL is used instead of MySQL if STORAGE_DRIVER is postgres.
+func openRDB(t *testing.T) *database.RDB {
+	cfg := infra.StorageConfig{
+		Driver: os.Getenv("STORAGE_DRIVER"),
+		MySQL: infra.MySQLConfig{
+			Addr:   "localhost:3306",
+			User:   "root",
+			DBName: os.Getenv("MYSQL_DB"),
+		},
+		Postgres: infra.PostgresConfig{
+			Addr:    "localhost:5432",
+			User:    "postgres",
+			DBName:  os.Getenv("POSTGRES_DB"),
+			SSLMode: "disable",
+		},
+	}
+	if cfg.MySQL.DBName == "" {
+		cfg.MySQL.DBName = "ident"
+	}
+	if cfg.Postgres.DBName == "" {
+		cfg.Postgres.DBName = "ident"
+	}
+	rdb, err := cfg.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rdb
+}
+
+func openKVS(t *testing.T) redis.Conn {
+	kvs, err := redis.Dial("tcp", "localhost:6379")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kvs
+}
+
+const keyFile = `# test keys
+old AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+new AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=
+`
+
+// importUsers stores the users into RDB as they have completed
+// registration. Secrets are stored in plain text.
+func importUsers(t *testing.T, rdb *database.RDB, kvs redis.Conn, policy entity.UserIDPolicy, users ...entity.User) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := func() (entity.User, error) {
+		if len(users) == 0 {
+			return entity.User{}, io.EOF
+		}
+		u := users[0]
+		users = users[1:]
+		u.Password = string(hashed)
+		u.CreatedAt = time.Now()
+		return u, nil
+	}
+	opts := database.ImportOptions{
+		Conflict: database.ConflictOverwrite,
+		Policy:   policy,
+	}
+	if _, err := database.ImportUsers(context.Background(), rdb, kvs, nil, next, opts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRekey(t *testing.T) {
+	rdb := openRDB(t)
+	kvs := openKVS(t)
+	ctx := context.Background()
+	kr, err := envelope.ReadKeyring(strings.NewReader(keyFile), "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := entity.DefaultUserIDPolicy()
+	importUsers(t, rdb, kvs, policy,
+		// users without secrets must not be returned forever
+		entity.User{ID: "rekey-empty", TOTPResetRequired: true},
+		entity.User{ID: "rekey-secret", TOTPSecret: "secret"},
+	)
+
+	n, err := database.Rekey(ctx, rdb, kvs, kr)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if n < 1 {
+		t.Errorf("%d users are re-encrypted", n)
+		return
+	}
+	n, err = database.Rekey(ctx, rdb, kvs, kr)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if n != 0 {
+		t.Errorf("%d users are re-encrypted again", n)
+		return
+	}
+
+	repo := database.NewUserRepository(rdb, kvs, kr, policy)
+	u, err := repo.FindUserByID(ctx, "rekey-secret")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if u.TOTPSecret != "secret" {
+		t.Errorf("%s != secret", u.TOTPSecret)
+		return
+	}
+	u, err = repo.FindUserByID(ctx, "rekey-empty")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if u.TOTPSecret != "" {
+		t.Errorf("empty secret is changed: %s", u.TOTPSecret)
+		return
+	}
+}
+
+func TestCaseInsensitiveUserID(t *testing.T) {
+	rdb := openRDB(t)
+	kvs := openKVS(t)
+	ctx := context.Background()
+	policy := entity.DefaultUserIDPolicy()
+	policy.Profile = entity.UserIDCasePreserved
+	importUsers(t, rdb, kvs, policy, entity.User{
+		ID:            "Case.Check",
+		Email:         "Case.Check@example.com",
+		EmailVerified: true,
+	})
+
+	repo := database.NewUserRepository(rdb, kvs, nil, policy)
+	for _, userID := range []string{"Case.Check", "case.check", "CASE.CHECK"} {
+		u, err := repo.FindUserByID(ctx, userID)
+		if err != nil {
+			t.Errorf("%s: %s", userID, err)
+			return
+		}
+		if u.ID != "Case.Check" {
+			t.Errorf("%s != Case.Check", u.ID)
+			return
+		}
+	}
+	u, err := repo.FindUserByEmail(ctx, "case.check@example.com")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if u.ID != "Case.Check" {
+		t.Errorf("%s != Case.Check", u.ID)
+		return
+	}
+
+	conflicts, err := database.CheckUserIDs(ctx, rdb, kvs, policy)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, c := range conflicts {
+		for _, userID := range c.UserIDs {
+			if userID == "Case.Check" {
+				t.Errorf("unexpected conflict: %+v", c)
+				return
+			}
+		}
+	}
+	conflicts, err = database.CheckUserIDs(ctx, rdb, kvs, entity.DefaultUserIDPolicy())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := database.UserIDConflict{Normalized: "case.check", UserIDs: []string{"Case.Check"}}
+	var found bool
+	for _, c := range conflicts {
+		if reflect.DeepEqual(c, expected) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("conflict is not found: %+v", conflicts)
+		return
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	rdb := openRDB(t)
+	kvs := openKVS(t)
+	ctx := context.Background()
+	policy := entity.DefaultUserIDPolicy()
+	var users []entity.User
+	for _, userID := range []string{"list-0", "list-1", "list-2", "list-3", "list-4"} {
+		users = append(users, entity.User{ID: userID, TOTPResetRequired: true})
+	}
+	importUsers(t, rdb, kvs, policy, users...)
+
+	repo := database.NewUserRepository(rdb, kvs, nil, policy)
+	verified := true
+	candidates := []struct {
+		offset   int
+		limit    int
+		expected []string
+	}{
+		{0, 0, []string{"list-0", "list-1", "list-2", "list-3", "list-4"}},
+		{0, 2, []string{"list-0", "list-1"}},
+		{1, 2, []string{"list-1", "list-2"}},
+		{3, 0, []string{"list-3", "list-4"}},
+		{5, 0, nil},
+	}
+	for _, c := range candidates {
+		filter := repository.UserFilter{IDPrefix: "list-", Verified: &verified, Offset: c.offset, Limit: c.limit}
+		listed, err := repo.ListUsers(ctx, filter)
+		if err != nil {
+			t.Errorf("offset %d, limit %d: %s", c.offset, c.limit, err)
+			continue
+		}
+		var userIDs []string
+		for _, u := range listed {
+			userIDs = append(userIDs, u.ID)
+		}
+		if !reflect.DeepEqual(userIDs, c.expected) {
+			t.Errorf("offset %d, limit %d: %v != %v", c.offset, c.limit, userIDs, c.expected)
+		}
+		n, err := repo.CountUsers(ctx, filter)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if n != len(users) {
+			t.Errorf("%d != %d", n, len(users))
+		}
+	}
+}
+
+func TestUpdateExpiredRegistration(t *testing.T) {
+	rdb := openRDB(t)
+	kvs := openKVS(t)
+	ctx := context.Background()
+	repo := database.NewUserRepository(rdb, kvs, nil, entity.DefaultUserIDPolicy())
+	if _, err := repo.CreateUser(ctx, "update-expired", "password", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	u, err := repo.FindUserByID(ctx, "update-expired")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kvs.Do("DEL", "user:update-expired"); err != nil {
+		t.Fatal(err)
+	}
+
+	u.PendingEmail = "update-expired@example.com"
+	if err := repo.UpdateUser(ctx, u); err != database.ErrUserNotFound {
+		t.Errorf("%v != %v", err, database.ErrUserNotFound)
+		return
+	}
+	if _, err := repo.FindUserByID(ctx, "update-expired"); err != sql.ErrNoRows {
+		t.Errorf("expired registration is recreated: %v", err)
+		return
+	}
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/infra/database/rdbms"
+)
+
+type groupRepository struct {
+	RDB     *sql.DB
+	Backend rdbms.Dialect
+}
+
+// NewGroupRepository returns a new GroupRepository instance.
+func NewGroupRepository(rdb *RDB) repository.GroupRepository {
+	return &groupRepository{
+		RDB:     rdb.db,
+		Backend: rdb.dialect,
+	}
+}
+
+// FindGroup returns the group with its roles and members.
+func (repo *groupRepository) FindGroup(ctx context.Context, name string) (entity.Group, error) {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return entity.Group{}, err
+	}
+	defer tx.Rollback()
+	return repo.findGroup(ctx, tx, name)
+}
+
+func (repo *groupRepository) findGroup(ctx context.Context, tx *sql.Tx, name string) (entity.Group, error) {
+	var err error
+	g := entity.Group{Name: name}
+	if g.Roles, err = repo.Backend.FindRolesOfGroup(ctx, tx, name); err != nil {
+		return entity.Group{}, err
+	}
+	if g.Members, err = repo.Backend.FindGroupMembers(ctx, tx, name); err != nil {
+		return entity.Group{}, err
+	}
+	if len(g.Roles) == 0 && len(g.Members) == 0 {
+		exists, err := repo.Backend.ExistGroup(ctx, tx, name)
+		if err != nil {
+			return entity.Group{}, err
+		}
+		if !exists {
+			return entity.Group{}, repository.ErrGroupNotFound
+		}
+	}
+	return g, nil
+}
+
+// ListGroups returns names of all groups.
+func (repo *groupRepository) ListGroups(ctx context.Context) ([]string, error) {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return repo.Backend.ListGroups(ctx, tx)
+}
+
+// CreateGroup creates an empty group.
+func (repo *groupRepository) CreateGroup(ctx context.Context, name string) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := repo.findGroup(ctx, tx, name); err != repository.ErrGroupNotFound {
+		if err == nil {
+			return repository.ErrGroupExists
+		}
+		return err
+	}
+	if err := repo.Backend.CreateGroup(ctx, tx, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteGroup deletes the group with its memberships and roles.
+func (repo *groupRepository) DeleteGroup(ctx context.Context, name string) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := repo.Backend.DeleteGroup(ctx, tx, name); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindGroups returns groups which the user is a member of.
+func (repo *groupRepository) FindGroups(ctx context.Context, userID string) ([]string, error) {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return repo.Backend.FindGroups(ctx, tx, userID)
+}
+
+// FindGroupRoles returns roles granted to the user through groups.
+func (repo *groupRepository) FindGroupRoles(ctx context.Context, userID string) ([]string, error) {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return repo.Backend.FindGroupRoles(ctx, tx, userID)
+}
+
+// AddMember adds the user to the group.
+func (repo *groupRepository) AddMember(ctx context.Context, group, userID string) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := repo.Backend.AddMember(ctx, tx, group, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveMember removes the user from the group.
+func (repo *groupRepository) RemoveMember(ctx context.Context, group, userID string) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := repo.Backend.RemoveMember(ctx, tx, group, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddGroupRole grants the role to the group.
+func (repo *groupRepository) AddGroupRole(ctx context.Context, group, role string) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := repo.Backend.AddGroupRole(ctx, tx, group, role); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveGroupRole revokes the role from the group.
+func (repo *groupRepository) RemoveGroupRole(ctx context.Context, group, role string) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := repo.Backend.RemoveGroupRole(ctx, tx, group, role); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/generator"
+	"github.com/nasa9084/ident/infra/database/rdbms"
+)
+
+type invitationRepository struct {
+	RDB     *sql.DB
+	Backend rdbms.Dialect
+}
+
+// NewInvitationRepository returns a new InvitationRepository instance.
+func NewInvitationRepository(rdb *RDB) repository.InvitationRepository {
+	return &invitationRepository{
+		RDB:     rdb.db,
+		Backend: rdb.dialect,
+	}
+}
+
+// CreateInvitation creates a new invitation.
+func (repo *invitationRepository) CreateInvitation(ctx context.Context, inv entity.Invitation) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := repo.Backend.CreateInvitation(ctx, tx, inv); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseInvitation consumes a use of the invitation.
+func (repo *invitationRepository) UseInvitation(ctx context.Context, code string) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	used, err := repo.Backend.UseInvitation(ctx, tx, code, generator.TimeFunc())
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !used {
+		tx.Rollback()
+		return repository.ErrInvitationInvalid
+	}
+	return tx.Commit()
+}
+
+// DeleteInvitation deletes the invitation, so that the code cannot be used.
+func (repo *invitationRepository) DeleteInvitation(ctx context.Context, code string) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	deleted, err := repo.Backend.DeleteInvitation(ctx, tx, code)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !deleted {
+		tx.Rollback()
+		return repository.ErrInvitationNotFound
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/infra/database/redis"
+)
+
+type lockoutRepository struct {
+	Redis redigo.Conn
+}
+
+// NewLockoutRepository returns a new LockoutRepository instance.
+func NewLockoutRepository(kvs redigo.Conn) repository.LockoutRepository {
+	return &lockoutRepository{
+		Redis: kvs,
+	}
+}
+
+// RecordFailure records an authentication failure for given key.
+func (repo *lockoutRepository) RecordFailure(ctx context.Context, key string, ttl time.Duration) (int, error) {
+	return redis.RecordFailure(repo.Redis, key, ttl)
+}
+
+// Lock locks given key temporarily.
+func (repo *lockoutRepository) Lock(ctx context.Context, key string, d time.Duration) error {
+	return redis.Lock(repo.Redis, key, d)
+}
+
+// LockedFor returns how long given key is still locked.
+func (repo *lockoutRepository) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	return redis.LockedFor(repo.Redis, key)
+}
+
+// Reset clears failures and the lock for given key.
+func (repo *lockoutRepository) Reset(ctx context.Context, key string) error {
+	return redis.ResetLockout(repo.Redis, key)
+}
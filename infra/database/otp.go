@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/infra/database/redis"
+)
+
+type otpRepository struct {
+	Redis redigo.Conn
+}
+
+// NewOTPRepository returns a new OTPRepository instance.
+func NewOTPRepository(kvs redigo.Conn) repository.OTPRepository {
+	return &otpRepository{
+		Redis: kvs,
+	}
+}
+
+// SaveOTP stores a one-time passcode for given purpose and subject.
+func (repo *otpRepository) SaveOTP(ctx context.Context, purpose, subject, code string, ttl time.Duration) error {
+	return redis.SaveOTP(repo.Redis, purpose, subject, code, ttl)
+}
+
+// ConsumeOTP returns whether given passcode is valid or not.
+// Valid passcode is deleted, so it cannot be used again.
+func (repo *otpRepository) ConsumeOTP(ctx context.Context, purpose, subject, code string) (bool, error) {
+	return redis.ConsumeOTP(repo.Redis, purpose, subject, code)
+}
+
+// DeleteOTP invalidates the passcode for given purpose and subject.
+func (repo *otpRepository) DeleteOTP(ctx context.Context, purpose, subject string) error {
+	return redis.DeleteOTP(repo.Redis, purpose, subject)
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/infra/database/rdbms"
+)
+
+type profileRepository struct {
+	RDB     *sql.DB
+	Backend rdbms.Dialect
+}
+
+// NewProfileRepository returns a new ProfileRepository instance.
+func NewProfileRepository(rdb *RDB) repository.ProfileRepository {
+	return &profileRepository{
+		RDB:     rdb.db,
+		Backend: rdb.dialect,
+	}
+}
+
+// FindProfile returns profile attributes of the user.
+func (repo *profileRepository) FindProfile(ctx context.Context, userID string) (entity.Profile, error) {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return repo.Backend.FindProfile(ctx, tx, userID)
+}
+
+// UpdateProfile sets or removes profile attributes of the user.
+func (repo *profileRepository) UpdateProfile(ctx context.Context, userID string, p entity.Profile) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for name, value := range p {
+		if value == "" {
+			err = repo.Backend.RemoveAttribute(ctx, tx, userID, name)
+		} else {
+			err = repo.Backend.SetAttribute(ctx, tx, userID, name, value)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
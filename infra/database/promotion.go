@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/generator"
+	"github.com/nasa9084/ident/infra/database/rdbms"
+	"github.com/nasa9084/ident/infra/database/redis"
+)
+
+// Promotion of a pending registration from Redis to RDB is done in
+// three steps, each of which can be retried safely:
+//
+//  1. the final state of the user is stored in Redis with a marker
+//  2. the user is inserted into RDB
+//  3. the user is deleted from Redis
+//
+// If the process crashes between the steps, the user marked in Redis is
+// completed by ReconcilePromotions.
+
+// insertPromoted inserts the user being promoted into RDB.
+// Returns false if the user has already been inserted by the previous
+// attempt, or repository.ErrUserExists if the user ID has been used by
+// another user.
+// If dryRun is true, the insertion is rolled back instead of committed,
+// so that the result is the same as the real run without changing anything.
+func insertPromoted(ctx context.Context, db *sql.DB, b rdbms.Dialect, u entity.User, keyID string, dryRun bool) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	stored, err := b.FindUser(ctx, tx, u.ID)
+	switch err {
+	case nil:
+		tx.Rollback()
+		if !samePromotion(stored, u) {
+			return false, repository.ErrUserExists
+		}
+		return false, nil
+	case sql.ErrNoRows:
+	default:
+		tx.Rollback()
+		return false, err
+	}
+	if err := b.CreateUser(ctx, tx, u, keyID); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if dryRun {
+		return true, tx.Rollback()
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// samePromotion returns the user stored in RDB has been promoted from
+// given registration or not.
+// TOTP secrets cannot be compared since they are encrypted again
+// on every attempt.
+func samePromotion(stored, u entity.User) bool {
+	return stored.Password == u.Password && stored.CreatedAt.Unix() == u.CreatedAt.Unix()
+}
+
+// ReconcileResult is the result of ReconcilePromotions.
+type ReconcileResult struct {
+	// Promoted is the number of users inserted into RDB.
+	Promoted int
+	// Cleaned is the number of users which had been inserted into RDB
+	// but left in Redis.
+	Cleaned int
+	// Discarded is the number of registrations whose user ID has been
+	// used by another user.
+	Discarded int
+	// Skipped is the number of registrations left in Redis since
+	// their verified email address has been used by another user.
+	Skipped int
+}
+
+// ReconcilePromotions completes promotions interrupted before
+// the user is deleted from Redis.
+// Users marked longer than grace ago are regarded as interrupted, so that
+// running promotions are not disturbed.
+// If dryRun is true, users are counted in the same way without changing anything.
+func ReconcilePromotions(ctx context.Context, rdb *RDB, kvs redigo.Conn, grace time.Duration, dryRun bool) (ReconcileResult, error) {
+	var result ReconcileResult
+	userIDs, err := redis.ScanUserIDs(kvs)
+	if err != nil {
+		return result, err
+	}
+	deadline := generator.TimeFunc().Add(-grace)
+	for _, userID := range userIDs {
+		at, err := redis.FindPromotion(kvs, userID)
+		if err != nil {
+			return result, err
+		}
+		if at.IsZero() || at.After(deadline) {
+			continue
+		}
+		u, err := redis.FindUser(kvs, userID)
+		if err == redis.ErrUserNotFound {
+			// completed meanwhile
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		_, _, keyID, err := redis.FindSecrets(kvs, userID)
+		if err != nil {
+			return result, err
+		}
+		inserted, err := insertPromoted(ctx, rdb.db, rdb.dialect, u, keyID, dryRun)
+		switch {
+		case err == repository.ErrUserExists:
+			result.Discarded++
+		case err == repository.ErrEmailExists:
+			result.Skipped++
+			continue
+		case err != nil:
+			return result, err
+		case inserted:
+			result.Promoted++
+		default:
+			result.Cleaned++
+		}
+		if dryRun {
+			continue
+		}
+		if err := redis.DeleteUser(kvs, u); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,26 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/infra/database/redis"
+)
+
+type rateLimitRepository struct {
+	Redis redigo.Conn
+}
+
+// NewRateLimitRepository returns a new RateLimitRepository instance.
+func NewRateLimitRepository(kvs redigo.Conn) repository.RateLimitRepository {
+	return &rateLimitRepository{
+		Redis: kvs,
+	}
+}
+
+// Incr increments the counter for given key within the window.
+func (repo *rateLimitRepository) Incr(ctx context.Context, key string, window time.Duration) (int, error) {
+	return redis.IncrCounter(repo.Redis, key, window)
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/nasa9084/ident/infra/database/rdbms"
+)
+
+// RDB is a relational database which holds users who have completed
+// registration, with the SQL dialect of its driver.
+type RDB struct {
+	db      *sql.DB
+	dialect rdbms.Dialect
+}
+
+// NewRDB returns a new RDB, which queries db in given dialect.
+func NewRDB(db *sql.DB, dialect rdbms.Dialect) *RDB {
+	return &RDB{db: db, dialect: dialect}
+}
+
+// Close closes the database.
+func (rdb *RDB) Close() error {
+	return rdb.db.Close()
+}
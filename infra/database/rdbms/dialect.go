@@ -0,0 +1,82 @@
+package rdbms
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Dialect is the differences of SQL between relational databases.
+// Queries are written once with ? placeholders in the syntax common to
+// the databases, and the dialect rewrites them where the syntax differs.
+type Dialect struct {
+	// numbered is true if placeholders are written as $1, $2, ...
+	numbered bool
+	// insertIgnore rewrites the INSERT query to skip rows which violate
+	// unique keys.
+	insertIgnore func(query string) string
+	// upsert rewrites the INSERT query to update columns of the row
+	// which has the same keys.
+	upsert func(query string, keys []string, columns ...string) string
+	// noLimit is the LIMIT which does not limit rows.
+	noLimit string
+	// duplicateKey returns keyPrimary or keyVerifiedEmail if err is
+	// caused by the unique key of users, or empty string if not.
+	duplicateKey func(err error) string
+}
+
+// unique keys of users distinguished by duplicateKey.
+const (
+	keyPrimary       = "primary"
+	keyVerifiedEmail = "verified_email"
+)
+
+// rebind replaces ? placeholders in the query with the ones of the dialect.
+func (d Dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	buf := make([]byte, 0, len(query)+8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] != '?' {
+			buf = append(buf, query[i])
+			continue
+		}
+		n++
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(n), 10)
+	}
+	return string(buf)
+}
+
+// nullableTime is a time which is stored as NULL if it is zero.
+type nullableTime struct {
+	Time time.Time
+}
+
+func nullTime(t time.Time) nullableTime {
+	return nullableTime{Time: t}
+}
+
+// Scan implements sql.Scanner.
+func (t *nullableTime) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		t.Time = time.Time{}
+	case time.Time:
+		t.Time = v
+	default:
+		return fmt.Errorf("cannot scan %T into time", src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (t nullableTime) Value() (driver.Value, error) {
+	if t.Time.IsZero() {
+		return nil, nil
+	}
+	return t.Time, nil
+}
@@ -0,0 +1,214 @@
+package rdbms
+
+import (
+	"context"
+	"database/sql"
+)
+
+// FindGroupMembers finds members of the group from RDB.
+func (d Dialect) FindGroupMembers(ctx context.Context, tx *sql.Tx, group string) ([]string, error) {
+	const query = `SELECT user_id FROM group_members WHERE group_name = ? ORDER BY user_id`
+	rows, err := tx.QueryContext(ctx, d.rebind(query), group)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// FindRolesOfGroup finds roles granted to the group from RDB.
+func (d Dialect) FindRolesOfGroup(ctx context.Context, tx *sql.Tx, group string) ([]string, error) {
+	const query = `SELECT role FROM group_roles WHERE group_name = ? ORDER BY role`
+	rows, err := tx.QueryContext(ctx, d.rebind(query), group)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// FindGroups finds groups which the user is a member of from RDB.
+func (d Dialect) FindGroups(ctx context.Context, tx *sql.Tx, userID string) ([]string, error) {
+	const query = `SELECT group_name FROM group_members WHERE user_id = ? ORDER BY group_name`
+	rows, err := tx.QueryContext(ctx, d.rebind(query), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []string
+	for rows.Next() {
+		var group string
+		if err := rows.Scan(&group); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// FindGroupRoles finds roles granted to the user through groups from RDB.
+func (d Dialect) FindGroupRoles(ctx context.Context, tx *sql.Tx, userID string) ([]string, error) {
+	const query = `SELECT DISTINCT gr.role FROM group_members gm JOIN group_roles gr ON gm.group_name = gr.group_name WHERE gm.user_id = ? ORDER BY gr.role`
+	rows, err := tx.QueryContext(ctx, d.rebind(query), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// AddMember adds the user to the group.
+func (d Dialect) AddMember(ctx context.Context, tx *sql.Tx, group, userID string) error {
+	const query = `INSERT INTO group_members(group_name, user_id) VALUES(?, ?)`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(d.insertIgnore(query)))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(group, userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RemoveMember removes the user from the group.
+func (d Dialect) RemoveMember(ctx context.Context, tx *sql.Tx, group, userID string) error {
+	const query = `DELETE FROM group_members WHERE group_name = ? AND user_id = ?`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(group, userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteMemberships removes the user from all groups.
+func (d Dialect) DeleteMemberships(ctx context.Context, tx *sql.Tx, userID string) error {
+	const query = `DELETE FROM group_members WHERE user_id = ?`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddGroupRole grants the role to the group.
+func (d Dialect) AddGroupRole(ctx context.Context, tx *sql.Tx, group, role string) error {
+	const query = `INSERT INTO group_roles(group_name, role) VALUES(?, ?)`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(d.insertIgnore(query)))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(group, role); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RemoveGroupRole revokes the role from the group.
+func (d Dialect) RemoveGroupRole(ctx context.Context, tx *sql.Tx, group, role string) error {
+	const query = `DELETE FROM group_roles WHERE group_name = ? AND role = ?`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(group, role); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListGroups finds names of all groups from RDB, including the groups
+// which have not been created explicitly but have members or roles.
+func (d Dialect) ListGroups(ctx context.Context, tx *sql.Tx) ([]string, error) {
+	const query = `SELECT group_name FROM user_groups UNION SELECT group_name FROM group_members UNION SELECT group_name FROM group_roles ORDER BY group_name`
+	rows, err := tx.QueryContext(ctx, d.rebind(query))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []string
+	for rows.Next() {
+		var group string
+		if err := rows.Scan(&group); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// ExistGroup returns the group has been created explicitly or not.
+func (d Dialect) ExistGroup(ctx context.Context, tx *sql.Tx, group string) (bool, error) {
+	const query = `SELECT EXISTS (SELECT 1 FROM user_groups WHERE group_name = ?)`
+	row := tx.QueryRowContext(ctx, d.rebind(query), group)
+	var exists bool
+	if err := row.Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// CreateGroup creates a group into RDB.
+func (d Dialect) CreateGroup(ctx context.Context, tx *sql.Tx, group string) error {
+	const query = `INSERT INTO user_groups(group_name) VALUES(?)`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(group); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteGroup deletes a group with its members and roles from RDB.
+func (d Dialect) DeleteGroup(ctx context.Context, tx *sql.Tx, group string) error {
+	for _, query := range []string{
+		`DELETE FROM group_members WHERE group_name = ?`,
+		`DELETE FROM group_roles WHERE group_name = ?`,
+		`DELETE FROM user_groups WHERE group_name = ?`,
+	} {
+		stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package rdbms
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/util"
+)
+
+// CreateInvitation creates an invitation into RDB.
+// The code is stored as its digest, so that codes cannot be used
+// even if the database is leaked.
+func (d Dialect) CreateInvitation(ctx context.Context, tx *sql.Tx, inv entity.Invitation) error {
+	const query = `INSERT INTO invitations(code_hash, max_uses, uses, expires_at, created_at) VALUES(?, ?, ?, ?, ?)`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(util.SHA512Digest(inv.Code), inv.MaxUses, inv.Uses, nullTime(inv.ExpiresAt), inv.CreatedAt); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UseInvitation increments uses of the invitation if it has not expired
+// at now nor been used up. Returns whether the invitation is used.
+func (d Dialect) UseInvitation(ctx context.Context, tx *sql.Tx, code string, now time.Time) (bool, error) {
+	const query = `UPDATE invitations SET uses = uses + 1 WHERE code_hash = ? AND (max_uses = 0 OR uses < max_uses) AND (expires_at IS NULL OR expires_at > ?)`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return false, err
+	}
+	res, err := stmt.Exec(util.SHA512Digest(code), now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// DeleteInvitation deletes an invitation from RDB.
+// Returns whether the invitation has existed.
+func (d Dialect) DeleteInvitation(ctx context.Context, tx *sql.Tx, code string) (bool, error) {
+	const query = `DELETE FROM invitations WHERE code_hash = ?`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return false, err
+	}
+	res, err := stmt.Exec(util.SHA512Digest(code))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
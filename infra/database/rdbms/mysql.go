@@ -0,0 +1,40 @@
+package rdbms
+
+import (
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL is the dialect of MySQL.
+var MySQL = Dialect{
+	insertIgnore: func(query string) string {
+		return strings.Replace(query, "INSERT INTO", "INSERT IGNORE INTO", 1)
+	},
+	upsert: func(query string, keys []string, columns ...string) string {
+		set := make([]string, len(columns))
+		for i, column := range columns {
+			set[i] = column + " = VALUES(" + column + ")"
+		}
+		return query + ` ON DUPLICATE KEY UPDATE ` + strings.Join(set, ", ")
+	},
+	noLimit:      "18446744073709551615",
+	duplicateKey: mysqlDuplicateKey,
+}
+
+// errDuplicateEntry is the error number of MySQL on duplicate unique key.
+const errDuplicateEntry = 1062
+
+func mysqlDuplicateKey(err error) string {
+	me, ok := err.(*mysql.MySQLError)
+	if !ok || me.Number != errDuplicateEntry {
+		return ""
+	}
+	switch {
+	case strings.Contains(me.Message, "PRIMARY"):
+		return keyPrimary
+	case strings.Contains(me.Message, "verified_email"):
+		return keyVerifiedEmail
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package rdbms
+
+import (
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// PostgreSQL is the dialect of PostgreSQL.
+// User IDs and email addresses are stored as CITEXT, so that they are
+// compared case-insensitively as in MySQL.
+var PostgreSQL = Dialect{
+	numbered: true,
+	insertIgnore: func(query string) string {
+		return query + ` ON CONFLICT DO NOTHING`
+	},
+	upsert: func(query string, keys []string, columns ...string) string {
+		set := make([]string, len(columns))
+		for i, column := range columns {
+			set[i] = column + " = EXCLUDED." + column
+		}
+		return query + ` ON CONFLICT (` + strings.Join(keys, ", ") + `) DO UPDATE SET ` + strings.Join(set, ", ")
+	},
+	noLimit:      "ALL",
+	duplicateKey: postgresDuplicateKey,
+}
+
+// errUniqueViolation is the error code of PostgreSQL on duplicate unique key.
+const errUniqueViolation = "23505"
+
+func postgresDuplicateKey(err error) string {
+	pe, ok := err.(*pq.Error)
+	if !ok || pe.Code != errUniqueViolation {
+		return ""
+	}
+	switch pe.Constraint {
+	case "users_pkey":
+		return keyPrimary
+	case "users_verified_email_key":
+		return keyVerifiedEmail
+	}
+	return ""
+}
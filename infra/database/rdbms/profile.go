@@ -0,0 +1,67 @@
+package rdbms
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/nasa9084/ident/domain/entity"
+)
+
+// FindProfile finds profile attributes of the user from RDB.
+func (d Dialect) FindProfile(ctx context.Context, tx *sql.Tx, userID string) (entity.Profile, error) {
+	const query = `SELECT name, value FROM user_profiles WHERE user_id = ?`
+	rows, err := tx.QueryContext(ctx, d.rebind(query), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	p := entity.Profile{}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		p[name] = value
+	}
+	return p, rows.Err()
+}
+
+// SetAttribute sets a profile attribute of the user.
+func (d Dialect) SetAttribute(ctx context.Context, tx *sql.Tx, userID, name, value string) error {
+	const query = `INSERT INTO user_profiles(user_id, name, value) VALUES(?, ?, ?)`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(d.upsert(query, []string{"user_id", "name"}, "value")))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(userID, name, value); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RemoveAttribute removes a profile attribute from the user.
+func (d Dialect) RemoveAttribute(ctx context.Context, tx *sql.Tx, userID, name string) error {
+	const query = `DELETE FROM user_profiles WHERE user_id = ? AND name = ?`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(userID, name); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteProfile removes all profile attributes from the user.
+func (d Dialect) DeleteProfile(ctx context.Context, tx *sql.Tx, userID string) error {
+	const query = `DELETE FROM user_profiles WHERE user_id = ?`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(userID); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package rdbms
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/nasa9084/ident/domain/entity"
+)
+
+// FindRoles finds roles of the user from RDB.
+func (d Dialect) FindRoles(ctx context.Context, tx *sql.Tx, userID string) ([]string, error) {
+	const query = `SELECT role FROM user_roles WHERE user_id = ? ORDER BY role`
+	rows, err := tx.QueryContext(ctx, d.rebind(query), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// AddRole adds a role to the user.
+func (d Dialect) AddRole(ctx context.Context, tx *sql.Tx, userID, role string) error {
+	const query = `INSERT INTO user_roles(user_id, role) VALUES(?, ?)`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(d.insertIgnore(query)))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(userID, role); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RemoveRole removes a role from the user.
+func (d Dialect) RemoveRole(ctx context.Context, tx *sql.Tx, userID, role string) error {
+	const query = `DELETE FROM user_roles WHERE user_id = ? AND role = ?`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(userID, role); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteRoles removes all roles from the user.
+func (d Dialect) DeleteRoles(ctx context.Context, tx *sql.Tx, userID string) error {
+	const query = `DELETE FROM user_roles WHERE user_id = ?`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindInheritance finds all inheritance relations between roles from RDB.
+func (d Dialect) FindInheritance(ctx context.Context, tx *sql.Tx) (entity.RoleInheritance, error) {
+	const query = `SELECT role, inherited_role FROM role_inheritance ORDER BY role, inherited_role`
+	rows, err := tx.QueryContext(ctx, d.rebind(query))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ri := entity.RoleInheritance{}
+	for rows.Next() {
+		var role, inherited string
+		if err := rows.Scan(&role, &inherited); err != nil {
+			return nil, err
+		}
+		ri[role] = append(ri[role], inherited)
+	}
+	return ri, rows.Err()
+}
+
+// AddInheritance makes the role inherit another role.
+func (d Dialect) AddInheritance(ctx context.Context, tx *sql.Tx, role, inherited string) error {
+	const query = `INSERT INTO role_inheritance(role, inherited_role) VALUES(?, ?)`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(d.insertIgnore(query)))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(role, inherited); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RemoveInheritance removes an inheritance relation between roles.
+func (d Dialect) RemoveInheritance(ctx context.Context, tx *sql.Tx, role, inherited string) error {
+	const query = `DELETE FROM role_inheritance WHERE role = ? AND inherited_role = ?`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(role, inherited); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package rdbms
+
+import (
+	"context"
+	"database/sql"
+)
+
+// ExistTombstone returns whether given user ID has been deleted or not.
+func (d Dialect) ExistTombstone(ctx context.Context, tx *sql.Tx, userID string) (bool, error) {
+	const query = `SELECT EXISTS (SELECT 1 FROM deleted_users WHERE user_id = ?)`
+	row := tx.QueryRowContext(ctx, d.rebind(query), userID)
+	var exists bool
+	if err := row.Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// CreateTombstone records given user ID as deleted.
+func (d Dialect) CreateTombstone(ctx context.Context, tx *sql.Tx, userID string) error {
+	const query = `INSERT INTO deleted_users(user_id) VALUES(?)`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(d.insertIgnore(query)))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(userID); err != nil {
+		return err
+	}
+	return nil
+}
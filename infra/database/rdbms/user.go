@@ -0,0 +1,250 @@
+package rdbms
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/domain/repository"
+)
+
+// ExistUser returns given user exists in RDB or not.
+func (d Dialect) ExistUser(ctx context.Context, tx *sql.Tx, userID string) (bool, error) {
+	const query = `SELECT EXISTS (SELECT 1 FROM users WHERE user_id = ?)`
+	row := tx.QueryRowContext(ctx, d.rebind(query), userID)
+	var exists bool
+	if err := row.Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// FindUser finds by given user id from RDB.
+func (d Dialect) FindUser(ctx context.Context, tx *sql.Tx, userID string) (entity.User, error) {
+	const query = `SELECT user_id, password, totp_secret, pending_totp_secret, totp_reset_required, email, email_verified, pending_email, previous_email, phone_number, phone_verified, created_at, status, status_reason, status_expires_at FROM users WHERE user_id = ?`
+	row := tx.QueryRowContext(ctx, d.rebind(query), userID)
+	var u entity.User
+	var expiresAt nullableTime
+	if err := row.Scan(&u.ID, &u.Password, &u.TOTPSecret, &u.PendingTOTPSecret, &u.TOTPResetRequired, &u.Email, &u.EmailVerified, &u.PendingEmail, &u.PreviousEmail, &u.PhoneNumber, &u.PhoneVerified, &u.CreatedAt, &u.Status, &u.StatusReason, &expiresAt); err != nil {
+		return entity.User{}, err
+	}
+	u.TOTPVerified = true
+	u.StatusExpiresAt = expiresAt.Time
+	return u, nil
+}
+
+// UpdateUser updates on RDB.
+// keyID is the ID of the key used to encrypt TOTP secrets.
+func (d Dialect) UpdateUser(ctx context.Context, tx *sql.Tx, u entity.User, keyID string) error {
+	const query = `UPDATE users SET password=?, totp_secret=?, pending_totp_secret=?, secret_key_id=?, totp_reset_required=?, email=?, email_verified=?, pending_email=?, previous_email=?, phone_number=?, phone_verified=?, status=?, status_reason=?, status_expires_at=? WHERE user_id=?`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(u.Password, u.TOTPSecret, u.PendingTOTPSecret, keyID, u.TOTPResetRequired, u.Email, u.EmailVerified, u.PendingEmail, u.PreviousEmail, u.PhoneNumber, u.PhoneVerified, statusOf(u), u.StatusReason, nullTime(u.StatusExpiresAt), u.ID); err != nil {
+		return d.duplicateError(err)
+	}
+	return nil
+}
+
+// CreateUser creates a new user into RDB.
+// keyID is the ID of the key used to encrypt TOTP secrets.
+func (d Dialect) CreateUser(ctx context.Context, tx *sql.Tx, u entity.User, keyID string) error {
+	const query = `INSERT INTO users(user_id, password, totp_secret, pending_totp_secret, secret_key_id, totp_reset_required, email, email_verified, pending_email, previous_email, phone_number, phone_verified, created_at, status, status_reason, status_expires_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(u.ID, u.Password, u.TOTPSecret, u.PendingTOTPSecret, keyID, u.TOTPResetRequired, u.Email, u.EmailVerified, u.PendingEmail, u.PreviousEmail, u.PhoneNumber, u.PhoneVerified, u.CreatedAt, statusOf(u), u.StatusReason, nullTime(u.StatusExpiresAt)); err != nil {
+		return d.duplicateError(err)
+	}
+	return nil
+}
+
+// duplicateError returns repository.ErrUserExists if the error is caused
+// by the primary key, or repository.ErrEmailExists if the error is caused
+// by the unique key of verified email addresses.
+func (d Dialect) duplicateError(err error) error {
+	switch d.duplicateKey(err) {
+	case keyPrimary:
+		return repository.ErrUserExists
+	case keyVerifiedEmail:
+		return repository.ErrEmailExists
+	}
+	return err
+}
+
+// FindUserIDByVerifiedEmail finds the ID of the user whose verified
+// email address is given one from RDB.
+func (d Dialect) FindUserIDByVerifiedEmail(ctx context.Context, tx *sql.Tx, email string) (string, error) {
+	const query = `SELECT user_id FROM users WHERE email = ? AND email_verified AND email <> ''`
+	row := tx.QueryRowContext(ctx, d.rebind(query), email)
+	var userID string
+	if err := row.Scan(&userID); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// statusOf returns the status to be stored.
+// Users stored in RDB have completed the registration,
+// so they are active unless the status is set.
+func statusOf(u entity.User) entity.UserStatus {
+	if u.Status == "" || u.Status == entity.StatusPending {
+		return entity.StatusActive
+	}
+	return u.Status
+}
+
+// userConditions returns the WHERE clause for the filter and its arguments.
+func userConditions(filter repository.UserFilter) (string, []interface{}) {
+	where := ` WHERE user_id > ?`
+	args := []interface{}{filter.After}
+	if filter.ID != "" {
+		where += ` AND user_id = ?`
+		args = append(args, filter.ID)
+	}
+	if filter.IDPrefix != "" {
+		where += ` AND user_id LIKE ?`
+		args = append(args, escapeLike(filter.IDPrefix)+"%")
+	}
+	if filter.Email != "" {
+		where += ` AND email = ?`
+		args = append(args, filter.Email)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		where += ` AND created_at >= ?`
+		args = append(args, filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		where += ` AND created_at < ?`
+		args = append(args, filter.CreatedBefore)
+	}
+	return where, args
+}
+
+// CountUsers counts users matching the filter.
+func (d Dialect) CountUsers(ctx context.Context, tx *sql.Tx, filter repository.UserFilter) (int, error) {
+	where, args := userConditions(filter)
+	row := tx.QueryRowContext(ctx, d.rebind(`SELECT COUNT(*) FROM users`+where), args...)
+	var n int
+	if err := row.Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// ListUsers lists users matching the filter, ordered by user ID.
+// Sensitive fields such as password and TOTP secrets are not filled.
+func (d Dialect) ListUsers(ctx context.Context, tx *sql.Tx, filter repository.UserFilter) ([]entity.User, error) {
+	where, args := userConditions(filter)
+	query := `SELECT user_id, totp_reset_required, email, email_verified, phone_number, phone_verified, created_at, status, status_reason, status_expires_at FROM users` + where + ` ORDER BY user_id`
+	switch {
+	case filter.Limit > 0:
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	case filter.Offset > 0:
+		// MySQL does not allow OFFSET without LIMIT
+		query += ` LIMIT ` + d.noLimit
+	}
+	if filter.Offset > 0 {
+		query += ` OFFSET ?`
+		args = append(args, filter.Offset)
+	}
+	rows, err := tx.QueryContext(ctx, d.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []entity.User
+	for rows.Next() {
+		var u entity.User
+		var expiresAt nullableTime
+		if err := rows.Scan(&u.ID, &u.TOTPResetRequired, &u.Email, &u.EmailVerified, &u.PhoneNumber, &u.PhoneVerified, &u.CreatedAt, &u.Status, &u.StatusReason, &expiresAt); err != nil {
+			return nil, err
+		}
+		u.TOTPVerified = true
+		u.StatusExpiresAt = expiresAt.Time
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// FindUsersAfter finds up to limit users whose ID is greater than after,
+// ordered by user ID. Unlike ListUsers, all fields are filled.
+func (d Dialect) FindUsersAfter(ctx context.Context, tx *sql.Tx, after string, limit int) ([]entity.User, error) {
+	const query = `SELECT user_id, password, totp_secret, pending_totp_secret, totp_reset_required, email, email_verified, pending_email, previous_email, phone_number, phone_verified, created_at, status, status_reason, status_expires_at FROM users WHERE user_id > ? ORDER BY user_id LIMIT ?`
+	rows, err := tx.QueryContext(ctx, d.rebind(query), after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []entity.User
+	for rows.Next() {
+		var u entity.User
+		var expiresAt nullableTime
+		if err := rows.Scan(&u.ID, &u.Password, &u.TOTPSecret, &u.PendingTOTPSecret, &u.TOTPResetRequired, &u.Email, &u.EmailVerified, &u.PendingEmail, &u.PreviousEmail, &u.PhoneNumber, &u.PhoneVerified, &u.CreatedAt, &u.Status, &u.StatusReason, &expiresAt); err != nil {
+			return nil, err
+		}
+		u.TOTPVerified = true
+		u.StatusExpiresAt = expiresAt.Time
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// escapeLike escapes wildcard characters for LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// DeleteUser deletes a user from RDB.
+func (d Dialect) DeleteUser(ctx context.Context, tx *sql.Tx, u entity.User) error {
+	const query = `DELETE FROM users WHERE user_id = ?`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(u.ID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindSecretsNotEncryptedBy finds up to limit users whose secrets are
+// not encrypted by the key of given key ID.
+// Users who have no secrets are not returned.
+// Only the ID and encrypted fields of the users are filled.
+func (d Dialect) FindSecretsNotEncryptedBy(ctx context.Context, tx *sql.Tx, keyID string, limit int) ([]entity.User, error) {
+	const query = `SELECT user_id, totp_secret, pending_totp_secret, previous_email FROM users WHERE secret_key_id <> ? AND (totp_secret <> '' OR pending_totp_secret <> '' OR previous_email <> '') LIMIT ? FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, d.rebind(query), keyID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var users []entity.User
+	for rows.Next() {
+		var u entity.User
+		if err := rows.Scan(&u.ID, &u.TOTPSecret, &u.PendingTOTPSecret, &u.PreviousEmail); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// UpdateSecrets updates encrypted fields of a user.
+func (d Dialect) UpdateSecrets(ctx context.Context, tx *sql.Tx, u entity.User, keyID string) error {
+	const query = `UPDATE users SET totp_secret=?, pending_totp_secret=?, previous_email=?, secret_key_id=? WHERE user_id=?`
+	stmt, err := tx.PrepareContext(ctx, d.rebind(query))
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(u.TOTPSecret, u.PendingTOTPSecret, u.PreviousEmail, keyID, u.ID); err != nil {
+		return err
+	}
+	return nil
+}
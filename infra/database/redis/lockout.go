@@ -0,0 +1,48 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func failuresKey(key string) string { return "lockout:failures:" + key }
+func lockKey(key string) string     { return "lockout:lock:" + key }
+
+// RecordFailure increments the failure counter for given key and returns
+// the new value. The counter expires after ttl from the last failure.
+func RecordFailure(conn redis.Conn, key string, ttl time.Duration) (int, error) {
+	conn.Send("MULTI")
+	conn.Send("INCR", failuresKey(key))
+	conn.Send("PEXPIRE", failuresKey(key), int64(ttl/time.Millisecond))
+	values, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, err
+	}
+	return redis.Int(values[0], nil)
+}
+
+// Lock locks given key for d.
+func Lock(conn redis.Conn, key string, d time.Duration) error {
+	_, err := conn.Do("SET", lockKey(key), 1, "PX", int64(d/time.Millisecond))
+	return err
+}
+
+// LockedFor returns remaining duration of the lock for given key.
+// Zero is returned if the key is not locked.
+func LockedFor(conn redis.Conn, key string) (time.Duration, error) {
+	ttl, err := redis.Int64(conn.Do("PTTL", lockKey(key)))
+	if err != nil {
+		return 0, err
+	}
+	if ttl <= 0 {
+		return 0, nil
+	}
+	return time.Duration(ttl) * time.Millisecond, nil
+}
+
+// ResetLockout deletes the failure counter and the lock for given key.
+func ResetLockout(conn redis.Conn, key string) error {
+	_, err := conn.Do("DEL", failuresKey(key), lockKey(key))
+	return err
+}
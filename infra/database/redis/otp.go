@@ -0,0 +1,38 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// consumeOTPScript deletes the passcode only if it matches,
+// so that a passcode can be used only once.
+var consumeOTPScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("DEL", KEYS[1])
+	return 1
+end
+return 0
+`)
+
+func otpKey(purpose, subject string) string {
+	return "otp:" + purpose + ":" + subject
+}
+
+// SaveOTP stores a one-time passcode with given TTL.
+func SaveOTP(conn redis.Conn, purpose, subject, code string, ttl time.Duration) error {
+	_, err := conn.Do("SET", otpKey(purpose, subject), code, "PX", int64(ttl/time.Millisecond))
+	return err
+}
+
+// ConsumeOTP checks given passcode and deletes it if valid.
+func ConsumeOTP(conn redis.Conn, purpose, subject, code string) (bool, error) {
+	return redis.Bool(consumeOTPScript.Do(conn, otpKey(purpose, subject), code))
+}
+
+// DeleteOTP deletes the passcode.
+func DeleteOTP(conn redis.Conn, purpose, subject string) error {
+	_, err := conn.Do("DEL", otpKey(purpose, subject))
+	return err
+}
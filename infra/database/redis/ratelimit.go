@@ -0,0 +1,23 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// IncrCounter increments the counter for given key and returns the new value.
+// The counter expires after given window from its first increment.
+// The counter is created with its expiry in the same transaction,
+// so that it never remains without expiry.
+func IncrCounter(conn redis.Conn, key string, window time.Duration) (int, error) {
+	key = "ratelimit:" + key
+	conn.Send("MULTI")
+	conn.Send("SET", key, 0, "PX", int64(window/time.Millisecond), "NX")
+	conn.Send("INCR", key)
+	values, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, err
+	}
+	return redis.Int(values[1], nil)
+}
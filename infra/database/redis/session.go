@@ -0,0 +1,60 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/nasa9084/ident/domain/entity"
+)
+
+func authKey(sessid string) string         { return "session_auth:" + sessid }
+func userSessionsKey(userID string) string { return "user_sessions:" + userID }
+
+// AddAuthMethod records the authentication method satisfied in the session.
+// The record expires with the session.
+func AddAuthMethod(conn redis.Conn, sessid string, method entity.AuthMethod, at time.Time) error {
+	if _, err := conn.Do("HSET", authKey(sessid), string(method), at.Unix()); err != nil {
+		return err
+	}
+	ttl, err := redis.Int64(conn.Do("PTTL", "session:"+sessid))
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		_, err = conn.Do("PEXPIRE", authKey(sessid), ttl)
+	}
+	return err
+}
+
+// FindAuthContext finds the authentication methods satisfied in the session.
+func FindAuthContext(conn redis.Conn, sessid string) (entity.AuthContext, error) {
+	m, err := redis.StringMap(conn.Do("HGETALL", authKey(sessid)))
+	if err != nil {
+		return nil, err
+	}
+	ac := entity.AuthContext{}
+	for method, at := range m {
+		unix, err := strconv.ParseInt(at, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ac[entity.AuthMethod(method)] = time.Unix(unix, 0)
+	}
+	return ac, nil
+}
+
+// DeleteUserSessions deletes all sessions of the user
+// with their authentication context.
+func DeleteUserSessions(conn redis.Conn, userID string) error {
+	sessids, err := redis.Strings(conn.Do("SMEMBERS", userSessionsKey(userID)))
+	if err != nil {
+		return err
+	}
+	keys := []interface{}{userSessionsKey(userID)}
+	for _, sessid := range sessids {
+		keys = append(keys, "session:"+sessid, authKey(sessid))
+	}
+	_, err = conn.Do("DEL", keys...)
+	return err
+}
@@ -3,6 +3,8 @@ package redis
 import (
 	"log"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/google/uuid"
@@ -34,15 +36,51 @@ func ExistUser(conn redis.Conn, userID string) (bool, error) {
 	return resp == exist, nil
 }
 
-// CreateSession creates a new session.
-func CreateSession(conn redis.Conn, userID string) (string, error) {
+// createSessionScript creates a session, and extends the expiry of
+// the set of sessions of the user so that it outlives the session.
+var createSessionScript = redis.NewScript(2, `
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+redis.call("SADD", KEYS[2], ARGV[3])
+if redis.call("PTTL", KEYS[2]) < tonumber(ARGV[2]) then
+	redis.call("PEXPIRE", KEYS[2], ARGV[2])
+end
+return 1
+`)
+
+// CreateSession creates a new session which expires after ttl.
+func CreateSession(conn redis.Conn, userID string, ttl time.Duration) (string, error) {
 	sessid := uuid.New().String()
-	if _, err := conn.Do("SET", "session:"+sessid, userID, "EX", 60*10); err != nil {
+	if _, err := createSessionScript.Do(conn, "session:"+sessid, userSessionsKey(userID), userID, int64(ttl/time.Millisecond), sessid); err != nil {
 		return "", err
 	}
 	return sessid, nil
 }
 
+// createUserScript creates the user only if the user ID has not been
+// reserved by another registration, so that concurrent registrations
+// of the same user ID never overwrite each other.
+var createUserScript = redis.NewScript(1, `
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return 0
+end
+redis.call("HMSET", KEYS[1], unpack(ARGV, 2))
+redis.call("PEXPIRE", KEYS[1], ARGV[1])
+return 1
+`)
+
+// CreateUser creates a pending registration which expires after ttl.
+// keyID is the ID of the key used to encrypt TOTP secret.
+// Returns false if the user already exists in Redis.
+func CreateUser(conn redis.Conn, u entity.User, keyID string, ttl time.Duration) (bool, error) {
+	return redis.Bool(createUserScript.Do(conn, "user:"+u.ID,
+		int64(ttl/time.Millisecond),
+		"password", u.Password,
+		"totp_secret", u.TOTPSecret,
+		"secret_key_id", keyID,
+		"created_at", u.CreatedAt.Unix(),
+	))
+}
+
 // FindUser finds by given user id from Redis.
 func FindUser(conn redis.Conn, userID string) (entity.User, error) {
 	userMap, err := redis.StringMap(conn.Do("HGETALL", "user:"+userID))
@@ -57,6 +95,13 @@ func FindUser(conn redis.Conn, userID string) (entity.User, error) {
 		ID:         userID,
 		Password:   userMap["password"],
 		TOTPSecret: userMap["totp_secret"],
+		Email:      userMap["email"],
+
+		PendingTOTPSecret: userMap["pending_totp_secret"],
+		PendingEmail:      userMap["pending_email"],
+		PhoneNumber:       userMap["phone_number"],
+
+		Pending: true,
 	}
 
 	if b, ok := userMap["totp_verified"]; ok {
@@ -66,17 +111,136 @@ func FindUser(conn redis.Conn, userID string) (entity.User, error) {
 		}
 		u.TOTPVerified = totpVerified
 	}
+	if b, ok := userMap["phone_verified"]; ok {
+		phoneVerified, err := strconv.ParseBool(b)
+		if err != nil {
+			return nilUser, err
+		}
+		u.PhoneVerified = phoneVerified
+	}
+	if b, ok := userMap["totp_reset_required"]; ok {
+		totpResetRequired, err := strconv.ParseBool(b)
+		if err != nil {
+			return nilUser, err
+		}
+		u.TOTPResetRequired = totpResetRequired
+	}
+	if b, ok := userMap["email_verified"]; ok {
+		emailVerified, err := strconv.ParseBool(b)
+		if err != nil {
+			return nilUser, err
+		}
+		u.EmailVerified = emailVerified
+	}
+	if b, ok := userMap["awaiting_approval"]; ok {
+		awaitingApproval, err := strconv.ParseBool(b)
+		if err != nil {
+			return nilUser, err
+		}
+		u.AwaitingApproval = awaitingApproval
+	}
+	if t, ok := userMap["created_at"]; ok {
+		createdAt, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			return nilUser, err
+		}
+		u.CreatedAt = time.Unix(createdAt, 0)
+	}
 
 	return u, nil
 }
 
-// UpdateUser updates the user on Redis.
-func UpdateUser(conn redis.Conn, u entity.User) error {
-	_, err := conn.Do("HSET", "user:"+u.ID,
+// userFields returns field-value pairs of the user to be stored.
+func userFields(u entity.User, keyID string) []interface{} {
+	return []interface{}{
 		"password", u.Password,
+		"totp_secret", u.TOTPSecret,
+		"pending_totp_secret", u.PendingTOTPSecret,
+		"secret_key_id", keyID,
 		"email", u.Email,
+		"email_verified", u.EmailVerified,
+		"pending_email", u.PendingEmail,
 		"totp_verified", u.TOTPVerified,
-	)
+		"totp_reset_required", u.TOTPResetRequired,
+		"phone_number", u.PhoneNumber,
+		"phone_verified", u.PhoneVerified,
+	}
+}
+
+// updateUserScript updates the user only if it exists, so that
+// an expired registration is not recreated without expiry.
+var updateUserScript = redis.NewScript(1, `
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("HMSET", KEYS[1], unpack(ARGV))
+return 1
+`)
+
+// UpdateUser updates the user on Redis.
+// keyID is the ID of the key used to encrypt TOTP secrets.
+// Returns false if the user does not exist.
+func UpdateUser(conn redis.Conn, u entity.User, keyID string) (bool, error) {
+	return redis.Bool(updateUserScript.Do(conn, append([]interface{}{"user:" + u.ID}, userFields(u, keyID)...)...))
+}
+
+// markPromotingScript stores the final state of the user with
+// the promotion marker, and makes the user never expire, only if
+// the user still exists.
+var markPromotingScript = redis.NewScript(1, `
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("HMSET", KEYS[1], unpack(ARGV))
+redis.call("PERSIST", KEYS[1])
+return 1
+`)
+
+// MarkPromoting marks the user as being promoted to RDB at given time.
+// The user is stored as it will be stored in RDB, so that the promotion
+// can be completed from Redis even if the process crashes.
+// Returns false if the user does not exist in Redis.
+func MarkPromoting(conn redis.Conn, u entity.User, keyID string, at time.Time) (bool, error) {
+	args := append([]interface{}{"user:" + u.ID}, userFields(u, keyID)...)
+	args = append(args, "created_at", u.CreatedAt.Unix(), "promoting", at.Unix())
+	return redis.Bool(markPromotingScript.Do(conn, args...))
+}
+
+// FindPromotion returns when the user has been marked as being promoted.
+// Zero time is returned if the user is not marked.
+func FindPromotion(conn redis.Conn, userID string) (time.Time, error) {
+	at, err := redis.Int64(conn.Do("HGET", "user:"+userID, "promoting"))
+	if err == redis.ErrNil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(at, 0), nil
+}
+
+// ExpireUser sets the expiry of the pending registration.
+// Returns ErrUserNotFound if the registration has already expired.
+func ExpireUser(conn redis.Conn, userID string, ttl time.Duration) error {
+	const set = 1 // redis::PEXPIRE returns 1 if the timeout was set
+
+	resp, err := redis.Int(conn.Do("PEXPIRE", "user:"+userID, int64(ttl/time.Millisecond)))
+	if err != nil {
+		return err
+	}
+	if resp != set {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// AwaitApproval marks the user as awaiting approval.
+// The user no longer expires, since it may take a while for admin to decide.
+func AwaitApproval(conn redis.Conn, userID string) error {
+	conn.Send("MULTI")
+	conn.Send("HSET", "user:"+userID, "awaiting_approval", true)
+	conn.Send("PERSIST", "user:"+userID)
+	_, err := conn.Do("EXEC")
 	return err
 }
 
@@ -85,3 +249,44 @@ func DeleteUser(conn redis.Conn, u entity.User) error {
 	_, err := conn.Do("DEL", "user:"+u.ID)
 	return err
 }
+
+// ScanUserIDs returns user IDs in Redis.
+func ScanUserIDs(conn redis.Conn) ([]string, error) {
+	var userIDs []string
+	cursor := 0
+	for {
+		values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", "user:*", "COUNT", 100))
+		if err != nil {
+			return nil, err
+		}
+		var keys []string
+		if _, err := redis.Scan(values, &cursor, &keys); err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			userIDs = append(userIDs, strings.TrimPrefix(key, "user:"))
+		}
+		if cursor == 0 {
+			return userIDs, nil
+		}
+	}
+}
+
+// FindSecrets finds encrypted TOTP secrets and the key ID of the user.
+func FindSecrets(conn redis.Conn, userID string) (totpSecret, pendingTOTPSecret, keyID string, err error) {
+	values, err := redis.Strings(conn.Do("HMGET", "user:"+userID, "totp_secret", "pending_totp_secret", "secret_key_id"))
+	if err != nil {
+		return "", "", "", err
+	}
+	return values[0], values[1], values[2], nil
+}
+
+// UpdateSecrets updates encrypted TOTP secrets of the user.
+// Returns false if the user does not exist.
+func UpdateSecrets(conn redis.Conn, userID, totpSecret, pendingTOTPSecret, keyID string) (bool, error) {
+	return redis.Bool(updateUserScript.Do(conn, "user:"+userID,
+		"totp_secret", totpSecret,
+		"pending_totp_secret", pendingTOTPSecret,
+		"secret_key_id", keyID,
+	))
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/infra/database/redis"
+	"github.com/nasa9084/ident/infra/envelope"
+)
+
+const rekeyBatchSize = 100
+
+// Rekey re-encrypts sensitive fields of all users which are not encrypted
+// by the current key of the keyring, both in RDB and Redis.
+// Plaintext values are also encrypted.
+// The keyring must hold old keys to decrypt existing values.
+// Returns the number of re-encrypted users.
+func Rekey(ctx context.Context, rdb *RDB, kvs redigo.Conn, keyring *envelope.Keyring) (int, error) {
+	n, err := rekeyRDB(ctx, rdb, keyring)
+	if err != nil {
+		return n, err
+	}
+	m, err := rekeyRedis(kvs, keyring)
+	return n + m, err
+}
+
+// rekeyRDB re-encrypts users in RDB by batch.
+// Users who have nothing to encrypt are never returned by
+// FindSecretsNotEncryptedBy, so that the loop terminates.
+func rekeyRDB(ctx context.Context, rdb *RDB, keyring *envelope.Keyring) (int, error) {
+	b := rdb.dialect
+	var n int
+	for {
+		tx, err := rdb.db.BeginTx(ctx, nil)
+		if err != nil {
+			return n, err
+		}
+		secrets, err := b.FindSecretsNotEncryptedBy(ctx, tx, keyring.CurrentID(), rekeyBatchSize)
+		if err != nil {
+			tx.Rollback()
+			return n, err
+		}
+		if len(secrets) == 0 {
+			return n, tx.Commit()
+		}
+		for _, s := range secrets {
+			u, keyID, err := reencrypt(keyring, s)
+			if err != nil {
+				tx.Rollback()
+				return n, err
+			}
+			if err := b.UpdateSecrets(ctx, tx, u, keyID); err != nil {
+				tx.Rollback()
+				return n, err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return n, err
+		}
+		n += len(secrets)
+	}
+}
+
+// rekeyRedis re-encrypts pending registrations in Redis.
+// Registrations which expire meanwhile are not recreated.
+func rekeyRedis(kvs redigo.Conn, keyring *envelope.Keyring) (int, error) {
+	userIDs, err := redis.ScanUserIDs(kvs)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, userID := range userIDs {
+		secret, pending, keyID, err := redis.FindSecrets(kvs, userID)
+		if err != nil {
+			return n, err
+		}
+		if keyID == keyring.CurrentID() || (secret == "" && pending == "") {
+			continue
+		}
+		s := entity.User{ID: userID, TOTPSecret: secret, PendingTOTPSecret: pending}
+		if s, keyID, err = reencrypt(keyring, s); err != nil {
+			return n, err
+		}
+		updated, err := redis.UpdateSecrets(kvs, userID, s.TOTPSecret, s.PendingTOTPSecret, keyID)
+		if err != nil {
+			return n, err
+		}
+		if updated {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// reencrypt decrypts sensitive fields of the user, and encrypts them
+// under the current key.
+func reencrypt(keyring *envelope.Keyring, u entity.User) (entity.User, string, error) {
+	u, err := decryptSecrets(keyring, u)
+	if err != nil {
+		return nilUser, "", err
+	}
+	return encryptSecrets(keyring, u)
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/infra/database/rdbms"
+)
+
+type roleRepository struct {
+	RDB     *sql.DB
+	Backend rdbms.Dialect
+}
+
+// NewRoleRepository returns a new RoleRepository instance.
+func NewRoleRepository(rdb *RDB) repository.RoleRepository {
+	return &roleRepository{
+		RDB:     rdb.db,
+		Backend: rdb.dialect,
+	}
+}
+
+// FindRoles returns roles of the user.
+func (repo *roleRepository) FindRoles(ctx context.Context, userID string) ([]string, error) {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return repo.Backend.FindRoles(ctx, tx, userID)
+}
+
+// AddRole grants the role to the user.
+func (repo *roleRepository) AddRole(ctx context.Context, userID, role string) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := repo.Backend.AddRole(ctx, tx, userID, role); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveRole revokes the role from the user.
+func (repo *roleRepository) RemoveRole(ctx context.Context, userID, role string) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := repo.Backend.RemoveRole(ctx, tx, userID, role); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindInheritance returns all inheritance relations between roles.
+func (repo *roleRepository) FindInheritance(ctx context.Context) (entity.RoleInheritance, error) {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return repo.Backend.FindInheritance(ctx, tx)
+}
+
+// AddInheritance makes the role inherit another role.
+func (repo *roleRepository) AddInheritance(ctx context.Context, role, inherited string) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := repo.Backend.AddInheritance(ctx, tx, role, inherited); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveInheritance removes an inheritance relation between roles.
+func (repo *roleRepository) RemoveInheritance(ctx context.Context, role, inherited string) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := repo.Backend.RemoveInheritance(ctx, tx, role, inherited); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
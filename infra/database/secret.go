@@ -0,0 +1,41 @@
+package database
+
+import (
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/infra/envelope"
+)
+
+// encryptSecrets returns a copy of the user whose sensitive fields are
+// encrypted, and the ID of the key used.
+// Sensitive fields are TOTP secrets and the previous email address,
+// which is the recovery material to revert an email change.
+// The key ID is empty if there is nothing to encrypt.
+func encryptSecrets(keyring *envelope.Keyring, u entity.User) (entity.User, string, error) {
+	var encrypted bool
+	for _, field := range []*string{&u.TOTPSecret, &u.PendingTOTPSecret, &u.PreviousEmail} {
+		if *field == "" {
+			continue
+		}
+		var err error
+		if *field, _, err = keyring.Encrypt(*field, u.ID); err != nil {
+			return nilUser, "", err
+		}
+		encrypted = true
+	}
+	if !encrypted {
+		return u, "", nil
+	}
+	return u, keyring.CurrentID(), nil
+}
+
+// decryptSecrets returns a copy of the user whose sensitive fields are
+// decrypted.
+func decryptSecrets(keyring *envelope.Keyring, u entity.User) (entity.User, error) {
+	for _, field := range []*string{&u.TOTPSecret, &u.PendingTOTPSecret, &u.PreviousEmail} {
+		var err error
+		if *field, err = keyring.Decrypt(*field, u.ID); err != nil {
+			return nilUser, err
+		}
+	}
+	return u, nil
+}
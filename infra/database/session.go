@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/infra/database/redis"
+)
+
+type sessionRepository struct {
+	Redis redigo.Conn
+}
+
+// NewSessionRepository returns a new SessionRepository instance.
+func NewSessionRepository(kvs redigo.Conn) repository.SessionRepository {
+	return &sessionRepository{
+		Redis: kvs,
+	}
+}
+
+// AddAuthMethod records the authentication method satisfied in the session.
+func (repo *sessionRepository) AddAuthMethod(ctx context.Context, sessid string, method entity.AuthMethod, at time.Time) error {
+	return redis.AddAuthMethod(repo.Redis, sessid, method, at)
+}
+
+// FindAuthContext returns the authentication methods satisfied in the session.
+func (repo *sessionRepository) FindAuthContext(ctx context.Context, sessid string) (entity.AuthContext, error) {
+	return redis.FindAuthContext(repo.Redis, sessid)
+}
+
+// DeleteUserSessions deletes all sessions of the user.
+func (repo *sessionRepository) DeleteUserSessions(ctx context.Context, userID string) error {
+	return redis.DeleteUserSessions(repo.Redis, userID)
+}
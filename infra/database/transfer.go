@@ -0,0 +1,238 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/generator"
+	"github.com/nasa9084/ident/infra/database/redis"
+	"github.com/nasa9084/ident/infra/envelope"
+	"github.com/nasa9084/ident/util"
+)
+
+// DefaultBatchSize is the default number of users
+// exported or imported in a transaction.
+const DefaultBatchSize = 500
+
+// ConflictStrategy is how ImportUsers handles users which already exist.
+type ConflictStrategy string
+
+// conflict strategies
+const (
+	ConflictSkip      ConflictStrategy = "skip"
+	ConflictOverwrite ConflictStrategy = "overwrite"
+	ConflictFail      ConflictStrategy = "fail"
+)
+
+// ImportOptions is options for ImportUsers.
+type ImportOptions struct {
+	Conflict  ConflictStrategy
+	BatchSize int
+	// DryRun rolls back all transactions instead of committing.
+	DryRun bool
+	// Progress is called after each batch with the total result so far.
+	Progress func(ImportResult)
+	// Policy validates user IDs. User IDs must have been normalized,
+	// since password hashes may be salted with them.
+	Policy entity.UserIDPolicy
+	// EmailPolicy validates and normalizes email addresses.
+	EmailPolicy entity.EmailPolicy
+}
+
+// ImportResult is the number of users processed by ImportUsers.
+type ImportResult struct {
+	Created int
+	Updated int
+	Skipped int
+}
+
+// ExportUsers calls fn with each user who has completed registration,
+// ordered by user ID. Sensitive fields such as TOTP secrets are
+// decrypted, and passwords are given as stored hashes.
+// Returns the number of exported users.
+func ExportUsers(ctx context.Context, rdb *RDB, keyring *envelope.Keyring, batchSize int, fn func(entity.User) error) (int, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	b := rdb.dialect
+	var n int
+	var after string
+	for {
+		tx, err := rdb.db.BeginTx(ctx, nil)
+		if err != nil {
+			return n, err
+		}
+		users, err := b.FindUsersAfter(ctx, tx, after, batchSize)
+		tx.Rollback()
+		if err != nil {
+			return n, err
+		}
+		for _, u := range users {
+			if u, err = decryptSecrets(keyring, u); err != nil {
+				return n, err
+			}
+			if err := fn(u); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if len(users) < batchSize {
+			return n, nil
+		}
+		after = users[len(users)-1].ID
+	}
+}
+
+// ImportUsers imports users returned by next into RDB until next
+// returns io.EOF. Users are imported as they have completed registration,
+// and sensitive fields are encrypted using the keyring.
+// Each batch is imported in a transaction. If an error occurs,
+// the batch is rolled back but preceding batches remain committed.
+// Deleted user IDs and pending registrations are conflicts which
+// are never overwritten.
+func ImportUsers(ctx context.Context, rdb *RDB, kvs redigo.Conn, keyring *envelope.Keyring, next func() (entity.User, error), opts ImportOptions) (ImportResult, error) {
+	var result ImportResult
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	for {
+		batch, done, err := readBatch(next, opts.BatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(batch) > 0 {
+			r, err := importBatch(ctx, rdb, kvs, keyring, batch, opts)
+			if err != nil {
+				return result, err
+			}
+			result.Created += r.Created
+			result.Updated += r.Updated
+			result.Skipped += r.Skipped
+			if opts.Progress != nil {
+				opts.Progress(result)
+			}
+		}
+		if done {
+			return result, nil
+		}
+	}
+}
+
+func readBatch(next func() (entity.User, error), size int) ([]entity.User, bool, error) {
+	var batch []entity.User
+	for len(batch) < size {
+		u, err := next()
+		if err == io.EOF {
+			return batch, true, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		batch = append(batch, u)
+	}
+	return batch, false, nil
+}
+
+func importBatch(ctx context.Context, rdb *RDB, kvs redigo.Conn, keyring *envelope.Keyring, batch []entity.User, opts ImportOptions) (ImportResult, error) {
+	var result ImportResult
+	b := rdb.dialect
+	tx, err := rdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	for _, u := range batch {
+		u, err := validateImport(u, opts)
+		if err != nil {
+			tx.Rollback()
+			return result, err
+		}
+		pending, err := redis.ExistUser(kvs, u.ID)
+		if err != nil {
+			tx.Rollback()
+			return result, err
+		}
+		deleted, err := b.ExistTombstone(ctx, tx, u.ID)
+		if err != nil {
+			tx.Rollback()
+			return result, err
+		}
+		exists, err := b.ExistUser(ctx, tx, u.ID)
+		if err != nil {
+			tx.Rollback()
+			return result, err
+		}
+		if pending || deleted || exists {
+			if opts.Conflict == ConflictFail {
+				tx.Rollback()
+				return result, fmt.Errorf("user already exists: %s", u.ID)
+			}
+			if opts.Conflict != ConflictOverwrite || !exists {
+				result.Skipped++
+				continue
+			}
+		}
+
+		if u.CreatedAt.IsZero() {
+			u.CreatedAt = generator.TimeFunc()
+		}
+		u, keyID, err := encryptSecrets(keyring, u)
+		if err != nil {
+			tx.Rollback()
+			return result, err
+		}
+		if exists {
+			err = b.UpdateUser(ctx, tx, u, keyID)
+		} else {
+			err = b.CreateUser(ctx, tx, u, keyID)
+		}
+		if err != nil {
+			tx.Rollback()
+			return ImportResult{}, err
+		}
+		if exists {
+			result.Updated++
+		} else {
+			result.Created++
+		}
+	}
+	if opts.DryRun {
+		return result, tx.Rollback()
+	}
+	if err := tx.Commit(); err != nil {
+		return ImportResult{}, err
+	}
+	return result, nil
+}
+
+// validateImport validates the user to be imported,
+// and returns the user whose email address is normalized.
+func validateImport(u entity.User, opts ImportOptions) (entity.User, error) {
+	normalized, err := opts.Policy.Normalize(u.ID)
+	if err != nil {
+		return u, fmt.Errorf("%s: %s", err, u.ID)
+	}
+	if normalized != u.ID {
+		return u, fmt.Errorf("user ID is not normalized: %s (normalized: %s)", u.ID, normalized)
+	}
+	if u.Password == "" {
+		return u, fmt.Errorf("password hash is required: %s", u.ID)
+	}
+	if err := util.CheckHash(u.Password); err != nil {
+		return u, fmt.Errorf("%s: %s", err, u.ID)
+	}
+	if u.TOTPSecret == "" && !u.TOTPResetRequired {
+		return u, fmt.Errorf("TOTP secret is required unless TOTP reset is required: %s", u.ID)
+	}
+	if u.Status == entity.StatusPending {
+		return u, fmt.Errorf("pending user cannot be imported: %s", u.ID)
+	}
+	if u.Email != "" {
+		if u.Email, err = opts.EmailPolicy.Normalize(u.Email); err != nil {
+			return u, fmt.Errorf("%s: %s", err, u.ID)
+		}
+	}
+	return u, nil
+}
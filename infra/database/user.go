@@ -3,66 +3,144 @@ package database
 import (
 	"context"
 	"database/sql"
+	"sort"
+	"time"
 
 	redigo "github.com/gomodule/redigo/redis"
-	"github.com/google/uuid"
 	"github.com/nasa9084/ident/domain/entity"
 	"github.com/nasa9084/ident/domain/repository"
 	"github.com/nasa9084/ident/generator"
-	"github.com/nasa9084/ident/infra/database/mysql"
+	"github.com/nasa9084/ident/infra/database/rdbms"
 	"github.com/nasa9084/ident/infra/database/redis"
+	"github.com/nasa9084/ident/infra/envelope"
 	"github.com/nasa9084/ident/util"
 )
 
 var nilUser = entity.User{}
 
 type userRepository struct {
-	MySQL *sql.DB
-	Redis redigo.Conn
+	RDB     *sql.DB
+	Backend rdbms.Dialect
+	Redis   redigo.Conn
+	Keyring *envelope.Keyring
+	Policy  entity.UserIDPolicy
 }
 
 // NewUserRepository returns a new UserRepo instance.
-func NewUserRepository(rdb *sql.DB, kvs redigo.Conn) repository.UserRepository {
+// TOTP secrets and previous email addresses are encrypted using given
+// keyring before stored.
+// If keyring is nil, they are stored in plaintext.
+// User IDs are normalized by given policy.
+func NewUserRepository(rdb *RDB, kvs redigo.Conn, keyring *envelope.Keyring, policy entity.UserIDPolicy) repository.UserRepository {
 	return &userRepository{
-		MySQL: rdb,
-		Redis: kvs,
+		RDB:     rdb.db,
+		Backend: rdb.dialect,
+		Redis:   kvs,
+		Keyring: keyring,
+		Policy:  policy,
 	}
 }
 
+// encrypt returns a copy of the user whose sensitive fields are encrypted,
+// and the ID of the key used.
+func (repo *userRepository) encrypt(u entity.User) (entity.User, string, error) {
+	return encryptSecrets(repo.Keyring, u)
+}
+
+// decrypt returns a copy of the user whose sensitive fields are decrypted.
+func (repo *userRepository) decrypt(u entity.User) (entity.User, error) {
+	return decryptSecrets(repo.Keyring, u)
+}
+
 // ExistsUser returns whether the user id has been used or not.
 func (repo *userRepository) ExistsUser(ctx context.Context, userID string) (bool, error) {
+	userID, err := repo.Policy.Normalize(userID)
+	if err != nil {
+		return false, err
+	}
 	existsInRedis, err := redis.ExistUser(repo.Redis, userID)
 	if err != nil {
 		return false, err
 	}
-	tx, err := repo.MySQL.BeginTx(ctx, nil)
+	tx, err := repo.RDB.BeginTx(ctx, nil)
 	if err != nil {
 		return false, err
 	}
-	existsInMySQL, err := mysql.ExistUser(ctx, tx, userID)
+	defer tx.Rollback()
+	existsInRDB, err := repo.Backend.ExistUser(ctx, tx, userID)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := repo.Backend.ExistTombstone(ctx, tx, userID)
 	if err != nil {
 		return false, err
 	}
 
-	return existsInRedis || existsInMySQL, nil
+	return existsInRedis || existsInRDB || deleted, nil
 }
 
 // CreateUser creates a new user into Redis and returns the session id.
-// The user is temporary user.
-func (repo *userRepository) CreateUser(ctx context.Context, userID, password string) (string, error) {
-	secret := generator.NewSecret()
-	userKey := "user:" + userID
-
-	repo.Redis.Send("MULTI")
-	repo.Redis.Send("HMSET", userKey,
-		"password", util.Hash(password, userID),
-		"totp_secret", secret,
-	)
-	repo.Redis.Send("EXPIRE", userKey, 60*10)
-	if _, err := repo.Redis.Do("EXEC"); err != nil {
+// The user is temporary user, which expires after ttl with the session.
+// The user ID is normalized, and must be allowed by the policy.
+// repository.ErrUserExists is returned if the user ID has been used
+// or reserved by another pending registration.
+func (repo *userRepository) CreateUser(ctx context.Context, userID, password string, ttl time.Duration) (string, error) {
+	userID, err := repo.Policy.NormalizeNew(userID)
+	if err != nil {
+		return "", err
+	}
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	deleted, err := repo.Backend.ExistTombstone(ctx, tx, userID)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	exists, err := repo.Backend.ExistUser(ctx, tx, userID)
+	tx.Rollback()
+	if err != nil {
+		return "", err
+	}
+	if deleted {
+		return "", repository.ErrUserDeleted
+	}
+	if exists {
+		return "", repository.ErrUserExists
+	}
+	u := entity.User{
+		ID:         userID,
+		Password:   util.Hash(password, userID),
+		TOTPSecret: generator.NewSecret(),
+		CreatedAt:  generator.TimeFunc(),
+	}
+	u, keyID, err := repo.encrypt(u)
+	if err != nil {
+		return "", err
+	}
+	// reserves the user ID atomically. a user verified after the check
+	// above is rejected by the primary key on Verify.
+	created, err := redis.CreateUser(repo.Redis, u, keyID, ttl)
+	if err != nil {
 		return "", err
 	}
-	return redis.CreateSession(repo.Redis, userID)
+	if !created {
+		return "", repository.ErrUserExists
+	}
+	return redis.CreateSession(repo.Redis, userID, ttl)
+}
+
+// ResumeRegistration extends the expiry of the pending registration
+// to ttl from now, and returns a new session id which expires with it.
+func (repo *userRepository) ResumeRegistration(ctx context.Context, u entity.User, ttl time.Duration) (string, error) {
+	if err := redis.ExpireUser(repo.Redis, u.ID, ttl); err != nil {
+		if err == redis.ErrUserNotFound {
+			return "", ErrUserNotFound
+		}
+		return "", err
+	}
+	return redis.CreateSession(repo.Redis, u.ID, ttl)
 }
 
 // FindUserBySessionID finds user using user id associated with given session id.
@@ -79,44 +157,81 @@ func (repo *userRepository) findSession(sessid string) (string, error) {
 }
 
 // FindUserByID finds user using given user id.
+// The user ID is normalized, and sql.ErrNoRows is returned if it cannot be.
 func (repo *userRepository) FindUserByID(ctx context.Context, userID string) (entity.User, error) {
-	var u entity.User
-	var err error
-	u, err = redis.FindUser(repo.Redis, userID)
+	userID, err := repo.Policy.Normalize(userID)
+	if err != nil {
+		return nilUser, sql.ErrNoRows
+	}
+	u, err := redis.FindUser(repo.Redis, userID)
 	if err != nil && err != redis.ErrUserNotFound {
 		return nilUser, err
 	}
 	if u != nilUser {
-		return u, nil
+		return repo.decrypt(u)
+	}
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nilUser, err
+	}
+	defer tx.Rollback()
+	u, err = repo.Backend.FindUser(ctx, tx, userID)
+	if err != nil {
+		return nilUser, err
 	}
-	tx, err := repo.MySQL.BeginTx(ctx, nil)
+	return repo.decrypt(u)
+}
+
+// FindUserByEmail finds the user whose verified email address is given one.
+// The email address is normalized, and sql.ErrNoRows is returned
+// if it cannot be.
+func (repo *userRepository) FindUserByEmail(ctx context.Context, email string) (entity.User, error) {
+	email, err := entity.ParseEmail(email)
+	if err != nil {
+		return nilUser, sql.ErrNoRows
+	}
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nilUser, err
+	}
+	defer tx.Rollback()
+	userID, err := repo.Backend.FindUserIDByVerifiedEmail(ctx, tx, email)
 	if err != nil {
-		return u, nil
+		return nilUser, err
+	}
+	u, err := repo.Backend.FindUser(ctx, tx, userID)
+	if err != nil {
+		return nilUser, err
 	}
-	return mysql.FindUser(ctx, tx, userID)
+	return repo.decrypt(u)
 }
 
 // UpdateUser updates user information.
 func (repo *userRepository) UpdateUser(ctx context.Context, u entity.User) error {
-	inRedis, err := redis.ExistUser(repo.Redis, u.ID)
+	u, keyID, err := repo.encrypt(u)
+	if err != nil {
+		return err
+	}
+	inRedis, err := redis.UpdateUser(repo.Redis, u, keyID)
 	if err != nil {
 		return err
 	}
 	if inRedis {
-		return redis.UpdateUser(repo.Redis, u)
+		return nil
 	}
-	tx, err := repo.MySQL.BeginTx(ctx, nil)
+	tx, err := repo.RDB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	inMySQL, err := mysql.ExistUser(ctx, tx, u.ID)
+	defer tx.Rollback()
+	inRDB, err := repo.Backend.ExistUser(ctx, tx, u.ID)
 	if err != nil {
 		return err
 	}
-	if !inMySQL {
+	if !inRDB {
 		return ErrUserNotFound
 	}
-	if err := mysql.UpdateUser(ctx, tx, u); err != nil {
+	if err := repo.Backend.UpdateUser(ctx, tx, u, keyID); err != nil {
 		return err
 	}
 
@@ -124,47 +239,196 @@ func (repo *userRepository) UpdateUser(ctx context.Context, u entity.User) error
 }
 
 // Verify makes user non-temporary.
+// The user is promoted from Redis to RDB in retryable steps, so that
+// calling Verify again completes the promotion interrupted by an error.
+// repository.ErrUserExists is returned if the user ID has been used
+// by another user, and the registration is deleted.
 func (repo *userRepository) Verify(ctx context.Context, u entity.User) error {
-	exists, err := redis.ExistUser(repo.Redis, u.ID)
+	u, keyID, err := repo.encrypt(u)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return ErrUserNotFound
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = generator.TimeFunc()
 	}
-	tx, err := repo.MySQL.BeginTx(ctx, nil)
+	marked, err := redis.MarkPromoting(repo.Redis, u, keyID, generator.TimeFunc())
 	if err != nil {
 		return err
 	}
-	if err := mysql.CreateUser(ctx, tx, u); err != nil {
+	if !marked {
+		return ErrUserNotFound
+	}
+	if _, err := insertPromoted(ctx, repo.RDB, repo.Backend, u, keyID, false); err != nil {
+		if err == repository.ErrUserExists {
+			if derr := redis.DeleteUser(repo.Redis, u); derr != nil {
+				return derr
+			}
+		}
 		return err
 	}
-	if err := redis.DeleteUser(repo.Redis, u); err != nil {
+	return redis.DeleteUser(repo.Redis, u)
+}
+
+// AwaitApproval marks the pending registration as awaiting approval.
+func (repo *userRepository) AwaitApproval(ctx context.Context, u entity.User) error {
+	exists, err := redis.ExistUser(repo.Redis, u.ID)
+	if err != nil {
 		return err
 	}
-	return tx.Commit()
+	if !exists {
+		return ErrUserNotFound
+	}
+	return redis.AwaitApproval(repo.Redis, u.ID)
 }
 
-// DeleteUser deletes user from Redis and MySQL.
-func (repo *userRepository) DeleteUser(ctx context.Context, u entity.User) error {
-	if err := redis.DeleteUser(repo.Redis, u); err != nil {
+// DeleteRegistration deletes the pending registration from Redis.
+func (repo *userRepository) DeleteRegistration(ctx context.Context, u entity.User) error {
+	exists, err := redis.ExistUser(repo.Redis, u.ID)
+	if err != nil {
 		return err
 	}
-	tx, err := repo.MySQL.BeginTx(ctx, nil)
+	if !exists {
+		return ErrUserNotFound
+	}
+	return redis.DeleteUser(repo.Redis, u)
+}
+
+// DeleteUser deletes user from Redis and RDB, and leaves a tombstone
+// so that the user ID cannot be registered again.
+func (repo *userRepository) DeleteUser(ctx context.Context, u entity.User) error {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	if err := mysql.DeleteUser(ctx, tx, u); err != nil {
+	if err := repo.Backend.DeleteUser(ctx, tx, u); err != nil {
+		tx.Rollback()
 		return err
 	}
-	return tx.Commit()
+	if err := repo.Backend.DeleteRoles(ctx, tx, u.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := repo.Backend.DeleteProfile(ctx, tx, u.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := repo.Backend.DeleteMemberships(ctx, tx, u.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := repo.Backend.CreateTombstone(ctx, tx, u.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return redis.DeleteUser(repo.Redis, u)
 }
 
-func (repo *userRepository) CreateSession(u entity.User) (string, error) {
-	sessid := uuid.New().String()
-	_, err := repo.Redis.Do("SET", "session:"+sessid, u.ID)
+// ListUsers lists users including pending registrations in Redis,
+// ordered by user ID.
+// Sensitive fields such as password and TOTP secrets are not filled.
+func (repo *userRepository) ListUsers(ctx context.Context, filter repository.UserFilter) ([]entity.User, error) {
+	if filter.Verified != nil && *filter.Verified {
+		// paginated by RDB
+		return repo.listVerifiedUsers(ctx, filter)
+	}
+	users, err := repo.listPendingUsers(filter)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if filter.Verified == nil {
+		// pagination is applied after merged with pending registrations
+		verifiedFilter := filter
+		verifiedFilter.Offset = 0
+		if filter.Limit > 0 {
+			verifiedFilter.Limit = filter.Offset + filter.Limit
+		}
+		verified, err := repo.listVerifiedUsers(ctx, verifiedFilter)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, verified...)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	if filter.Offset >= len(users) {
+		return nil, nil
+	}
+	users = users[filter.Offset:]
+	if filter.Limit > 0 && len(users) > filter.Limit {
+		users = users[:filter.Limit]
+	}
+	return users, nil
+}
+
+// CountUsers counts users including pending registrations in Redis.
+func (repo *userRepository) CountUsers(ctx context.Context, filter repository.UserFilter) (int, error) {
+	var n int
+	if filter.Verified == nil || !*filter.Verified {
+		users, err := repo.listPendingUsers(filter)
+		if err != nil {
+			return 0, err
+		}
+		n += len(users)
+	}
+	if filter.Verified == nil || *filter.Verified {
+		tx, err := repo.RDB.BeginTx(ctx, nil)
+		if err != nil {
+			return 0, err
+		}
+		defer tx.Rollback()
+		verified, err := repo.Backend.CountUsers(ctx, tx, filter)
+		if err != nil {
+			return 0, err
+		}
+		n += verified
 	}
-	return sessid, nil
+	return n, nil
+}
+
+// listPendingUsers lists pending registrations matching the filter
+// without pagination.
+func (repo *userRepository) listPendingUsers(filter repository.UserFilter) ([]entity.User, error) {
+	userIDs, err := redis.ScanUserIDs(repo.Redis)
+	if err != nil {
+		return nil, err
+	}
+	var users []entity.User
+	for _, userID := range userIDs {
+		u, err := redis.FindUser(repo.Redis, userID)
+		if err == redis.ErrUserNotFound {
+			// expired while listing
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if filter.Match(u) {
+			users = append(users, sanitize(u))
+		}
+	}
+	return users, nil
+}
+
+func (repo *userRepository) listVerifiedUsers(ctx context.Context, filter repository.UserFilter) ([]entity.User, error) {
+	tx, err := repo.RDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return repo.Backend.ListUsers(ctx, tx, filter)
+}
+
+// sanitize returns a copy of the user without sensitive fields.
+func sanitize(u entity.User) entity.User {
+	u.Password = ""
+	u.TOTPSecret = ""
+	u.PendingTOTPSecret = ""
+	return u
+}
+
+// CreateSession creates a login session which expires after ttl.
+func (repo *userRepository) CreateSession(u entity.User, ttl time.Duration) (string, error) {
+	return redis.CreateSession(repo.Redis, u.ID, ttl)
 }
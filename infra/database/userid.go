@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/infra/database/redis"
+)
+
+// UserIDConflict is a set of stored user IDs which are not found by
+// themselves once the policy is applied.
+type UserIDConflict struct {
+	// Normalized is the user ID normalized by the policy.
+	// empty if the user IDs cannot be normalized, and in lower case if
+	// the normalized user IDs differ only in case.
+	Normalized string
+	// UserIDs are the stored user IDs normalized to Normalized.
+	// More than one user IDs collide with each other.
+	UserIDs []string
+}
+
+// CheckUserIDs finds stored user IDs which are not normalized by
+// the policy, both in RDB and Redis. This should be run before
+// changing the policy, since such users cannot be found by their IDs.
+// User IDs which differ only in case collide even if the policy preserves
+// case, since RDB compares user IDs case-insensitively.
+// Returns conflicts ordered by normalized user ID.
+func CheckUserIDs(ctx context.Context, rdb *RDB, kvs redigo.Conn, policy entity.UserIDPolicy) ([]UserIDConflict, error) {
+	userIDs, err := redis.ScanUserIDs(kvs)
+	if err != nil {
+		return nil, err
+	}
+	b := rdb.dialect
+	filter := repository.UserFilter{Limit: DefaultBatchSize}
+	for {
+		tx, err := rdb.db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		users, err := b.ListUsers(ctx, tx, filter)
+		tx.Rollback()
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			userIDs = append(userIDs, u.ID)
+		}
+		if len(users) < filter.Limit {
+			break
+		}
+		filter.After = users[len(users)-1].ID
+	}
+
+	normalizedOf := map[string]string{}
+	byFolded := map[string][]string{}
+	for _, userID := range userIDs {
+		if _, ok := normalizedOf[userID]; ok {
+			// being promoted from Redis to RDB
+			continue
+		}
+		normalized, err := policy.Normalize(userID)
+		if err != nil {
+			normalized = ""
+		}
+		normalizedOf[userID] = normalized
+		folded := strings.ToLower(normalized)
+		byFolded[folded] = append(byFolded[folded], userID)
+	}
+	var conflicts []UserIDConflict
+	for folded, ids := range byFolded {
+		if len(ids) == 1 && ids[0] == normalizedOf[ids[0]] {
+			continue
+		}
+		sort.Strings(ids)
+		normalized := normalizedOf[ids[0]]
+		if len(ids) > 1 {
+			normalized = folded
+		}
+		conflicts = append(conflicts, UserIDConflict{Normalized: normalized, UserIDs: ids})
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Normalized < conflicts[j].Normalized })
+	return conflicts, nil
+}
@@ -0,0 +1,87 @@
+package infra
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/util"
+)
+
+// DefaultEmailVerifyTTL is how long verification links are valid by default.
+const DefaultEmailVerifyTTL = 24 * time.Hour
+
+// EmailConfig holds configuration for email addresses which users can set,
+// and their verification.
+// This struct can also be used for go-flags.
+type EmailConfig struct {
+	AllowedDomains []string `long:"email-allowed-domain" env:"EMAIL_ALLOWED_DOMAINS" env-delim:"," value-name:"EMAIL_ALLOWED_DOMAINS" description:"domains of email addresses allowed. any domain is allowed if empty"`
+	DeniedDomains  []string `long:"email-denied-domain" env:"EMAIL_DENIED_DOMAINS" env-delim:"," value-name:"EMAIL_DENIED_DOMAINS" description:"domains of email addresses denied"`
+	DisposableFile string   `long:"email-disposable-domains" env:"EMAIL_DISPOSABLE_DOMAINS" value-name:"EMAIL_DISPOSABLE_DOMAINS" description:"file which lists domains of disposable email services, one per line"`
+
+	VerifyTTL      time.Duration `long:"email-verify-ttl" env:"EMAIL_VERIFY_TTL" value-name:"EMAIL_VERIFY_TTL" default:"24h" description:"verification links expire after this duration"`
+	ResendCooldown time.Duration `long:"email-resend-cooldown" env:"EMAIL_RESEND_COOLDOWN" value-name:"EMAIL_RESEND_COOLDOWN" default:"1m" description:"minimum interval to resend verification mail"`
+}
+
+// Policy returns the email policy, loading the disposable domain list
+// from configured file.
+func (cfg EmailConfig) Policy() (entity.EmailPolicy, error) {
+	var policy entity.EmailPolicy
+	var err error
+	if policy.AllowedDomains, err = toASCIIDomains(cfg.AllowedDomains); err != nil {
+		return policy, err
+	}
+	if policy.DeniedDomains, err = toASCIIDomains(cfg.DeniedDomains); err != nil {
+		return policy, err
+	}
+	if cfg.DisposableFile != "" {
+		if policy.DisposableDomains, err = loadDomainList(cfg.DisposableFile); err != nil {
+			return policy, err
+		}
+	}
+	return policy, nil
+}
+
+func toASCIIDomains(domains []string) ([]string, error) {
+	var converted []string
+	for _, domain := range domains {
+		domain = strings.TrimSpace(domain)
+		if domain == "" {
+			continue
+		}
+		d, err := util.ToASCIIDomain(domain)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", domain, err)
+		}
+		converted = append(converted, d)
+	}
+	return converted, nil
+}
+
+// loadDomainList loads domains listed one per line in the file.
+// Empty lines and lines starting with # are ignored.
+func loadDomainList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var domains []string
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		d, err := util.ToASCIIDomain(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, line, err)
+		}
+		domains = append(domains, d)
+	}
+	return domains, sc.Err()
+}
@@ -4,20 +4,71 @@ import (
 	"crypto/ecdsa"
 	"database/sql"
 	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/gomodule/redigo/redis"
+	"github.com/nasa9084/ident/domain/entity"
 	"github.com/nasa9084/ident/domain/repository"
 	"github.com/nasa9084/ident/domain/service"
 	"github.com/nasa9084/ident/infra/database"
+	"github.com/nasa9084/ident/infra/database/rdbms"
+	"github.com/nasa9084/ident/infra/envelope"
 	"github.com/nasa9084/ident/infra/mail"
+	"github.com/nasa9084/ident/infra/sms"
 )
 
 // Config is wrapper for all configurations.
 type Config struct {
-	MySQL MySQLConfig
-	Redis RedisConfig
-	Mail  MailConfig
+	Storage StorageConfig
+	Redis   RedisConfig
+	Mail    MailConfig
+	SMS     SMSConfig
+	Admin   AdminConfig
+	Key     KeyConfig
+	TOTP    TOTPConfig
+	Lockout LockoutConfig
+	Auth    AuthConfig
+	Profile ProfileConfig
+	UserID  UserIDConfig
+	Email   EmailConfig
+
+	Registration RegistrationConfig
+}
+
+// StorageConfig holds configurations for the relational database which
+// holds users who have completed registration.
+// This struct can also be used for go-flags.
+type StorageConfig struct {
+	Driver string `long:"storage-driver" env:"STORAGE_DRIVER" value-name:"STORAGE_DRIVER" choice:"mysql" choice:"postgres" default:"mysql"`
+
+	MySQL    MySQLConfig
+	Postgres PostgresConfig
+}
+
+// Open opens the database of the configured driver,
+// which is queried in the SQL dialect of the driver.
+func (cfg StorageConfig) Open() (*database.RDB, error) {
+	var db *sql.DB
+	var dialect rdbms.Dialect
+	var err error
+	switch cfg.Driver {
+	case "mysql", "":
+		db, err = OpenMySQL(cfg.MySQL)
+		dialect = rdbms.MySQL
+	case "postgres":
+		db, err = OpenPostgres(cfg.Postgres)
+		dialect = rdbms.PostgreSQL
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.Driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return database.NewRDB(db, dialect), nil
 }
 
 // MySQLConfig holds configurations for connect to MySQL server.
@@ -29,6 +80,16 @@ type MySQLConfig struct {
 	DBName   string `long:"mysql-db" env:"MYSQL_DB" value-name:"MYSQL_DB" default:"ident"`
 }
 
+// PostgresConfig holds configurations for connect to PostgreSQL server.
+// This struct can also be used for go-flags.
+type PostgresConfig struct {
+	Addr     string `long:"postgres-addr" env:"POSTGRES_ADDR" value-name:"POSTGRES_ADDR" default:"127.0.0.1:5432"`
+	User     string `long:"postgres-user" env:"POSTGRES_USER" value-name:"POSTGRES_USER" default:"postgres"`
+	Password string `long:"postgres-password" env:"POSTGRES_PASSWORD" value-name:"POSTGRES_PASSWORD" default:""`
+	DBName   string `long:"postgres-db" env:"POSTGRES_DB" value-name:"POSTGRES_DB" default:"ident"`
+	SSLMode  string `long:"postgres-sslmode" env:"POSTGRES_SSLMODE" value-name:"POSTGRES_SSLMODE" choice:"disable" choice:"require" choice:"verify-ca" choice:"verify-full" default:"disable"`
+}
+
 // RedisConfig holds configurations for connect to Redis server.
 // This struct can also be used for go-flags.
 type RedisConfig struct {
@@ -42,18 +103,192 @@ type MailConfig struct {
 	APIKey   string `long:"sg-apikey" env:"SENDGRID_APIKEY" value-name:"SENDGRID_APIKEY" required:"yes"`
 }
 
+// LockoutConfig holds configuration for brute-force protection
+// on authentication. Zero threshold disables lockout.
+// This struct can also be used for go-flags.
+type LockoutConfig struct {
+	UserThreshold int           `long:"lockout-user-threshold" env:"LOCKOUT_USER_THRESHOLD" value-name:"LOCKOUT_USER_THRESHOLD" default:"5" description:"failures per user before lockout"`
+	IPThreshold   int           `long:"lockout-ip-threshold" env:"LOCKOUT_IP_THRESHOLD" value-name:"LOCKOUT_IP_THRESHOLD" default:"20" description:"failures per client IP before lockout"`
+	Window        time.Duration `long:"lockout-window" env:"LOCKOUT_WINDOW" value-name:"LOCKOUT_WINDOW" default:"15m" description:"failures are forgotten after this duration from the last failure"`
+	BaseDelay     time.Duration `long:"lockout-base-delay" env:"LOCKOUT_BASE_DELAY" value-name:"LOCKOUT_BASE_DELAY" default:"1m" description:"first lockout duration, doubled on each further failure"`
+	MaxDelay      time.Duration `long:"lockout-max-delay" env:"LOCKOUT_MAX_DELAY" value-name:"LOCKOUT_MAX_DELAY" default:"1h" description:"maximum lockout duration"`
+
+	TrustForwardedFor bool `long:"trust-x-forwarded-for" env:"TRUST_X_FORWARDED_FOR" description:"use the last address of X-Forwarded-For header as client IP"`
+}
+
+// AuthConfig holds configuration for authentication flow.
+// This struct can also be used for go-flags.
+type AuthConfig struct {
+	Flow string `long:"auth-flow" env:"AUTH_FLOW" value-name:"AUTH_FLOW" default:"otp|sms,pwd" description:"authentication steps separated by comma. alternative methods in a step are separated by |"`
+
+	Audiences []string `long:"token-audience" env:"TOKEN_AUDIENCES" env-delim:"," value-name:"TOKEN_AUDIENCES" description:"audiences which clients can request tokens for. any audience is allowed if empty"`
+
+	SessionTTL time.Duration `long:"session-ttl" env:"SESSION_TTL" value-name:"SESSION_TTL" default:"24h" description:"login sessions and their authentication context expire after this duration"`
+}
+
+// DefaultSessionTTL is how long login sessions are kept by default.
+const DefaultSessionTTL = 24 * time.Hour
+
+// UserIDConfig holds configuration for normalization and validation
+// of user IDs.
+// This struct can also be used for go-flags.
+type UserIDConfig struct {
+	Profile   string   `long:"user-id-profile" env:"USER_ID_PROFILE" value-name:"USER_ID_PROFILE" choice:"casemapped" choice:"casepreserved" choice:"none" default:"casemapped" description:"PRECIS profile applied to user IDs. run ident users check-ids before changing this"`
+	MinLength int      `long:"user-id-min-length" env:"USER_ID_MIN_LENGTH" value-name:"USER_ID_MIN_LENGTH" default:"1"`
+	MaxLength int      `long:"user-id-max-length" env:"USER_ID_MAX_LENGTH" value-name:"USER_ID_MAX_LENGTH" default:"64"`
+	ASCIIOnly bool     `long:"user-id-ascii-only" env:"USER_ID_ASCII_ONLY" description:"allow only ASCII letters and digits in user IDs"`
+	Symbols   string   `long:"user-id-symbols" env:"USER_ID_SYMBOLS" value-name:"USER_ID_SYMBOLS" default:"._-+" description:"symbols allowed in user IDs in addition to letters and digits. @ should not be allowed since logins containing @ are regarded as email addresses"`
+	Reserved  []string `long:"user-id-reserved" env:"USER_ID_RESERVED" env-delim:"," value-name:"USER_ID_RESERVED" description:"user IDs which cannot be registered. defaults to built-in names such as admin and root"`
+}
+
+// Policy returns the user ID policy.
+func (cfg UserIDConfig) Policy() (entity.UserIDPolicy, error) {
+	policy := entity.UserIDPolicy{
+		Profile:   entity.UserIDProfile(cfg.Profile),
+		MinLength: cfg.MinLength,
+		MaxLength: cfg.MaxLength,
+		ASCIIOnly: cfg.ASCIIOnly,
+		Symbols:   cfg.Symbols,
+		Reserved:  cfg.Reserved,
+	}
+	if policy.Reserved == nil {
+		policy.Reserved = entity.DefaultUserIDPolicy().Reserved
+	}
+	return policy, policy.Check()
+}
+
+// RegistrationConfig holds configuration for who can register.
+// This struct can also be used for go-flags.
+type RegistrationConfig struct {
+	Mode     string   `long:"registration-mode" env:"REGISTRATION_MODE" value-name:"REGISTRATION_MODE" choice:"open" choice:"invite" choice:"approval" default:"open" description:"open allows anyone to register. invite requires an invitation code issued by admin. approval makes registrations wait for approval by admin"`
+	NotifyTo []string `long:"registration-notify" env:"REGISTRATION_NOTIFY" env-delim:"," value-name:"REGISTRATION_NOTIFY" description:"email addresses notified of registrations awaiting approval"`
+
+	TTL time.Duration `long:"registration-ttl" env:"REGISTRATION_TTL" value-name:"REGISTRATION_TTL" default:"10m" description:"pending registrations expire after this duration unless resumed"`
+}
+
+// DefaultRegistrationTTL is how long pending registrations are kept by default.
+const DefaultRegistrationTTL = 10 * time.Minute
+
+// TOTPConfig holds configuration for TOTP enrollment.
+// This struct can also be used for go-flags.
+type TOTPConfig struct {
+	Issuer      string `long:"totp-issuer" env:"TOTP_ISSUER" value-name:"TOTP_ISSUER" default:"ident"`
+	LabelFormat string `long:"totp-label" env:"TOTP_LABEL" value-name:"TOTP_LABEL" default:"{user_id}" description:"account label format. {user_id}, {email} and {issuer} are replaced"`
+}
+
+// KeyConfig holds configuration for key-encryption keys
+// used to encrypt sensitive columns at rest.
+// This struct can also be used for go-flags.
+type KeyConfig struct {
+	KEKFile string `long:"kek-file" env:"IDENT_KEK_FILE" value-name:"IDENT_KEK_FILE" description:"key-encryption key file. encryption is disabled if empty"`
+	KEKID   string `long:"kek-id" env:"IDENT_KEK_ID" value-name:"IDENT_KEK_ID" description:"ID of key-encryption key to encrypt new values. defaults to the first key in the file"`
+}
+
+// LoadKeyring loads keyring from configured file.
+// nil keyring is returned if no file is configured.
+func (cfg KeyConfig) LoadKeyring() (*envelope.Keyring, error) {
+	if cfg.KEKFile == "" {
+		return nil, nil
+	}
+	return envelope.LoadKeyring(cfg.KEKFile, cfg.KEKID)
+}
+
+// AdminConfig holds configuration for administrative API.
+// This struct can also be used for go-flags.
+type AdminConfig struct {
+	Token string `long:"admin-token" env:"IDENT_ADMIN_TOKEN" value-name:"IDENT_ADMIN_TOKEN"`
+}
+
+// SMSConfig holds configuration to use to send SMS.
+// This struct can also be used for go-flags.
+type SMSConfig struct {
+	Provider   string        `long:"sms-provider" env:"SMS_PROVIDER" value-name:"SMS_PROVIDER" choice:"http" choice:"log" default:"log"`
+	Endpoint   string        `long:"sms-endpoint" env:"SMS_ENDPOINT" value-name:"SMS_ENDPOINT"`
+	APIKey     string        `long:"sms-apikey" env:"SMS_APIKEY" value-name:"SMS_APIKEY"`
+	From       string        `long:"sms-from" env:"SMS_FROM" value-name:"SMS_FROM"`
+	Timeout    time.Duration `long:"sms-timeout" env:"SMS_TIMEOUT" value-name:"SMS_TIMEOUT" default:"10s" description:"timeout of requests to the HTTP SMS gateway"`
+	LogFile    string        `long:"sms-log-file" env:"SMS_LOG_FILE" value-name:"SMS_LOG_FILE"`
+	RateLimit  int           `long:"sms-rate-limit" env:"SMS_RATE_LIMIT" value-name:"SMS_RATE_LIMIT" default:"5" description:"max SMS sent to a phone number in the window. negative means unlimited"`
+	RateWindow time.Duration `long:"sms-rate-window" env:"SMS_RATE_WINDOW" value-name:"SMS_RATE_WINDOW" default:"1h"`
+
+	IPRateLimit int `long:"sms-ip-rate-limit" env:"SMS_IP_RATE_LIMIT" value-name:"SMS_IP_RATE_LIMIT" default:"20" description:"max SMS requested from a client IP in the window. negative means unlimited"`
+}
+
 // Environment holds RDB Connection, KVS Connection, and Private KEY.
 type Environment struct {
-	RDB        *sql.DB
+	// RDB is MySQL or PostgreSQL database opened by StorageConfig.
+	RDB        *database.RDB
 	KVS        redis.Conn
 	MailFrom   string
 	Mail       service.Mail
+	SMS        service.SMS
 	PrivateKey *ecdsa.PrivateKey
+	Keyring    *envelope.Keyring
+
+	// TOTPIssuer and TOTPLabelFormat are used to build otpauth URI.
+	TOTPIssuer      string
+	TOTPLabelFormat string
+
+	// Lockout is the policy of brute-force protection on authentication.
+	Lockout LockoutConfig
+
+	// AuthFlow is the policy which decides the order of authentication
+	// methods. nil means entity.DefaultAuthFlow.
+	AuthFlow entity.AuthFlow
+
+	// Audiences are the audiences which clients can request tokens for.
+	// empty means any audience is allowed.
+	Audiences []string
+	// SessionTTL is how long login sessions are kept.
+	// zero means DefaultSessionTTL.
+	SessionTTL time.Duration
+
+	// ProfileSchema defines profile attributes of users.
+	// nil means entity.DefaultProfileSchema.
+	ProfileSchema entity.ProfileSchema
+
+	// UserIDPolicy normalizes and validates user IDs.
+	// nil means entity.DefaultUserIDPolicy.
+	UserIDPolicy *entity.UserIDPolicy
+
+	// EmailPolicy validates email addresses which users can set.
+	EmailPolicy entity.EmailPolicy
+	// EmailVerifyTTL is how long verification links are valid.
+	// zero means DefaultEmailVerifyTTL.
+	EmailVerifyTTL time.Duration
+	// EmailResendCooldown is the minimum interval to resend
+	// verification mail. zero means no cooldown.
+	EmailResendCooldown time.Duration
+
+	// RegistrationMode is the policy of who can register.
+	// empty means entity.RegistrationOpen.
+	RegistrationMode entity.RegistrationMode
+	// RegistrationNotifyTo are email addresses notified of
+	// registrations awaiting approval.
+	RegistrationNotifyTo []string
+	// RegistrationTTL is how long pending registrations are kept.
+	// zero means DefaultRegistrationTTL.
+	RegistrationTTL time.Duration
+
+	// AdminToken is a token to access administrative API.
+	// empty AdminToken disables authentication by the token, and then
+	// only users who have admin role can access administrative API.
+	AdminToken string
+
+	// SMSRateLimit is the max number of SMS sent to a phone number
+	// in SMSRateWindow, and SMSIPRateLimit is the max number of SMS
+	// requested from a client IP. zero means the default limit,
+	// and negative means unlimited.
+	SMSRateLimit   int
+	SMSIPRateLimit int
+	// SMSRateWindow is the window of the limits.
+	// zero means DefaultSMSRateWindow.
+	SMSRateWindow time.Duration
 }
 
 // NewEnvironment returns a new Environment object.
 func NewEnvironment(cfg Config, keyPath string) (*Environment, error) {
-	rdb, err := openMySQL(cfg.MySQL)
+	rdb, err := cfg.Storage.Open()
 	if err != nil {
 		return nil, err
 	}
@@ -65,16 +300,94 @@ func NewEnvironment(cfg Config, keyPath string) (*Environment, error) {
 	if err != nil {
 		return nil, err
 	}
+	smsClient, err := newSMS(cfg.SMS)
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := cfg.Key.LoadKeyring()
+	if err != nil {
+		return nil, err
+	}
+	flow, err := entity.ParseAuthFlow(cfg.Auth.Flow)
+	if err != nil {
+		return nil, err
+	}
+	profileSchema, err := cfg.Profile.LoadSchema()
+	if err != nil {
+		return nil, err
+	}
+	userIDPolicy, err := cfg.UserID.Policy()
+	if err != nil {
+		return nil, err
+	}
+	emailPolicy, err := cfg.Email.Policy()
+	if err != nil {
+		return nil, err
+	}
+	registrationMode, err := entity.ParseRegistrationMode(cfg.Registration.Mode)
+	if err != nil {
+		return nil, err
+	}
 	env := &Environment{
 		RDB:        rdb,
 		KVS:        kvs,
 		Mail:       mail.NewSendGrid(cfg.Mail.APIKey, cfg.Mail.FromAddr),
+		SMS:        smsClient,
 		PrivateKey: key,
+		Keyring:    keyring,
+		AdminToken: cfg.Admin.Token,
+
+		Lockout:  cfg.Lockout,
+		AuthFlow: flow,
+
+		Audiences:  cfg.Auth.Audiences,
+		SessionTTL: cfg.Auth.SessionTTL,
+
+		ProfileSchema: profileSchema,
+		UserIDPolicy:  &userIDPolicy,
+		EmailPolicy:   emailPolicy,
+
+		EmailVerifyTTL:      cfg.Email.VerifyTTL,
+		EmailResendCooldown: cfg.Email.ResendCooldown,
+
+		RegistrationMode:     registrationMode,
+		RegistrationNotifyTo: cfg.Registration.NotifyTo,
+		RegistrationTTL:      cfg.Registration.TTL,
+
+		TOTPIssuer:      cfg.TOTP.Issuer,
+		TOTPLabelFormat: cfg.TOTP.LabelFormat,
+
+		SMSRateLimit:   cfg.SMS.RateLimit,
+		SMSIPRateLimit: cfg.SMS.IPRateLimit,
+		SMSRateWindow:  cfg.SMS.RateWindow,
 	}
 	return env, nil
 }
 
-func openMySQL(opts MySQLConfig) (*sql.DB, error) {
+func newSMS(opts SMSConfig) (service.SMS, error) {
+	switch opts.Provider {
+	case "http":
+		timeout := opts.Timeout
+		if timeout <= 0 {
+			timeout = DefaultSMSTimeout
+		}
+		return sms.NewHTTP(opts.Endpoint, opts.APIKey, opts.From, timeout), nil
+	case "log", "":
+		var w io.Writer = os.Stderr
+		if opts.LogFile != "" {
+			f, err := os.OpenFile(opts.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+			if err != nil {
+				return nil, err
+			}
+			w = f
+		}
+		return sms.NewLog(w), nil
+	}
+	return nil, fmt.Errorf("unknown SMS provider: %s", opts.Provider)
+}
+
+// OpenMySQL opens MySQL database with given configuration.
+func OpenMySQL(opts MySQLConfig) (*sql.DB, error) {
 	cfg := mysql.Config{
 		Net:    "tcp",
 		Addr:   opts.Addr,
@@ -87,15 +400,195 @@ func openMySQL(opts MySQLConfig) (*sql.DB, error) {
 	return sql.Open("mysql", cfg.FormatDSN())
 }
 
+// OpenPostgres opens PostgreSQL database with given configuration.
+func OpenPostgres(opts PostgresConfig) (*sql.DB, error) {
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(opts.User, opts.Password),
+		Host:   opts.Addr,
+		Path:   "/" + opts.DBName,
+	}
+	if opts.SSLMode != "" {
+		dsn.RawQuery = url.Values{"sslmode": {opts.SSLMode}}.Encode()
+	}
+	return sql.Open("postgres", dsn.String())
+}
+
+// GetUserIDPolicy returns the user ID policy of env.
+func (env Environment) GetUserIDPolicy() entity.UserIDPolicy {
+	if env.UserIDPolicy == nil {
+		return entity.DefaultUserIDPolicy()
+	}
+	return *env.UserIDPolicy
+}
+
+// GetRegistrationMode returns the registration mode of env.
+func (env Environment) GetRegistrationMode() entity.RegistrationMode {
+	if env.RegistrationMode == "" {
+		return entity.RegistrationOpen
+	}
+	return env.RegistrationMode
+}
+
+// GetSessionTTL returns how long login sessions are kept.
+func (env Environment) GetSessionTTL() time.Duration {
+	if env.SessionTTL <= 0 {
+		return DefaultSessionTTL
+	}
+	return env.SessionTTL
+}
+
+// GetRegistrationTTL returns how long pending registrations are kept.
+func (env Environment) GetRegistrationTTL() time.Duration {
+	if env.RegistrationTTL <= 0 {
+		return DefaultRegistrationTTL
+	}
+	return env.RegistrationTTL
+}
+
+// default limits of SMS.
+const (
+	DefaultSMSRateLimit   = 5
+	DefaultSMSIPRateLimit = 20
+	DefaultSMSRateWindow  = time.Hour
+)
+
+// DefaultSMSTimeout is the timeout of requests to the SMS gateway
+// used unless configured.
+const DefaultSMSTimeout = 10 * time.Second
+
+// GetSMSRateLimit returns the max number of SMS sent to a phone number
+// in the window. Zero is returned if unlimited.
+func (env Environment) GetSMSRateLimit() int {
+	return limitOrDefault(env.SMSRateLimit, DefaultSMSRateLimit)
+}
+
+// GetSMSIPRateLimit returns the max number of SMS requested from
+// a client IP in the window. Zero is returned if unlimited.
+func (env Environment) GetSMSIPRateLimit() int {
+	return limitOrDefault(env.SMSIPRateLimit, DefaultSMSIPRateLimit)
+}
+
+// GetSMSRateWindow returns the window of SMS rate limits.
+func (env Environment) GetSMSRateWindow() time.Duration {
+	if env.SMSRateWindow <= 0 {
+		return DefaultSMSRateWindow
+	}
+	return env.SMSRateWindow
+}
+
+func limitOrDefault(limit, def int) int {
+	switch {
+	case limit < 0:
+		return 0
+	case limit == 0:
+		return def
+	}
+	return limit
+}
+
+// GetEmailVerifyTTL returns how long verification links are valid.
+func (env Environment) GetEmailVerifyTTL() time.Duration {
+	if env.EmailVerifyTTL <= 0 {
+		return DefaultEmailVerifyTTL
+	}
+	return env.EmailVerifyTTL
+}
+
 // GetUserRepository generates UserRepository instance fron env itself.
 func (env Environment) GetUserRepository() repository.UserRepository {
-	return database.NewUserRepository(env.RDB, env.KVS)
+	return database.NewUserRepository(env.RDB, env.KVS, env.Keyring, env.GetUserIDPolicy())
+}
+
+// GetOTPRepository generates OTPRepository instance from env itself.
+func (env Environment) GetOTPRepository() repository.OTPRepository {
+	return database.NewOTPRepository(env.KVS)
+}
+
+// GetRateLimitRepository generates RateLimitRepository instance from env itself.
+func (env Environment) GetRateLimitRepository() repository.RateLimitRepository {
+	return database.NewRateLimitRepository(env.KVS)
+}
+
+// GetLockoutRepository generates LockoutRepository instance from env itself.
+func (env Environment) GetLockoutRepository() repository.LockoutRepository {
+	return database.NewLockoutRepository(env.KVS)
+}
+
+// GetRoleRepository generates RoleRepository instance from env itself.
+func (env Environment) GetRoleRepository() repository.RoleRepository {
+	return database.NewRoleRepository(env.RDB)
+}
+
+// GetGroupRepository generates GroupRepository instance from env itself.
+func (env Environment) GetGroupRepository() repository.GroupRepository {
+	return database.NewGroupRepository(env.RDB)
+}
+
+// GetProfileRepository generates ProfileRepository instance from env itself.
+func (env Environment) GetProfileRepository() repository.ProfileRepository {
+	return database.NewProfileRepository(env.RDB)
+}
+
+// GetInvitationRepository generates InvitationRepository instance from env itself.
+func (env Environment) GetInvitationRepository() repository.InvitationRepository {
+	return database.NewInvitationRepository(env.RDB)
 }
 
-// SendVerifyMail sends address verification mail using sendgrid.
-func (env Environment) SendVerifyMail(from, to, sessid string) error {
+// GetSessionRepository generates SessionRepository instance from env itself.
+func (env Environment) GetSessionRepository() repository.SessionRepository {
+	return database.NewSessionRepository(env.KVS)
+}
+
+// SendVerifyMail sends address verification mail with the link
+// which contains given user ID and verification token.
+func (env Environment) SendVerifyMail(to, userID, token string) error {
+	const subject = `verify your email address`
 	const body = `access below to verify your e-mail address.
-http://localhost:8080/v1/user/email/%s
+http://localhost:8080/v1/user/email/verify/%s/%s
+this link expires in %s.
+`
+	return env.Mail.Send(to, subject, fmt.Sprintf(body, url.PathEscape(userID), token, env.GetEmailVerifyTTL()))
+}
+
+// SendEmailChangedMail notifies the previous address that the email
+// address of the user has been changed, with the link to revert it.
+func (env Environment) SendEmailChangedMail(to, userID, code string) error {
+	const subject = `your email address has been changed`
+	const body = `the email address of your account %s has been changed.
+if you did not change it, access below to restore this address.
+http://localhost:8080/v1/user/email/revert/%s/%s
 `
-	return env.Mail.Send(from, to, fmt.Sprintf(body, sessid))
+	return env.Mail.Send(to, subject, fmt.Sprintf(body, userID, url.PathEscape(userID), code))
+}
+
+// SendApprovalRequestMail notifies admins that the registration
+// is awaiting approval.
+func (env Environment) SendApprovalRequestMail(userID string) error {
+	const subject = `registration awaiting approval`
+	const body = `user %s has completed registration and is awaiting your approval.
+`
+	for _, to := range env.RegistrationNotifyTo {
+		if err := env.Mail.Send(to, subject, fmt.Sprintf(body, userID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendApprovalResultMail notifies the user whether the registration
+// has been approved or not.
+func (env Environment) SendApprovalResultMail(to string, approved bool) error {
+	const subject = `your registration`
+	body := "your registration has been approved. you can log in now.\n"
+	if !approved {
+		body = "your registration has been rejected.\n"
+	}
+	return env.Mail.Send(to, subject, body)
+}
+
+// SendSMSCode sends one-time passcode via SMS.
+func (env Environment) SendSMSCode(to, code string) error {
+	const body = `your ident verification code is %s`
+	return env.SMS.Send(to, fmt.Sprintf(body, code))
 }
@@ -0,0 +1,241 @@
+// Package envelope provides envelope encryption for sensitive values.
+// Each value is encrypted with its own AES-GCM data key, and the data key
+// is wrapped by a key-encryption key (KEK) in the keyring.
+package envelope
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+)
+
+// Error is package-specific error type.
+type Error string
+
+// error constants
+const (
+	ErrKeyNotFound   Error = "key-encryption key not found"
+	ErrInvalidKey    Error = "key-encryption key must be 32 bytes"
+	ErrInvalidFormat Error = "ciphertext format invalid"
+	ErrNoCurrentKey  Error = "no current key-encryption key"
+	ErrInvalidKeyID  Error = "key ID must not be empty or contain separator"
+)
+
+// Error implements error interface.
+func (e Error) Error() string { return string(e) }
+
+const (
+	prefix    = "enc:v1:"
+	separator = ":"
+	keySize   = 32
+)
+
+// Keyring holds key-encryption keys identified by key ID.
+// New values are always encrypted under the current key.
+type Keyring struct {
+	current string
+	keks    map[string][]byte
+}
+
+// NewKeyring returns a new empty keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{keks: map[string][]byte{}}
+}
+
+// LoadKeyring loads keyring from given file.
+// Each line of the file is a key ID and base64-encoded 32-byte key
+// separated by space. Empty lines and lines start with # are ignored.
+// If currentID is empty, the first key in the file is used as current.
+func LoadKeyring(path, currentID string) (*Keyring, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadKeyring(f, currentID)
+}
+
+// ReadKeyring reads keyring from r. See LoadKeyring for the format.
+func ReadKeyring(r io.Reader, currentID string) (*Keyring, error) {
+	kr := NewKeyring()
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, ErrInvalidFormat
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		if err := kr.Add(fields[0], key); err != nil {
+			return nil, err
+		}
+		if currentID == "" {
+			currentID = fields[0]
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := kr.SetCurrent(currentID); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// Add adds a key-encryption key to the keyring.
+func (kr *Keyring) Add(id string, key []byte) error {
+	if id == "" || strings.Contains(id, separator) {
+		return ErrInvalidKeyID
+	}
+	if len(key) != keySize {
+		return ErrInvalidKey
+	}
+	kr.keks[id] = key
+	return nil
+}
+
+// SetCurrent sets the key used to encrypt new values.
+func (kr *Keyring) SetCurrent(id string) error {
+	if _, ok := kr.keks[id]; !ok {
+		return ErrKeyNotFound
+	}
+	kr.current = id
+	return nil
+}
+
+// CurrentID returns the ID of current key-encryption key.
+// nil keyring has no current key and returns empty string.
+func (kr *Keyring) CurrentID() string {
+	if kr == nil {
+		return ""
+	}
+	return kr.current
+}
+
+// Encrypt encrypts plaintext under the current key and returns
+// the ciphertext and the key ID used.
+// additionalData is authenticated but not encrypted, and the same
+// value must be given to Decrypt.
+// nil keyring does not encrypt, returns plaintext as is.
+func (kr *Keyring) Encrypt(plaintext, additionalData string) (string, string, error) {
+	if kr == nil || plaintext == "" {
+		return plaintext, "", nil
+	}
+	kek, ok := kr.keks[kr.current]
+	if !ok {
+		return "", "", ErrNoCurrentKey
+	}
+	dek := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return "", "", err
+	}
+	wrapped, err := seal(kek, dek, []byte(kr.current))
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := seal(dek, []byte(plaintext), []byte(additionalData))
+	if err != nil {
+		return "", "", err
+	}
+	ciphertext := prefix + strings.Join([]string{
+		kr.current,
+		base64.RawURLEncoding.EncodeToString(wrapped),
+		base64.RawURLEncoding.EncodeToString(sealed),
+	}, separator)
+	return ciphertext, kr.current, nil
+}
+
+// Decrypt decrypts the ciphertext encrypted by Encrypt.
+// Values not encrypted are returned as is, so that plaintext values
+// stored before enabling encryption can be read.
+func (kr *Keyring) Decrypt(ciphertext, additionalData string) (string, error) {
+	if !IsEncrypted(ciphertext) {
+		return ciphertext, nil
+	}
+	fields := strings.Split(strings.TrimPrefix(ciphertext, prefix), separator)
+	if len(fields) != 3 {
+		return "", ErrInvalidFormat
+	}
+	if kr == nil {
+		return "", ErrKeyNotFound
+	}
+	kek, ok := kr.keks[fields[0]]
+	if !ok {
+		return "", ErrKeyNotFound
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(fields[1])
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(fields[2])
+	if err != nil {
+		return "", err
+	}
+	dek, err := open(kek, wrapped, []byte(fields[0]))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dek, sealed, []byte(additionalData))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted returns given value is encrypted by Encrypt or not.
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, prefix)
+}
+
+// KeyID returns the ID of key-encryption key used for given ciphertext.
+// Empty string is returned if the value is not encrypted.
+func KeyID(ciphertext string) string {
+	if !IsEncrypted(ciphertext) {
+		return ""
+	}
+	return strings.SplitN(strings.TrimPrefix(ciphertext, prefix), separator, 2)[0]
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext and returns nonce and ciphertext concatenated.
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidFormat
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
@@ -0,0 +1,107 @@
+package envelope_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nasa9084/ident/infra/envelope"
+)
+
+const keyFile = `# test keys
+old AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+new AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=
+`
+
+func TestReadKeyring(t *testing.T) {
+	candidates := []struct {
+		label     string
+		input     string
+		currentID string
+		expected  string
+		hasErr    bool
+	}{
+		{"default current", keyFile, "", "old", false},
+		{"given current", keyFile, "new", "new", false},
+		{"unknown current", keyFile, "foo", "", true},
+		{"short key", "foo AAAA", "", "", true},
+		{"invalid base64", "foo !!!!", "", "", true},
+		{"invalid line", "foo", "", "", true},
+		{"empty", "", "", "", true},
+	}
+	for _, c := range candidates {
+		kr, err := envelope.ReadKeyring(strings.NewReader(c.input), c.currentID)
+		if c.hasErr != (err != nil) {
+			t.Errorf("%s: unexpected error state: %v", c.label, err)
+			continue
+		}
+		if err == nil && kr.CurrentID() != c.expected {
+			t.Errorf("%s: %s != %s", c.label, kr.CurrentID(), c.expected)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	kr, err := envelope.ReadKeyring(strings.NewReader(keyFile), "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const plaintext = "secret"
+	ciphertext, keyID, err := kr.Encrypt(plaintext, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID != "old" || envelope.KeyID(ciphertext) != "old" {
+		t.Errorf("%s != old", keyID)
+		return
+	}
+	if strings.Contains(ciphertext, plaintext) {
+		t.Errorf("ciphertext contains plaintext: %s", ciphertext)
+		return
+	}
+
+	// old ciphertext can be decrypted after rotation
+	if err := kr.SetCurrent("new"); err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := kr.Decrypt(ciphertext, "alice")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if decrypted != plaintext {
+		t.Errorf("%s != %s", decrypted, plaintext)
+		return
+	}
+
+	// ciphertext is bound to additional data
+	if _, err := kr.Decrypt(ciphertext, "bob"); err == nil {
+		t.Error("error should be occurred, but not")
+		return
+	}
+}
+
+func TestDecryptPlaintext(t *testing.T) {
+	var kr *envelope.Keyring
+	ciphertext, keyID, err := kr.Encrypt("secret", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ciphertext != "secret" || keyID != "" {
+		t.Errorf("nil keyring should not encrypt: %s, %s", ciphertext, keyID)
+		return
+	}
+	kr, err = envelope.ReadKeyring(bytes.NewBufferString(keyFile), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := kr.Decrypt("secret", "alice")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if plaintext != "secret" {
+		t.Errorf("%s != secret", plaintext)
+		return
+	}
+}
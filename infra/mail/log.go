@@ -0,0 +1,26 @@
+package mail
+
+import (
+	"io"
+	"log"
+
+	"github.com/nasa9084/ident/domain/service"
+)
+
+// logmail is an implementation of service.Mail interface.
+// this struct only writes messages to given writer, for local testing.
+type logmail struct {
+	logger *log.Logger
+}
+
+// NewLog returns a new mail client which writes messages to w as service.Mail.
+func NewLog(w io.Writer) service.Mail {
+	return &logmail{
+		logger: log.New(w, "[MAIL] ", log.LstdFlags),
+	}
+}
+
+func (l *logmail) Send(to, subject, body string) error {
+	l.logger.Printf("to=%s subject=%q body=%q", to, subject, body)
+	return nil
+}
@@ -0,0 +1,64 @@
+package infra
+
+import (
+	"io/ioutil"
+
+	"github.com/nasa9084/ident/domain/entity"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ProfileConfig holds configuration for user profile attributes.
+// This struct can also be used for go-flags.
+type ProfileConfig struct {
+	SchemaFile string `long:"profile-schema" env:"PROFILE_SCHEMA" value-name:"PROFILE_SCHEMA" description:"YAML file which defines custom profile attributes"`
+}
+
+// profileAttribute is a definition of a profile attribute in schema file.
+type profileAttribute struct {
+	Type       string `yaml:"type"`
+	Visibility string `yaml:"visibility"`
+	MaxLength  int    `yaml:"max_length"`
+	Claim      bool   `yaml:"claim"`
+}
+
+// LoadSchema loads profile schema from configured file.
+// The file is a YAML map from attribute name to its definition, e.g.:
+//
+//	department:
+//	  type: string        # string, int, bool, url, locale or timezone
+//	  visibility: user    # user, readonly or admin
+//	  max_length: 64
+//	  claim: true         # include in JWT token
+//
+// Attributes in the file are added to the built-in attributes
+// (display_name, locale, timezone and avatar_url), or override them.
+func (cfg ProfileConfig) LoadSchema() (entity.ProfileSchema, error) {
+	schema := entity.DefaultProfileSchema()
+	if cfg.SchemaFile == "" {
+		return schema, nil
+	}
+	b, err := ioutil.ReadFile(cfg.SchemaFile)
+	if err != nil {
+		return nil, err
+	}
+	var attrs map[string]profileAttribute
+	if err := yaml.Unmarshal(b, &attrs); err != nil {
+		return nil, err
+	}
+	for name, a := range attrs {
+		attr := entity.ProfileAttribute{
+			Type:       entity.AttributeType(a.Type),
+			Visibility: entity.Visibility(a.Visibility),
+			MaxLength:  a.MaxLength,
+			Claim:      a.Claim,
+		}
+		if attr.Type == "" {
+			attr.Type = entity.AttributeString
+		}
+		if attr.Visibility == "" {
+			attr.Visibility = entity.VisibilityUser
+		}
+		schema[name] = attr
+	}
+	return schema, schema.Check()
+}
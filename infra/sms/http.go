@@ -0,0 +1,66 @@
+package sms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nasa9084/ident/domain/service"
+)
+
+// httpsms is an implementation of service.SMS interface.
+// this struct posts messages to HTTP-based SMS gateway as JSON.
+type httpsms struct {
+	client   *http.Client
+	endpoint string
+	apikey   string
+	from     string
+}
+
+// NewHTTP returns a new HTTP-based SMS client as service.SMS.
+// Requests to the gateway time out after timeout.
+func NewHTTP(endpoint, apikey, from string, timeout time.Duration) service.SMS {
+	return &httpsms{
+		client:   &http.Client{Timeout: timeout},
+		endpoint: endpoint,
+		apikey:   apikey,
+		from:     from,
+	}
+}
+
+type httpMessage struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Body string `json:"body"`
+}
+
+func (h *httpsms) Send(to, body string) error {
+	var buf bytes.Buffer
+	msg := httpMessage{
+		From: h.from,
+		To:   to,
+		Body: body,
+	}
+	if err := json.NewEncoder(&buf).Encode(msg); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, h.endpoint, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.apikey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.apikey)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || 300 <= resp.StatusCode {
+		return fmt.Errorf("sms gateway returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package sms
+
+import (
+	"io"
+	"log"
+
+	"github.com/nasa9084/ident/domain/service"
+)
+
+// logsms is an implementation of service.SMS interface.
+// this struct only writes messages to given writer, for local testing.
+type logsms struct {
+	logger *log.Logger
+}
+
+// NewLog returns a new SMS client which writes messages to w as service.SMS.
+func NewLog(w io.Writer) service.SMS {
+	return &logsms{
+		logger: log.New(w, "[SMS] ", log.LstdFlags),
+	}
+}
+
+func (l *logsms) Send(to, body string) error {
+	l.logger.Printf("to=%s body=%q", to, body)
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 
 	flags "github.com/jessevdk/go-flags"
@@ -75,6 +76,12 @@ func generateServer(spec *openapi.Document) error {
 		if pathItem.Put != nil {
 			buf.WriteString(fmt.Sprintf(route, path, pathItem.Put.OperationID, "Put"))
 		}
+		if pathItem.Delete != nil {
+			buf.WriteString(fmt.Sprintf(route, path, pathItem.Delete.OperationID, "Delete"))
+		}
+		if pathItem.Patch != nil {
+			buf.WriteString(fmt.Sprintf(route, path, pathItem.Patch.OperationID, "Patch"))
+		}
 	}
 	buf.WriteString("\n}")
 
@@ -114,6 +121,18 @@ func generateRequestInterface(buf *bytes.Buffer) error {
 	buf.WriteString("\nRequest")
 	buf.WriteString("\nSetPathArgs(map[string]string)")
 	buf.WriteString("\n}")
+	buf.WriteString("\n\ntype QueryArgsRequest interface {")
+	buf.WriteString("\nRequest")
+	buf.WriteString("\nSetQueryArgs(map[string]string)")
+	buf.WriteString("\n}")
+	buf.WriteString("\n\ntype HeaderArgsRequest interface {")
+	buf.WriteString("\nRequest")
+	buf.WriteString("\nSetHeaderArgs(map[string]string)")
+	buf.WriteString("\n}")
+	buf.WriteString("\n\ntype AdminRequest interface {")
+	buf.WriteString("\nRequest")
+	buf.WriteString("\nSetAdminToken(string)")
+	buf.WriteString("\n}")
 	return nil
 }
 
@@ -133,6 +152,16 @@ func generateRequestsForPathItem(buf *bytes.Buffer, pathItem *openapi.PathItem)
 			return err
 		}
 	}
+	if pathItem.Delete != nil {
+		if err := generateRequest(buf, pathItem.Delete); err != nil {
+			return err
+		}
+	}
+	if pathItem.Patch != nil {
+		if err := generateRequest(buf, pathItem.Patch); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -148,9 +177,14 @@ func generateRequest(buf *bytes.Buffer, op *openapi.Operation) error {
 			buf.WriteString("\n")
 			buf.WriteString(v.Title)
 			buf.WriteString("\t")
-			if v.Type == "string" {
+			switch v.Type {
+			case "string":
 				buf.WriteString("string")
-			} else {
+			case "integer":
+				buf.WriteString("int")
+			case "object":
+				buf.WriteString(goType(v))
+			default:
 				return errors.New("unknown type")
 			}
 			buf.WriteString("\t `json:\"")
@@ -158,27 +192,39 @@ func generateRequest(buf *bytes.Buffer, op *openapi.Operation) error {
 			buf.WriteString("\"`")
 		}
 	}
-	var isSessionRequest bool
+	var isSessionRequest, isAdminRequest, isSecurityOptional bool
 	if op.Security != nil {
+		isSecurityOptional = len(*op.Security) > 1
 		for _, security := range *op.Security {
 			if _, ok := security["sessionId"]; ok {
 				buf.WriteString("\n\nSessionID string `json:\"-\"`")
 				isSessionRequest = true
 			}
+			if _, ok := security["adminToken"]; ok {
+				buf.WriteString("\n\nAdminToken string `json:\"-\"`")
+				isAdminRequest = true
+			}
 		}
 	}
-	var isPathArgsRequest bool
+	var isPathArgsRequest, isQueryArgsRequest, isHeaderArgsRequest bool
 	pathArgTitles := map[string]*openapi.Schema{}
 	for _, param := range op.Parameters {
-		if param.In == "path" {
-			buf.WriteString("\n\n")
-			buf.WriteString(param.Schema.Title)
-			buf.WriteString(" ")
-			buf.WriteString(param.Schema.Type)
-			buf.WriteString(" `json:\"-\"`")
+		switch param.In {
+		case "path":
 			isPathArgsRequest = true
 			pathArgTitles[param.Name] = param.Schema
+		case "query":
+			isQueryArgsRequest = true
+		case "header":
+			isHeaderArgsRequest = true
+		default:
+			continue
 		}
+		buf.WriteString("\n\n")
+		buf.WriteString(param.Schema.Title)
+		buf.WriteString(" ")
+		buf.WriteString(param.Schema.Type)
+		buf.WriteString(" `json:\"-\"`")
 	}
 	buf.WriteString("\n}")
 
@@ -200,11 +246,25 @@ func generateRequest(buf *bytes.Buffer, op *openapi.Operation) error {
 		buf.WriteString(strconv.Quote(fmt.Sprintf("%s is required", n)))
 		buf.WriteString(")")
 	}
-	if isSessionRequest {
+	switch {
+	case isSecurityOptional && isSessionRequest && isAdminRequest:
+		// security requirements are alternatives
+		buf.WriteString("\ncase r.SessionID == \"\" && r.AdminToken == \"\":")
+		buf.WriteString("\nreturn errors.New(")
+		buf.WriteString(strconv.Quote("authorization header or admin token is required"))
+		buf.WriteString(")")
+	case isSecurityOptional:
+		// empty security requirement makes authentication optional
+	case isSessionRequest:
 		buf.WriteString("\ncase r.SessionID == \"\":")
 		buf.WriteString("\nreturn errors.New(")
 		buf.WriteString(strconv.Quote("authorization header is required"))
 		buf.WriteString(")")
+	case isAdminRequest:
+		buf.WriteString("\ncase r.AdminToken == \"\":")
+		buf.WriteString("\nreturn errors.New(")
+		buf.WriteString(strconv.Quote("admin token is required"))
+		buf.WriteString(")")
 	}
 	if op.RequestBody != nil {
 		for _, required := range op.RequestBody.Content["application/json"].Schema.Required {
@@ -240,7 +300,7 @@ func generateRequest(buf *bytes.Buffer, op *openapi.Operation) error {
 					buf.WriteString(":")
 					buf.WriteString("\nreturn errors.New(\"length of ")
 					buf.WriteString(p)
-					buf.WriteString(" is over")
+					buf.WriteString(" is over\")")
 				}
 				if s.MinLength != 0 {
 					buf.WriteString("\ncase len(r.")
@@ -250,7 +310,7 @@ func generateRequest(buf *bytes.Buffer, op *openapi.Operation) error {
 					buf.WriteString(":")
 					buf.WriteString("\nreturn errors.New(\"length of ")
 					buf.WriteString(p)
-					buf.WriteString(" is less")
+					buf.WriteString(" is less\")")
 				}
 			}
 			if s.Format == "digit" {
@@ -275,32 +335,56 @@ func generateRequest(buf *bytes.Buffer, op *openapi.Operation) error {
 		buf.WriteString("\nr.SessionID = sessid")
 		buf.WriteString("\n}")
 	}
-	if isPathArgsRequest {
+	if isAdminRequest {
 		buf.WriteString("\n\nfunc (r *")
 		buf.WriteString(op.OperationID)
-		buf.WriteString("Request) SetPathArgs(args map[string]string) {")
-		for _, param := range op.Parameters {
-			if param.In != "path" {
-				continue
-			}
-			buf.WriteString("\nr.")
-			buf.WriteString(param.Schema.Title)
-			buf.WriteString(" = args[`")
-			buf.WriteString(param.Name)
-			buf.WriteString("`]")
-		}
+		buf.WriteString("Request) SetAdminToken(token string) {")
+		buf.WriteString("\nr.AdminToken = token")
 		buf.WriteString("\n}")
 	}
+	if isPathArgsRequest {
+		generateArgsSetter(buf, op, "path", "SetPathArgs")
+	}
+	if isQueryArgsRequest {
+		generateArgsSetter(buf, op, "query", "SetQueryArgs")
+	}
+	if isHeaderArgsRequest {
+		generateArgsSetter(buf, op, "header", "SetHeaderArgs")
+	}
 
 	return nil
 }
 
+func generateArgsSetter(buf *bytes.Buffer, op *openapi.Operation, in, method string) {
+	buf.WriteString("\n\nfunc (r *")
+	buf.WriteString(op.OperationID)
+	buf.WriteString("Request) ")
+	buf.WriteString(method)
+	buf.WriteString("(args map[string]string) {")
+	for _, param := range op.Parameters {
+		if param.In != in {
+			continue
+		}
+		name := param.Name
+		if in == "header" {
+			name = http.CanonicalHeaderKey(name)
+		}
+		buf.WriteString("\nr.")
+		buf.WriteString(param.Schema.Title)
+		buf.WriteString(" = args[`")
+		buf.WriteString(name)
+		buf.WriteString("`]")
+	}
+	buf.WriteString("\n}")
+}
+
 func generateResponses(spec *openapi.Document) error {
 	var buf bytes.Buffer
 	buf.WriteString("package output")
 	buf.WriteString("\nimport (")
 	writeImport(&buf, "encoding/json")
 	writeImport(&buf, "net/http")
+	writeImport(&buf, "strconv")
 	buf.WriteString("\n")
 	writeImport(&buf, "github.com/lestrrat-go/bufferpool")
 	buf.WriteString("\n)")
@@ -372,6 +456,23 @@ func generateResponseInterface(buf *bytes.Buffer, responses openapi.Responses) e
 	buf.WriteString("\nw.WriteHeader(status)")
 	buf.WriteString("\nw.Write(png)")
 	buf.WriteString("\n}")
+	buf.WriteString("\n\nfunc renderSVG(w http.ResponseWriter, status int, svg []byte) {")
+	buf.WriteString("\nw.Header().Set(\"Content-Type\", \"image/svg+xml\")")
+	buf.WriteString("\nw.WriteHeader(status)")
+	buf.WriteString("\nw.Write(svg)")
+	buf.WriteString("\n}")
+
+	buf.WriteString("\n\nfunc setRetryAfter(w http.ResponseWriter, seconds int) {")
+	buf.WriteString("\nif seconds > 0 {")
+	buf.WriteString(fmt.Sprintf("\nw.Header().Set(%s, strconv.Itoa(seconds))", strconv.Quote("Retry-After")))
+	buf.WriteString("\n}")
+	buf.WriteString("\n}")
+
+	buf.WriteString("\n\nfunc setSessionID(w http.ResponseWriter, sessid string) {")
+	buf.WriteString("\nif sessid != \"\" {")
+	buf.WriteString("\nw.Header().Set(\"X-SESSION-ID\", sessid)")
+	buf.WriteString("\n}")
+	buf.WriteString("\n}")
 
 	buf.WriteString("\n\nfunc renderJSONWithSessionID(w http.ResponseWriter, status int, err error, sessid string) {")
 	buf.WriteString("\nif err != nil {")
@@ -401,6 +502,16 @@ func generateResponseForPathItem(buf *bytes.Buffer, pathItem *openapi.PathItem)
 			return err
 		}
 	}
+	if pathItem.Delete != nil {
+		if err := generateResponse(buf, pathItem.Delete); err != nil {
+			return err
+		}
+	}
+	if pathItem.Patch != nil {
+		if err := generateResponse(buf, pathItem.Patch); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -417,7 +528,7 @@ func generateResponse(buf *bytes.Buffer, op *openapi.Operation) error {
 		for _, mime := range resp.Content {
 			if mime.Schema.Type == "object" {
 				for _, p := range mime.Schema.Properties {
-					buf.WriteString(fmt.Sprintf("\n%s %s", p.Title, p.Type))
+					buf.WriteString(fmt.Sprintf("\n%s %s", p.Title, goType(p)))
 				}
 			} else if mime.Schema.Type == "string" {
 				if mime.Schema.Title != "" {
@@ -441,36 +552,120 @@ func generateResponse(buf *bytes.Buffer, op *openapi.Operation) error {
 			returnSessionID = true
 		}
 	}
+	negotiate := len(resp.Content) > 1
+	if negotiate {
+		buf.WriteString("\n\nContentType string")
+	}
+	var retryAfter bool
+	for _, r := range op.Responses {
+		if _, ok := r.Headers["Retry-After"]; ok {
+			retryAfter = true
+		}
+	}
+	if retryAfter {
+		buf.WriteString("\n\nRetryAfter int")
+	}
 	buf.WriteString("\n}")
 
 	buf.WriteString(fmt.Sprintf("\n\nfunc (resp %sResponse) Render(w http.ResponseWriter) {", op.OperationID))
+	if retryAfter {
+		buf.WriteString("\nsetRetryAfter(w, resp.RetryAfter)")
+	}
 	buf.WriteString("\nif resp.Err != nil {")
 	buf.WriteString("\nrenderJSON(w, resp.Status, resp.Err)")
 	buf.WriteString("\nreturn")
 	buf.WriteString("\n}")
-	buf.WriteString("\nrender")
-	if _, ok := resp.Content["application/json"]; ok {
-		buf.WriteString("JSON")
-		if returnSessionID {
-			buf.WriteString("WithSessionID(w, resp.Status, resp.Err, resp.SessionID)")
-		} else {
-			buf.WriteString("(w, resp.Status, okBody)")
+	if !negotiate {
+		for mime, content := range resp.Content {
+			call, err := renderCall(mime, content, returnSessionID)
+			if err != nil {
+				return err
+			}
+			buf.WriteString("\n")
+			buf.WriteString(call)
 		}
-	} else if content, ok := resp.Content["image/png"]; ok {
-		buf.WriteString("PNG(w, resp.Status, resp.")
-		buf.WriteString(content.Schema.Title)
-		buf.WriteString(")")
-	} else if content, ok := resp.Content["application/x-pem-file"]; ok {
-		buf.WriteString("PEM(w, resp.Status, resp.")
-		buf.WriteString(content.Schema.Title)
-		buf.WriteString(")")
-	} else {
-		return errors.New("unknown content-type for response")
+		if len(resp.Content) == 0 {
+			return errors.New("unknown content-type for response")
+		}
+		buf.WriteString("\n}")
+		return nil
 	}
+	mimes := make([]string, 0, len(resp.Content))
+	for mime := range resp.Content {
+		mimes = append(mimes, mime)
+	}
+	sort.Strings(mimes)
+	buf.WriteString("\nswitch resp.ContentType {")
+	for _, mime := range mimes {
+		call, err := renderCall(mime, resp.Content[mime], returnSessionID)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("\ncase %s:", strconv.Quote(mime)))
+		buf.WriteString("\n")
+		buf.WriteString(call)
+	}
+	buf.WriteString("\ndefault:")
+	buf.WriteString("\nrenderJSON(w, http.StatusInternalServerError, jsonErr{")
+	buf.WriteString(fmt.Sprintf("\nMessage: %s,", strconv.Quote("unknown content type")))
+	buf.WriteString("\nError: http.StatusText(http.StatusInternalServerError),")
+	buf.WriteString("\n})")
+	buf.WriteString("\n}")
 	buf.WriteString("\n}")
 	return nil
 }
 
+// goType returns Go type name of response property.
+// Object is a named type in output package whose name is the title,
+// and array is a slice of its items.
+func goType(s *openapi.Schema) string {
+	switch s.Type {
+	case "object":
+		return s.Title
+	case "array":
+		return "[]" + goType(s.Items)
+	}
+	return s.Type
+}
+
+// renderCall returns a statement which renders the response as given content type.
+func renderCall(mime string, content *openapi.MediaType, returnSessionID bool) (string, error) {
+	switch mime {
+	case "application/json":
+		names := make([]string, 0, len(content.Schema.Properties))
+		for name, p := range content.Schema.Properties {
+			if p.Title == "Message" {
+				continue
+			}
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			if returnSessionID {
+				return "renderJSONWithSessionID(w, resp.Status, resp.Err, resp.SessionID)", nil
+			}
+			return "renderJSON(w, resp.Status, okBody)", nil
+		}
+		sort.Strings(names)
+		var buf bytes.Buffer
+		if returnSessionID {
+			buf.WriteString("setSessionID(w, resp.SessionID)\n")
+		}
+		buf.WriteString("renderJSON(w, resp.Status, map[string]interface{}{")
+		for _, name := range names {
+			buf.WriteString(fmt.Sprintf("\n%s: resp.%s,", strconv.Quote(name), content.Schema.Properties[name].Title))
+		}
+		buf.WriteString("\n})")
+		return buf.String(), nil
+	case "image/png":
+		return fmt.Sprintf("renderPNG(w, resp.Status, resp.%s)", content.Schema.Title), nil
+	case "image/svg+xml":
+		return fmt.Sprintf("renderSVG(w, resp.Status, resp.%s)", content.Schema.Title), nil
+	case "application/x-pem-file":
+		return fmt.Sprintf("renderPEM(w, resp.Status, resp.%s)", content.Schema.Title), nil
+	}
+	return "", errors.New("unknown content-type for response")
+}
+
 func generateHandlers(spec *openapi.Document) error {
 	var buf bytes.Buffer
 	buf.WriteString("package ident")
@@ -507,7 +702,7 @@ func writeImport(buf *bytes.Buffer, pkg string) {
 
 func generateHandlerHelper(buf *bytes.Buffer) error {
 	buf.WriteString("\n\nfunc parseRequest(r *http.Request, dest input.Request) error {")
-	buf.WriteString("\nif r.Method != http.MethodGet {")
+	buf.WriteString("\nif r.Method != http.MethodGet && r.Body != http.NoBody {")
 	buf.WriteString("\nif err := json.NewDecoder(r.Body).Decode(dest); err != nil {")
 	buf.WriteString(fmt.Sprintf("\nreturn errors.Wrap(err, %s)", strconv.Quote("parsing request body")))
 	buf.WriteString("\n}")
@@ -519,9 +714,27 @@ func generateHandlerHelper(buf *bytes.Buffer) error {
 	buf.WriteString("\n}")
 	buf.WriteString("\nsessReq.SetSessionID(authorization)")
 	buf.WriteString("\n}")
+	buf.WriteString("\nif adReq, ok := dest.(input.AdminRequest); ok {")
+	buf.WriteString(fmt.Sprintf("\nadReq.SetAdminToken(r.Header.Get(%s))", strconv.Quote("X-ADMIN-TOKEN")))
+	buf.WriteString("\n}")
 	buf.WriteString("\nif arReq, ok := dest.(input.PathArgsRequest); ok {")
 	buf.WriteString("\narReq.SetPathArgs(mux.Vars(r))")
 	buf.WriteString("\n}")
+	buf.WriteString("\nif qaReq, ok := dest.(input.QueryArgsRequest); ok {")
+	buf.WriteString("\nargs := map[string]string{}")
+	buf.WriteString("\nquery := r.URL.Query()")
+	buf.WriteString("\nfor k := range query {")
+	buf.WriteString("\nargs[k] = query.Get(k)")
+	buf.WriteString("\n}")
+	buf.WriteString("\nqaReq.SetQueryArgs(args)")
+	buf.WriteString("\n}")
+	buf.WriteString("\nif haReq, ok := dest.(input.HeaderArgsRequest); ok {")
+	buf.WriteString("\nargs := map[string]string{}")
+	buf.WriteString("\nfor k := range r.Header {")
+	buf.WriteString("\nargs[k] = r.Header.Get(k)")
+	buf.WriteString("\n}")
+	buf.WriteString("\nhaReq.SetHeaderArgs(args)")
+	buf.WriteString("\n}")
 	buf.WriteString("\nreturn dest.Validate()")
 	buf.WriteString("\n}")
 
@@ -580,6 +793,16 @@ func generateHandlerForPathItem(buf *bytes.Buffer, pathItem *openapi.PathItem) e
 			return err
 		}
 	}
+	if pathItem.Delete != nil {
+		if err := generateHandler(buf, pathItem.Delete); err != nil {
+			return err
+		}
+	}
+	if pathItem.Patch != nil {
+		if err := generateHandler(buf, pathItem.Patch); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
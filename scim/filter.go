@@ -0,0 +1,362 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Filter is a parsed SCIM filter expression (RFC 7644 section 3.4.2.2),
+// evaluated against a resource represented as a JSON object.
+type Filter interface {
+	Match(resource map[string]interface{}) bool
+}
+
+type andFilter struct{ left, right Filter }
+
+func (f andFilter) Match(r map[string]interface{}) bool { return f.left.Match(r) && f.right.Match(r) }
+
+type orFilter struct{ left, right Filter }
+
+func (f orFilter) Match(r map[string]interface{}) bool { return f.left.Match(r) || f.right.Match(r) }
+
+type notFilter struct{ filter Filter }
+
+func (f notFilter) Match(r map[string]interface{}) bool { return !f.filter.Match(r) }
+
+// attrFilter compares an attribute with a value.
+type attrFilter struct {
+	path  string
+	op    string
+	value interface{}
+}
+
+func (f attrFilter) Match(r map[string]interface{}) bool {
+	values := lookup(r, f.path)
+	if f.op == "pr" {
+		for _, v := range values {
+			if v != nil && v != "" {
+				return true
+			}
+		}
+		return false
+	}
+	for _, v := range values {
+		if compare(v, f.op, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// valuePathFilter matches if any element of a multi-valued
+// attribute matches the filter, e.g. emails[type eq "work"].
+type valuePathFilter struct {
+	attr   string
+	filter Filter
+}
+
+func (f valuePathFilter) Match(r map[string]interface{}) bool {
+	for _, elem := range elements(r, f.attr) {
+		if f.filter.Match(elem) {
+			return true
+		}
+	}
+	return false
+}
+
+// getAttr returns the attribute of the object.
+// Attribute names are case-insensitive.
+func getAttr(obj map[string]interface{}, name string) (string, interface{}, bool) {
+	if v, ok := obj[name]; ok {
+		return name, v, true
+	}
+	for k, v := range obj {
+		if strings.EqualFold(k, name) {
+			return k, v, true
+		}
+	}
+	return "", nil, false
+}
+
+// elements returns the elements of the multi-valued complex attribute.
+func elements(r map[string]interface{}, attr string) []map[string]interface{} {
+	_, v, ok := getAttr(r, attr)
+	if !ok {
+		return nil
+	}
+	list, _ := v.([]interface{})
+	var elems []map[string]interface{}
+	for _, e := range list {
+		if m, ok := e.(map[string]interface{}); ok {
+			elems = append(elems, m)
+		}
+	}
+	return elems
+}
+
+// lookup returns the values of the attribute path such as "userName",
+// "meta.created" or "emails.value". A multi-valued complex attribute
+// without sub-attribute refers its "value" sub-attribute.
+func lookup(r map[string]interface{}, path string) []interface{} {
+	attr, sub := path, ""
+	if i := strings.Index(path, "."); i >= 0 {
+		attr, sub = path[:i], path[i+1:]
+	}
+	_, v, ok := getAttr(r, attr)
+	if !ok {
+		return nil
+	}
+	var values []interface{}
+	switch v := v.(type) {
+	case []interface{}:
+		if sub == "" {
+			sub = "value"
+		}
+		for _, e := range v {
+			if m, ok := e.(map[string]interface{}); ok {
+				if _, sv, ok := getAttr(m, sub); ok {
+					values = append(values, sv)
+				}
+			} else {
+				values = append(values, e)
+			}
+		}
+	case map[string]interface{}:
+		if _, sv, ok := getAttr(v, sub); ok {
+			values = append(values, sv)
+		}
+	default:
+		if sub == "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// compare compares the attribute value with the filter value.
+// Strings are compared case-insensitively,
+// and as date time if both are RFC 3339 format.
+func compare(attr interface{}, op string, value interface{}) bool {
+	switch a := attr.(type) {
+	case string:
+		v, ok := value.(string)
+		if !ok {
+			return false
+		}
+		if at, err := time.Parse(time.RFC3339, a); err == nil {
+			if vt, err := time.Parse(time.RFC3339, v); err == nil {
+				return compareOrder(op, at.Before(vt), at.Equal(vt))
+			}
+		}
+		a, v = strings.ToLower(a), strings.ToLower(v)
+		switch op {
+		case "co":
+			return strings.Contains(a, v)
+		case "sw":
+			return strings.HasPrefix(a, v)
+		case "ew":
+			return strings.HasSuffix(a, v)
+		}
+		return compareOrder(op, a < v, a == v)
+	case float64:
+		v, ok := value.(float64)
+		if !ok {
+			return false
+		}
+		return compareOrder(op, a < v, a == v)
+	case bool:
+		v, ok := value.(bool)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return a == v
+		case "ne":
+			return a != v
+		}
+	case nil:
+		switch op {
+		case "eq":
+			return value == nil
+		case "ne":
+			return value != nil
+		}
+	}
+	return false
+}
+
+func compareOrder(op string, less, equal bool) bool {
+	switch op {
+	case "eq":
+		return equal
+	case "ne":
+		return !equal
+	case "gt":
+		return !less && !equal
+	case "ge":
+		return !less
+	case "lt":
+		return less
+	case "le":
+		return less || equal
+	}
+	return false
+}
+
+// ParseFilter parses SCIM filter expression.
+func ParseFilter(s string) (Filter, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected token in filter: %s", p.peek())
+	}
+	return f, nil
+}
+
+// tokenize splits the filter into words, quoted strings and brackets.
+// Quoted strings are kept with quotes.
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string in filter")
+			}
+			tokens = append(tokens, s[i:j+1])
+			i = j + 1
+		default:
+			j := i
+			for ; j < len(s) && !strings.ContainsRune(` ()[]"`, rune(s[j])); j++ {
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *filterParser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *filterParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *filterParser) expect(t string) error {
+	if got := p.next(); got != t {
+		return fmt.Errorf("%s is expected in filter, but got %q", t, got)
+	}
+	return nil
+}
+
+func (p *filterParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orFilter{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (Filter, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "and") {
+		p.next()
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = andFilter{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseFactor() (Filter, error) {
+	switch t := p.next(); {
+	case t == "":
+		return nil, fmt.Errorf("unexpected end of filter")
+	case t == "(":
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return f, p.expect(")")
+	case strings.EqualFold(t, "not"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return notFilter{f}, p.expect(")")
+	default:
+		if p.peek() == "[" {
+			p.next()
+			f, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return valuePathFilter{attr: t, filter: f}, p.expect("]")
+		}
+		op := strings.ToLower(p.next())
+		switch op {
+		case "pr":
+			return attrFilter{path: t, op: op}, nil
+		case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+		default:
+			return nil, fmt.Errorf("unknown operator in filter: %q", op)
+		}
+		v := p.next()
+		var value interface{}
+		if err := json.Unmarshal([]byte(v), &value); err != nil {
+			return nil, fmt.Errorf("invalid value in filter: %s", v)
+		}
+		return attrFilter{path: t, op: op, value: value}, nil
+	}
+}
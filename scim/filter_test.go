@@ -0,0 +1,122 @@
+package scim_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/scim"
+)
+
+const resource = `{
+  "userName": "alice",
+  "active": true,
+  "emails": [
+    {"value": "alice@example.com", "type": "work", "primary": true},
+    {"value": "alice@example.org", "type": "home"}
+  ],
+  "meta": {"created": "2018-04-01T00:00:00Z"}
+}`
+
+func TestFilter(t *testing.T) {
+	var r map[string]interface{}
+	if err := json.Unmarshal([]byte(resource), &r); err != nil {
+		t.Fatal(err)
+	}
+	candidates := []struct {
+		filter   string
+		expected bool
+	}{
+		{`userName eq "alice"`, true},
+		{`UserName eq "ALICE"`, true},
+		{`userName ne "alice"`, false},
+		{`userName sw "al"`, true},
+		{`userName ew "ce"`, true},
+		{`userName co "lic"`, true},
+		{`userName co "bob"`, false},
+		{`active eq true`, true},
+		{`displayName pr`, false},
+		{`emails pr`, true},
+		{`emails eq "alice@example.org"`, true},
+		{`emails.value ew "example.net"`, false},
+		{`emails[type eq "work" and value co "example.com"]`, true},
+		{`emails[type eq "work" and value co "example.org"]`, false},
+		{`meta.created gt "2018-01-01T00:00:00Z"`, true},
+		{`meta.created lt "2018-01-01T00:00:00+09:00"`, false},
+		{`userName eq "bob" or active eq true`, true},
+		{`userName eq "bob" or userName eq "carol" and active eq true`, false},
+		{`not (userName eq "bob")`, true},
+		{`(userName eq "bob" or userName eq "alice") and active eq true`, true},
+	}
+	for _, c := range candidates {
+		f, err := scim.ParseFilter(c.filter)
+		if err != nil {
+			t.Errorf("%s (filter: %s)", err, c.filter)
+			continue
+		}
+		if out := f.Match(r); out != c.expected {
+			t.Errorf("%t != %t (filter: %s)", out, c.expected, c.filter)
+		}
+	}
+}
+
+func TestParseFilterError(t *testing.T) {
+	candidates := []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName foo "alice"`,
+		`userName eq alice`,
+		`userName eq "alice`,
+		`(userName eq "alice"`,
+		`not userName eq "alice"`,
+		`emails[type eq "work"`,
+		`userName eq "alice" and`,
+	}
+	for _, c := range candidates {
+		if _, err := scim.ParseFilter(c); err == nil {
+			t.Errorf("error should be returned (filter: %s)", c)
+		}
+	}
+}
+
+func TestUserFilter(t *testing.T) {
+	created := time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC)
+	candidates := []struct {
+		filter   string
+		expected repository.UserFilter
+		rest     bool
+	}{
+		{`userName eq "Alice"`, repository.UserFilter{ID: "alice"}, false},
+		{`userName sw "al"`, repository.UserFilter{IDPrefix: "al"}, false},
+		{`emails.value eq "alice@example.com"`, repository.UserFilter{Email: "alice@example.com"}, false},
+		{`meta.created ge "2018-04-01T00:00:00Z" and meta.created lt "2018-04-01T09:00:00+09:00"`, repository.UserFilter{CreatedAfter: created, CreatedBefore: created}, false},
+		{`meta.created gt "2018-04-01T00:00:00Z"`, repository.UserFilter{CreatedAfter: created.Add(time.Second)}, false},
+		{`userName eq "alice" and active eq true`, repository.UserFilter{ID: "alice"}, true},
+		{`userName eq "alice" or userName eq "bob"`, repository.UserFilter{}, true},
+		{`userName co "lic"`, repository.UserFilter{}, true},
+	}
+	policy := entity.DefaultUserIDPolicy()
+	policy.Profile = entity.UserIDCaseMapped
+	for _, c := range candidates {
+		f, err := scim.ParseFilter(c.filter)
+		if err != nil {
+			t.Errorf("%s (filter: %s)", err, c.filter)
+			continue
+		}
+		out, rest := scim.UserFilter(f, policy)
+		if !out.CreatedAfter.Equal(c.expected.CreatedAfter) || !out.CreatedBefore.Equal(c.expected.CreatedBefore) {
+			t.Errorf("%v != %v (filter: %s)", out, c.expected, c.filter)
+			continue
+		}
+		out.CreatedAfter, out.CreatedBefore = c.expected.CreatedAfter, c.expected.CreatedBefore
+		if out != c.expected {
+			t.Errorf("%v != %v (filter: %s)", out, c.expected, c.filter)
+		}
+		if (rest != nil) != c.rest {
+			t.Errorf("unexpected rest of filter: %v (filter: %s)", rest, c.filter)
+		}
+	}
+}
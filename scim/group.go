@@ -0,0 +1,248 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/nasa9084/ident/domain/entity"
+)
+
+// Group is a SCIM group resource.
+// id and displayName are the group name of ident.
+type Group struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []Reference `json:"members,omitempty"`
+	Meta        *Meta       `json:"meta,omitempty"`
+}
+
+// newGroup builds a SCIM group resource.
+func newGroup(r *http.Request, g entity.Group) Group {
+	res := Group{
+		Schemas:     []string{SchemaGroup},
+		ID:          g.Name,
+		DisplayName: g.Name,
+	}
+	for _, m := range g.Members {
+		res.Members = append(res.Members, Reference{Value: m, Ref: location(r, "Users", m)})
+	}
+	res.Meta = &Meta{
+		ResourceType: "Group",
+		Location:     location(r, "Groups", g.Name),
+	}
+	res.Meta.Version = version(res)
+	return res
+}
+
+func (h *handler) findGroup(r *http.Request, name string) (entity.Group, Group, error) {
+	g, err := h.env.GetGroupRepository().FindGroup(r.Context(), name)
+	if err != nil {
+		return g, Group{}, err
+	}
+	return g, newGroup(r, g), nil
+}
+
+func (h *handler) getGroup(w http.ResponseWriter, r *http.Request) {
+	_, res, err := h.findGroup(r, mux.Vars(r)["id"])
+	if err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	if !checkPrecondition(w, r, res.Meta.Version) {
+		return
+	}
+	w.Header().Set("ETag", res.Meta.Version)
+	render(w, http.StatusOK, res)
+}
+
+func (h *handler) listGroups(w http.ResponseWriter, r *http.Request) {
+	q, err := parseQuery(r)
+	if err != nil {
+		renderError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	names, err := h.env.GetGroupRepository().ListGroups(r.Context())
+	if err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	var resources []interface{}
+	for _, name := range names {
+		_, res, err := h.findGroup(r, name)
+		if err != nil {
+			renderRepoError(w, err)
+			return
+		}
+		resources = append(resources, res)
+	}
+	render(w, http.StatusOK, q.page(resources))
+}
+
+// members returns the user IDs of the members.
+// All members must be registered users.
+func (h *handler) members(r *http.Request, res Group) ([]string, error) {
+	var ids []string
+	for _, m := range res.Members {
+		u, err := h.env.GetUserRepository().FindUserByID(r.Context(), m.Value)
+		if err != nil || u.Pending {
+			return nil, fmt.Errorf("member not found: %s", m.Value)
+		}
+		ids = append(ids, u.ID)
+	}
+	return ids, nil
+}
+
+// saveMembers updates the members of the group to the given users.
+func (h *handler) saveMembers(r *http.Request, g entity.Group, members []string) error {
+	ctx := r.Context()
+	repo := h.env.GetGroupRepository()
+	current := map[string]bool{}
+	for _, m := range g.Members {
+		current[m] = true
+	}
+	next := map[string]bool{}
+	for _, m := range members {
+		next[m] = true
+		if !current[m] {
+			if err := repo.AddMember(ctx, g.Name, m); err != nil {
+				return err
+			}
+		}
+	}
+	for _, m := range g.Members {
+		if !next[m] {
+			if err := repo.RemoveMember(ctx, g.Name, m); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (h *handler) createGroup(w http.ResponseWriter, r *http.Request) {
+	var res Group
+	if err := decode(r, &res); err != nil {
+		renderError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	if res.DisplayName == "" {
+		renderError(w, http.StatusBadRequest, "invalidValue", "displayName is required")
+		return
+	}
+	members, err := h.members(r, res)
+	if err != nil {
+		renderError(w, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	if err := h.env.GetGroupRepository().CreateGroup(r.Context(), res.DisplayName); err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	if err := h.saveMembers(r, entity.Group{Name: res.DisplayName}, members); err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	_, created, err := h.findGroup(r, res.DisplayName)
+	if err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	w.Header().Set("Location", created.Meta.Location)
+	w.Header().Set("ETag", created.Meta.Version)
+	render(w, http.StatusCreated, created)
+}
+
+// updateGroup replaces the members of the group with the resource
+// built by modify from the current resource.
+func (h *handler) updateGroup(w http.ResponseWriter, r *http.Request, modify func(current Group) (Group, string, error)) {
+	g, current, err := h.findGroup(r, mux.Vars(r)["id"])
+	if err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	if !checkPrecondition(w, r, current.Meta.Version) {
+		return
+	}
+	res, scimType, err := modify(current)
+	if err != nil {
+		renderError(w, http.StatusBadRequest, scimType, err.Error())
+		return
+	}
+	if res.DisplayName != g.Name {
+		renderError(w, http.StatusBadRequest, "mutability", "displayName cannot be changed")
+		return
+	}
+	members, err := h.members(r, res)
+	if err != nil {
+		renderError(w, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	if err := h.saveMembers(r, g, members); err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	_, updated, err := h.findGroup(r, g.Name)
+	if err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	w.Header().Set("ETag", updated.Meta.Version)
+	render(w, http.StatusOK, updated)
+}
+
+func (h *handler) replaceGroup(w http.ResponseWriter, r *http.Request) {
+	h.updateGroup(w, r, func(Group) (Group, string, error) {
+		var res Group
+		if err := decode(r, &res); err != nil {
+			return res, "invalidSyntax", err
+		}
+		return res, "", nil
+	})
+}
+
+func (h *handler) patchGroup(w http.ResponseWriter, r *http.Request) {
+	h.updateGroup(w, r, func(current Group) (Group, string, error) {
+		var req PatchRequest
+		if err := decode(r, &req); err != nil {
+			return Group{}, "invalidSyntax", err
+		}
+		current.Meta = nil
+		m := toMap(current)
+		if err := applyPatch(m, req.Operations, SchemaGroup); err != nil {
+			if pe, ok := err.(patchError); ok {
+				return Group{}, pe.scimType, err
+			}
+			return Group{}, "", err
+		}
+		b, err := json.Marshal(m)
+		if err != nil {
+			return Group{}, "invalidValue", err
+		}
+		var res Group
+		if err := json.Unmarshal(b, &res); err != nil {
+			return Group{}, "invalidValue", err
+		}
+		return res, "", nil
+	})
+}
+
+// deleteGroup deletes the group. Roles granted through the group are
+// revoked from the members.
+func (h *handler) deleteGroup(w http.ResponseWriter, r *http.Request) {
+	g, current, err := h.findGroup(r, mux.Vars(r)["id"])
+	if err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	if !checkPrecondition(w, r, current.Meta.Version) {
+		return
+	}
+	if err := h.env.GetGroupRepository().DeleteGroup(r.Context(), g.Name); err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,200 @@
+package scim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PatchRequest is a request of PATCH operation (RFC 7644 section 3.5.2).
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation is an operation in PATCH request.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// patchError is an error of PATCH operation with SCIM error type.
+type patchError struct {
+	scimType string
+	detail   string
+}
+
+func (e patchError) Error() string { return e.detail }
+
+func invalidPath(path string) error {
+	return patchError{"invalidPath", fmt.Sprintf("invalid path: %q", path)}
+}
+
+// applyPatch applies the operations to the resource represented as
+// a JSON object. schema is the URI of the resource schema, which may
+// prefix the paths.
+func applyPatch(resource map[string]interface{}, ops []PatchOperation, schema string) error {
+	if len(ops) > maxPatchOperations {
+		return patchError{"tooMany", "too many operations"}
+	}
+	for _, op := range ops {
+		path := strings.TrimPrefix(op.Path, schema+":")
+		var err error
+		switch strings.ToLower(op.Op) {
+		case "add":
+			err = patchSet(resource, path, op.Value, true)
+		case "replace":
+			err = patchSet(resource, path, op.Value, false)
+		case "remove":
+			err = patchRemove(resource, path)
+		default:
+			err = patchError{"invalidSyntax", fmt.Sprintf("unknown operation: %q", op.Op)}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parsePath splits the path into the attribute, the value filter
+// and the sub-attribute, e.g. `emails[type eq "work"].value`.
+func parsePath(path string) (attr string, filter Filter, sub string, err error) {
+	attr = path
+	if i := strings.Index(path, "["); i >= 0 {
+		j := strings.LastIndex(path, "]")
+		if j < i {
+			return "", nil, "", invalidPath(path)
+		}
+		if filter, err = ParseFilter(path[i+1 : j]); err != nil {
+			return "", nil, "", invalidPath(path)
+		}
+		attr, sub = path[:i], strings.TrimPrefix(path[j+1:], ".")
+	} else if i := strings.Index(path, "."); i >= 0 {
+		attr, sub = path[:i], path[i+1:]
+	}
+	if attr == "" {
+		return "", nil, "", invalidPath(path)
+	}
+	return attr, filter, sub, nil
+}
+
+// patchSet adds or replaces the value at the path.
+// If add is true, values are appended to multi-valued attributes.
+func patchSet(resource map[string]interface{}, path string, value interface{}, add bool) error {
+	if path == "" {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return patchError{"invalidValue", "value must be an object if path is not specified"}
+		}
+		for k, v := range obj {
+			if err := patchSet(resource, k, v, add); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	attr, filter, sub, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	key, current, exists := getAttr(resource, attr)
+	if !exists {
+		key = attr
+	}
+	if filter != nil {
+		matched := false
+		for _, elem := range elements(resource, attr) {
+			if !filter.Match(elem) {
+				continue
+			}
+			matched = true
+			if sub != "" {
+				subKey, _, ok := getAttr(elem, sub)
+				if !ok {
+					subKey = sub
+				}
+				elem[subKey] = value
+				continue
+			}
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				return patchError{"invalidValue", "value must be an object"}
+			}
+			for k, v := range obj {
+				elem[k] = v
+			}
+		}
+		if !matched {
+			return patchError{"noTarget", fmt.Sprintf("no value matches the path: %q", path)}
+		}
+		return nil
+	}
+	if sub != "" {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			obj = map[string]interface{}{}
+			resource[key] = obj
+		}
+		subKey, _, ok := getAttr(obj, sub)
+		if !ok {
+			subKey = sub
+		}
+		obj[subKey] = value
+		return nil
+	}
+	if list, ok := current.([]interface{}); ok && add {
+		if values, ok := value.([]interface{}); ok {
+			resource[key] = append(list, values...)
+		} else {
+			resource[key] = append(list, value)
+		}
+		return nil
+	}
+	resource[key] = value
+	return nil
+}
+
+// patchRemove removes the value at the path.
+func patchRemove(resource map[string]interface{}, path string) error {
+	if path == "" {
+		return patchError{"noTarget", "path is required for remove operation"}
+	}
+	attr, filter, sub, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	key, current, exists := getAttr(resource, attr)
+	if !exists {
+		return nil
+	}
+	if filter != nil {
+		list, _ := current.([]interface{})
+		remaining := []interface{}{}
+		for _, e := range list {
+			elem, ok := e.(map[string]interface{})
+			if !ok || !filter.Match(elem) {
+				remaining = append(remaining, e)
+				continue
+			}
+			if sub != "" {
+				if subKey, _, ok := getAttr(elem, sub); ok {
+					delete(elem, subKey)
+				}
+				remaining = append(remaining, elem)
+			}
+		}
+		resource[key] = remaining
+		return nil
+	}
+	if sub != "" {
+		if obj, ok := current.(map[string]interface{}); ok {
+			if subKey, _, ok := getAttr(obj, sub); ok {
+				delete(obj, subKey)
+			}
+		}
+		return nil
+	}
+	delete(resource, key)
+	return nil
+}
@@ -0,0 +1,261 @@
+// Package scim implements SCIM 2.0 provisioning endpoints
+// (RFC 7643, RFC 7644) for users and groups.
+package scim
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/nasa9084/ident/infra"
+)
+
+// schema URIs
+const (
+	SchemaUser          = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup         = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp       = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError         = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaSPConfig      = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	contentType         = "application/scim+json"
+	defaultCount        = 100
+	maxCount            = 1000
+	maxPatchOperations  = 100
+	maxRequestBodyBytes = 1 << 20
+)
+
+// BindRoutes binds SCIM endpoints to the router,
+// which should be a subrouter for /scim/v2.
+// All endpoints require the admin token as a bearer token.
+func BindRoutes(router *mux.Router, env *infra.Environment) {
+	h := &handler{env: env}
+	router.HandleFunc(`/ServiceProviderConfig`, h.auth(h.serviceProviderConfig)).Methods(http.MethodGet)
+	router.HandleFunc(`/Users`, h.auth(h.listUsers)).Methods(http.MethodGet)
+	router.HandleFunc(`/Users`, h.auth(h.createUser)).Methods(http.MethodPost)
+	router.HandleFunc(`/Users/{id}`, h.auth(h.getUser)).Methods(http.MethodGet)
+	router.HandleFunc(`/Users/{id}`, h.auth(h.replaceUser)).Methods(http.MethodPut)
+	router.HandleFunc(`/Users/{id}`, h.auth(h.patchUser)).Methods(http.MethodPatch)
+	router.HandleFunc(`/Users/{id}`, h.auth(h.deleteUser)).Methods(http.MethodDelete)
+	router.HandleFunc(`/Groups`, h.auth(h.listGroups)).Methods(http.MethodGet)
+	router.HandleFunc(`/Groups`, h.auth(h.createGroup)).Methods(http.MethodPost)
+	router.HandleFunc(`/Groups/{id}`, h.auth(h.getGroup)).Methods(http.MethodGet)
+	router.HandleFunc(`/Groups/{id}`, h.auth(h.replaceGroup)).Methods(http.MethodPut)
+	router.HandleFunc(`/Groups/{id}`, h.auth(h.patchGroup)).Methods(http.MethodPatch)
+	router.HandleFunc(`/Groups/{id}`, h.auth(h.deleteGroup)).Methods(http.MethodDelete)
+}
+
+type handler struct {
+	env *infra.Environment
+}
+
+// Error is a SCIM error response.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func renderError(w http.ResponseWriter, status int, scimType, detail string) {
+	render(w, status, Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+func render(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// auth checks the admin token given as a bearer token.
+func (h *handler) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "Bearer "
+		authorization := r.Header.Get("Authorization")
+		if h.env.AdminToken == "" {
+			renderError(w, http.StatusForbidden, "", "admin token is disabled")
+			return
+		}
+		if !strings.HasPrefix(authorization, prefix) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ident"`)
+			renderError(w, http.StatusUnauthorized, "", "bearer token is required")
+			return
+		}
+		token := strings.TrimPrefix(authorization, prefix)
+		if subtle.ConstantTimeCompare([]byte(h.env.AdminToken), []byte(token)) != 1 {
+			renderError(w, http.StatusForbidden, "", "admin token invalid")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+		next(w, r)
+	}
+}
+
+// decode decodes request body into v.
+func decode(r *http.Request, v interface{}) error {
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// Meta is the metadata of a resource.
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	Location     string `json:"location"`
+	Version      string `json:"version"`
+}
+
+// version returns a weak ETag of the resource, computed from
+// its representation without meta.version.
+func version(v interface{}) string {
+	b, _ := json.Marshal(v)
+	sum := sha256.Sum256(b)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// checkPrecondition checks If-Match and If-None-Match headers against
+// the current version of the resource. It renders the error response
+// and returns false if the precondition fails.
+func checkPrecondition(w http.ResponseWriter, r *http.Request, current string) bool {
+	if m := r.Header.Get("If-Match"); m != "" && m != "*" && !containsETag(m, current) {
+		renderError(w, http.StatusPreconditionFailed, "", "resource has been modified")
+		return false
+	}
+	if m := r.Header.Get("If-None-Match"); r.Method == http.MethodGet && m != "" && (m == "*" || containsETag(m, current)) {
+		w.Header().Set("ETag", current)
+		w.WriteHeader(http.StatusNotModified)
+		return false
+	}
+	return true
+}
+
+func containsETag(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(t), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// location returns the URL of the resource.
+func location(r *http.Request, resourceType, id string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/scim/v2/" + resourceType + "/" + id
+}
+
+// ListResponse is a response of query.
+type ListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+// query is parameters of a query request.
+type query struct {
+	filter     Filter
+	startIndex int
+	count      int
+}
+
+// parseQuery parses filter, startIndex and count parameters.
+func parseQuery(r *http.Request) (query, error) {
+	q := query{startIndex: 1, count: defaultCount}
+	params := r.URL.Query()
+	if s := params.Get("filter"); s != "" {
+		f, err := ParseFilter(s)
+		if err != nil {
+			return q, err
+		}
+		q.filter = f
+	}
+	if s := params.Get("startIndex"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return q, err
+		}
+		if n > 1 {
+			q.startIndex = n
+		}
+	}
+	if s := params.Get("count"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return q, err
+		}
+		switch {
+		case n < 0:
+			q.count = 0
+		case n > maxCount:
+			q.count = maxCount
+		default:
+			q.count = n
+		}
+	}
+	return q, nil
+}
+
+// page filters and paginates the resources.
+func (q query) page(resources []interface{}) ListResponse {
+	var matched []interface{}
+	for _, res := range resources {
+		if q.filter == nil || q.filter.Match(toMap(res)) {
+			matched = append(matched, res)
+		}
+	}
+	resp := ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: len(matched),
+		StartIndex:   q.startIndex,
+		Resources:    []interface{}{},
+	}
+	if start := q.startIndex - 1; start < len(matched) {
+		end := start + q.count
+		if end > len(matched) {
+			end = len(matched)
+		}
+		resp.Resources = matched[start:end]
+	}
+	resp.ItemsPerPage = len(resp.Resources)
+	return resp
+}
+
+// toMap returns the JSON representation of the resource as a map.
+func toMap(v interface{}) map[string]interface{} {
+	b, _ := json.Marshal(v)
+	var m map[string]interface{}
+	json.Unmarshal(b, &m)
+	return m
+}
+
+func (h *handler) serviceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	supported := func(b bool) map[string]interface{} { return map[string]interface{}{"supported": b} }
+	render(w, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{SchemaSPConfig},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": maxCount},
+		"changePassword": supported(true),
+		"sort":           supported(false),
+		"etag":           supported(true),
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "the admin token of ident",
+		}},
+	})
+}
@@ -0,0 +1,603 @@
+package scim
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/generator"
+	"github.com/nasa9084/ident/util"
+)
+
+// deprovisionReason is the status reason of users deactivated via SCIM.
+const deprovisionReason = "deactivated via SCIM"
+
+// profile attributes mapped onto SCIM core user attributes
+const (
+	attrDisplayName = "display_name"
+	attrLocale      = "locale"
+	attrTimezone    = "timezone"
+)
+
+// Bool is a boolean which also accepts "true" and "false" strings,
+// since some SCIM clients send booleans as strings.
+type Bool bool
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	v, err := strconv.ParseBool(strings.ToLower(s))
+	if err != nil {
+		return fmt.Errorf("invalid boolean: %s", data)
+	}
+	*b = Bool(v)
+	return nil
+}
+
+// MultiValue is an element of multi-valued attribute such as emails.
+type MultiValue struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary Bool   `json:"primary,omitempty"`
+}
+
+// Reference is a reference to another resource.
+type Reference struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+// User is a SCIM user resource.
+// id and userName are the user ID of ident.
+type User struct {
+	Schemas      []string     `json:"schemas"`
+	ID           string       `json:"id,omitempty"`
+	UserName     string       `json:"userName"`
+	DisplayName  string       `json:"displayName,omitempty"`
+	Locale       string       `json:"locale,omitempty"`
+	Timezone     string       `json:"timezone,omitempty"`
+	Active       *Bool        `json:"active,omitempty"`
+	Password     string       `json:"password,omitempty"`
+	Emails       []MultiValue `json:"emails,omitempty"`
+	PhoneNumbers []MultiValue `json:"phoneNumbers,omitempty"`
+	Groups       []Reference  `json:"groups,omitempty"`
+	Meta         *Meta        `json:"meta,omitempty"`
+}
+
+// primary returns the primary value, or the first value.
+func primary(values []MultiValue) string {
+	for _, v := range values {
+		if v.Primary {
+			return v.Value
+		}
+	}
+	if len(values) > 0 {
+		return values[0].Value
+	}
+	return ""
+}
+
+// profile returns profile attributes mapped from the user.
+func (u User) profile() entity.Profile {
+	return entity.Profile{
+		attrDisplayName: u.DisplayName,
+		attrLocale:      u.Locale,
+		attrTimezone:    u.Timezone,
+	}
+}
+
+func (h *handler) profileSchema() entity.ProfileSchema {
+	if h.env.ProfileSchema == nil {
+		return entity.DefaultProfileSchema()
+	}
+	return h.env.ProfileSchema
+}
+
+// newUser builds a SCIM user resource.
+func newUser(r *http.Request, u entity.User, p entity.Profile, groups []string) User {
+	active := Bool(!u.StatusAt(generator.TimeFunc()).Blocked())
+	res := User{
+		Schemas:     []string{SchemaUser},
+		ID:          u.ID,
+		UserName:    u.ID,
+		DisplayName: p[attrDisplayName],
+		Locale:      p[attrLocale],
+		Timezone:    p[attrTimezone],
+		Active:      &active,
+	}
+	if u.Email != "" {
+		res.Emails = []MultiValue{{Value: u.Email, Primary: true}}
+	}
+	if u.PhoneNumber != "" {
+		res.PhoneNumbers = []MultiValue{{Value: u.PhoneNumber, Primary: true}}
+	}
+	for _, g := range groups {
+		res.Groups = append(res.Groups, Reference{Value: g, Ref: location(r, "Groups", g), Display: g})
+	}
+	res.Meta = &Meta{
+		ResourceType: "User",
+		Location:     location(r, "Users", u.ID),
+	}
+	if !u.CreatedAt.IsZero() {
+		res.Meta.Created = u.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	res.Meta.Version = version(res)
+	return res
+}
+
+// findUser returns the user and its SCIM representation.
+// Pending registrations are not provisioned users, so they are not found.
+func (h *handler) findUser(r *http.Request, id string) (entity.User, User, error) {
+	ctx := r.Context()
+	u, err := h.env.GetUserRepository().FindUserByID(ctx, id)
+	if err != nil {
+		return u, User{}, err
+	}
+	if u.Pending {
+		return u, User{}, sql.ErrNoRows
+	}
+	p, err := h.env.GetProfileRepository().FindProfile(ctx, u.ID)
+	if err != nil {
+		return u, User{}, err
+	}
+	groups, err := h.env.GetGroupRepository().FindGroups(ctx, u.ID)
+	if err != nil {
+		return u, User{}, err
+	}
+	return u, newUser(r, u, p, groups), nil
+}
+
+// renderRepoError renders the error returned from repositories.
+func renderRepoError(w http.ResponseWriter, err error) {
+	switch err {
+	case sql.ErrNoRows, repository.ErrGroupNotFound:
+		renderError(w, http.StatusNotFound, "", "resource not found")
+	case repository.ErrUserExists, repository.ErrUserDeleted, repository.ErrGroupExists, repository.ErrEmailExists:
+		renderError(w, http.StatusConflict, "uniqueness", err.Error())
+	default:
+		renderError(w, http.StatusInternalServerError, "", err.Error())
+	}
+}
+
+func (h *handler) getUser(w http.ResponseWriter, r *http.Request) {
+	_, res, err := h.findUser(r, mux.Vars(r)["id"])
+	if err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	if !checkPrecondition(w, r, res.Meta.Version) {
+		return
+	}
+	w.Header().Set("ETag", res.Meta.Version)
+	render(w, http.StatusOK, res)
+}
+
+// UserFilter splits the SCIM filter into the condition which can be
+// evaluated by the user repository and the rest, which is nil if the
+// whole filter can be evaluated by the repository.
+// userName is pushed down only if the policy case-maps user IDs,
+// since SCIM compares it case-insensitively.
+func UserFilter(f Filter, policy entity.UserIDPolicy) (repository.UserFilter, Filter) {
+	var filter repository.UserFilter
+	rest := splitUserFilter(f, policy, &filter)
+	return filter, rest
+}
+
+func splitUserFilter(f Filter, policy entity.UserIDPolicy, filter *repository.UserFilter) Filter {
+	switch f := f.(type) {
+	case nil:
+		return nil
+	case andFilter:
+		left := splitUserFilter(f.left, policy, filter)
+		right := splitUserFilter(f.right, policy, filter)
+		switch {
+		case left == nil:
+			return right
+		case right == nil:
+			return left
+		}
+		return andFilter{left, right}
+	case attrFilter:
+		if pushUserCondition(f, policy, filter) {
+			return nil
+		}
+	}
+	return f
+}
+
+// pushUserCondition sets the condition of the filter to the user filter
+// if it can be evaluated by the repository.
+func pushUserCondition(f attrFilter, policy entity.UserIDPolicy, filter *repository.UserFilter) bool {
+	value, ok := f.value.(string)
+	if !ok {
+		return false
+	}
+	switch path := strings.ToLower(f.path); {
+	case path == "username" || path == "id":
+		if policy.Profile != entity.UserIDCaseMapped {
+			return false
+		}
+		normalized, err := policy.Normalize(value)
+		if err != nil {
+			return false
+		}
+		switch {
+		case f.op == "eq" && filter.ID == "":
+			filter.ID = normalized
+			return true
+		case f.op == "sw" && filter.IDPrefix == "" && normalized == strings.ToLower(value):
+			filter.IDPrefix = normalized
+			return true
+		}
+	case (path == "emails" || path == "emails.value") && f.op == "eq" && filter.Email == "":
+		filter.Email = value
+		return true
+	case path == "meta.created":
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return false
+		}
+		// meta.created is compared in seconds
+		t = t.Truncate(time.Second)
+		switch {
+		case f.op == "ge" && filter.CreatedAfter.IsZero():
+			filter.CreatedAfter = t
+		case f.op == "gt" && filter.CreatedAfter.IsZero():
+			filter.CreatedAfter = t.Add(time.Second)
+		case f.op == "lt" && filter.CreatedBefore.IsZero():
+			filter.CreatedBefore = t
+		case f.op == "le" && filter.CreatedBefore.IsZero():
+			filter.CreatedBefore = t.Add(time.Second)
+		default:
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// listUsers lists provisioned users. The filter and pagination are
+// evaluated by the repository as far as possible, and the rest of
+// the filter is evaluated on each user resource.
+func (h *handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	q, err := parseQuery(r)
+	if err != nil {
+		renderError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	ctx := r.Context()
+	repo := h.env.GetUserRepository()
+	filter, rest := UserFilter(q.filter, h.env.GetUserIDPolicy())
+	verified := true
+	filter.Verified = &verified
+	if rest != nil {
+		// matched users are known only after building resources
+		q.filter = rest
+		resources, err := h.allUserResources(r, filter)
+		if err != nil {
+			renderRepoError(w, err)
+			return
+		}
+		render(w, http.StatusOK, q.page(resources))
+		return
+	}
+
+	total, err := repo.CountUsers(ctx, filter)
+	if err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	resources := []interface{}{}
+	if q.count > 0 {
+		filter.Offset = q.startIndex - 1
+		filter.Limit = q.count
+		users, err := repo.ListUsers(ctx, filter)
+		if err != nil {
+			renderRepoError(w, err)
+			return
+		}
+		if resources, err = h.userResources(r, users); err != nil {
+			renderRepoError(w, err)
+			return
+		}
+	}
+	render(w, http.StatusOK, ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   q.startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// userResources builds SCIM resources of given users.
+func (h *handler) userResources(r *http.Request, users []entity.User) ([]interface{}, error) {
+	resources := []interface{}{}
+	for _, u := range users {
+		_, res, err := h.findUser(r, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+// allUserResources builds SCIM resources of all users matching
+// the filter, listing them by batch.
+func (h *handler) allUserResources(r *http.Request, filter repository.UserFilter) ([]interface{}, error) {
+	resources := []interface{}{}
+	filter.Limit = maxCount
+	for {
+		users, err := h.env.GetUserRepository().ListUsers(r.Context(), filter)
+		if err != nil {
+			return nil, err
+		}
+		batch, err := h.userResources(r, users)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, batch...)
+		if len(users) < filter.Limit {
+			return resources, nil
+		}
+		filter.After = users[len(users)-1].ID
+	}
+}
+
+// validateUser validates the user resource to be saved,
+// and normalizes its primary email address, which is the only one saved.
+func (h *handler) validateUser(res *User) error {
+	if res.UserName == "" {
+		return fmt.Errorf("userName is required")
+	}
+	if email := primary(res.Emails); email != "" {
+		normalized, err := h.env.EmailPolicy.Normalize(email)
+		if err != nil {
+			return err
+		}
+		res.Emails = []MultiValue{{Value: normalized, Primary: true}}
+	}
+	return h.profileSchema().ValidateUpdate(res.profile(), true)
+}
+
+// applyUser applies the SCIM user resource to the user, and returns
+// whether the user has been deactivated.
+// Email addresses provisioned by SCIM clients are trusted as verified.
+func applyUser(u entity.User, res User) (entity.User, bool) {
+	u.Email = primary(res.Emails)
+	u.EmailVerified = u.Email != ""
+	if phone := primary(res.PhoneNumbers); phone != u.PhoneNumber {
+		u.PhoneNumber = phone
+		u.PhoneVerified = false
+	}
+	if res.Password != "" {
+		u.Password = util.Hash(res.Password, u.ID)
+	}
+	deactivated := false
+	if res.Active != nil {
+		blocked := u.StatusAt(generator.TimeFunc()).Blocked()
+		switch {
+		case !bool(*res.Active) && !blocked:
+			u.Status = entity.StatusSuspended
+			u.StatusReason = deprovisionReason
+			u.StatusExpiresAt = time.Time{}
+			deactivated = true
+		case bool(*res.Active) && blocked:
+			u.Status = entity.StatusActive
+			u.StatusReason = ""
+			u.StatusExpiresAt = time.Time{}
+		}
+	}
+	return u, deactivated
+}
+
+// saveUser applies the SCIM user resource to the user, and revokes all
+// sessions of the user if the user has been deactivated.
+func (h *handler) saveUser(ctx context.Context, u entity.User, res User) error {
+	u, deactivated := applyUser(u, res)
+	if err := h.env.GetUserRepository().UpdateUser(ctx, u); err != nil {
+		return err
+	}
+	if err := h.env.GetProfileRepository().UpdateProfile(ctx, u.ID, res.profile()); err != nil {
+		return err
+	}
+	if deactivated {
+		return h.env.GetSessionRepository().DeleteUserSessions(ctx, u.ID)
+	}
+	return nil
+}
+
+// createUser provisions a new user. The user has completed registration
+// but must enroll TOTP at first login. If password is not given,
+// the user cannot login until the password is set.
+func (h *handler) createUser(w http.ResponseWriter, r *http.Request) {
+	var res User
+	if err := decode(r, &res); err != nil {
+		renderError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	if err := h.validateUser(&res); err != nil {
+		renderError(w, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	userName, err := h.env.GetUserIDPolicy().NormalizeNew(res.UserName)
+	if err != nil {
+		renderError(w, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	res.UserName = userName
+	ctx := r.Context()
+	repo := h.env.GetUserRepository()
+	exists, err := repo.ExistsUser(ctx, res.UserName)
+	if err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	if exists {
+		renderRepoError(w, repository.ErrUserExists)
+		return
+	}
+	// the email address is checked before provisioning, and then
+	// stored with the user atomically on promotion
+	if email := primary(res.Emails); email != "" {
+		_, err := repo.FindUserByEmail(ctx, email)
+		switch {
+		case err == nil:
+			renderRepoError(w, repository.ErrEmailExists)
+			return
+		case err != sql.ErrNoRows:
+			renderRepoError(w, err)
+			return
+		}
+	}
+	password := res.Password
+	if password == "" {
+		password = generator.NewSecret()
+	}
+	if _, err := repo.CreateUser(ctx, res.UserName, password, h.env.GetRegistrationTTL()); err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	u, err := repo.FindUserByID(ctx, res.UserName)
+	if err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	res.Password = ""
+	u, _ = applyUser(u, res)
+	u.TOTPResetRequired = true
+	if err := repo.Verify(ctx, u); err != nil {
+		if err == repository.ErrEmailExists {
+			// verified by another user meanwhile
+			if derr := repo.DeleteRegistration(ctx, u); derr != nil {
+				renderRepoError(w, derr)
+				return
+			}
+		}
+		renderRepoError(w, err)
+		return
+	}
+	if err := h.env.GetProfileRepository().UpdateProfile(ctx, u.ID, res.profile()); err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	// the temporary session for registration is not used
+	if err := h.env.GetSessionRepository().DeleteUserSessions(ctx, u.ID); err != nil {
+		renderRepoError(w, err)
+		return
+	}
+
+	_, created, err := h.findUser(r, u.ID)
+	if err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	w.Header().Set("Location", created.Meta.Location)
+	w.Header().Set("ETag", created.Meta.Version)
+	render(w, http.StatusCreated, created)
+}
+
+// updateUser replaces the user with the resource built by modify
+// from the current resource.
+func (h *handler) updateUser(w http.ResponseWriter, r *http.Request, modify func(current User) (User, string, error)) {
+	u, current, err := h.findUser(r, mux.Vars(r)["id"])
+	if err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	if !checkPrecondition(w, r, current.Meta.Version) {
+		return
+	}
+	res, scimType, err := modify(current)
+	if err != nil {
+		renderError(w, http.StatusBadRequest, scimType, err.Error())
+		return
+	}
+	if userName, err := h.env.GetUserIDPolicy().Normalize(res.UserName); err != nil || userName != u.ID {
+		renderError(w, http.StatusBadRequest, "mutability", "userName cannot be changed")
+		return
+	}
+	if err := h.validateUser(&res); err != nil {
+		renderError(w, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	if err := h.saveUser(r.Context(), u, res); err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	_, updated, err := h.findUser(r, u.ID)
+	if err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	w.Header().Set("ETag", updated.Meta.Version)
+	render(w, http.StatusOK, updated)
+}
+
+func (h *handler) replaceUser(w http.ResponseWriter, r *http.Request) {
+	h.updateUser(w, r, func(User) (User, string, error) {
+		var res User
+		if err := decode(r, &res); err != nil {
+			return res, "invalidSyntax", err
+		}
+		return res, "", nil
+	})
+}
+
+func (h *handler) patchUser(w http.ResponseWriter, r *http.Request) {
+	h.updateUser(w, r, func(current User) (User, string, error) {
+		var req PatchRequest
+		if err := decode(r, &req); err != nil {
+			return User{}, "invalidSyntax", err
+		}
+		current.Meta = nil
+		m := toMap(current)
+		if err := applyPatch(m, req.Operations, SchemaUser); err != nil {
+			if pe, ok := err.(patchError); ok {
+				return User{}, pe.scimType, err
+			}
+			return User{}, "", err
+		}
+		b, err := json.Marshal(m)
+		if err != nil {
+			return User{}, "invalidValue", err
+		}
+		var res User
+		if err := json.Unmarshal(b, &res); err != nil {
+			return User{}, "invalidValue", err
+		}
+		return res, "", nil
+	})
+}
+
+// deleteUser deprovisions the user. The user ID cannot be used again.
+func (h *handler) deleteUser(w http.ResponseWriter, r *http.Request) {
+	u, current, err := h.findUser(r, mux.Vars(r)["id"])
+	if err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	if !checkPrecondition(w, r, current.Meta.Version) {
+		return
+	}
+	ctx := r.Context()
+	if err := h.env.GetUserRepository().DeleteUser(ctx, u); err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	if err := h.env.GetSessionRepository().DeleteUserSessions(ctx, u.ID); err != nil {
+		renderRepoError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,9 +1,79 @@
-DROP TABLE IF EXISTS users;
+-- User IDs and email addresses are compared case-insensitively but
+-- accent-sensitively, as CITEXT in PostgreSQL. This requires MySQL 8.0.
 
 CREATE TABLE IF NOT EXISTS users (
-        user_id VARCHAR(128) NOT NULL,
+        user_id VARCHAR(128) COLLATE utf8mb4_0900_as_ci NOT NULL,
         password VARCHAR(512) NOT NULL,
         totp_secret VARCHAR(512) NOT NULL,
-        email VARCHAR(256) NOT NULL,
+        pending_totp_secret VARCHAR(512) NOT NULL DEFAULT '',
+        secret_key_id VARCHAR(64) NOT NULL DEFAULT '',
+        totp_reset_required BOOLEAN NOT NULL DEFAULT FALSE,
+        email VARCHAR(256) COLLATE utf8mb4_0900_as_ci NOT NULL,
+        email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+        pending_email VARCHAR(256) NOT NULL DEFAULT '',
+        previous_email VARCHAR(512) NOT NULL DEFAULT '',
+        verified_email VARCHAR(256) COLLATE utf8mb4_0900_as_ci AS (IF(email_verified AND email <> '', email, NULL)) STORED,
+        phone_number VARCHAR(16) NOT NULL DEFAULT '',
+        phone_verified BOOLEAN NOT NULL DEFAULT FALSE,
+        created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        status VARCHAR(16) NOT NULL DEFAULT 'active',
+        status_reason VARCHAR(256) NOT NULL DEFAULT '',
+        status_expires_at DATETIME NULL,
+        PRIMARY KEY (user_id),
+        KEY (email),
+        UNIQUE KEY (verified_email),
+        KEY (created_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS user_roles (
+        user_id VARCHAR(128) COLLATE utf8mb4_0900_as_ci NOT NULL,
+        role VARCHAR(64) NOT NULL,
+        PRIMARY KEY (user_id, role)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS role_inheritance (
+        role VARCHAR(64) NOT NULL,
+        inherited_role VARCHAR(64) NOT NULL,
+        PRIMARY KEY (role, inherited_role)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS user_groups (
+        group_name VARCHAR(64) NOT NULL,
+        created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (group_name)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS group_members (
+        group_name VARCHAR(64) NOT NULL,
+        user_id VARCHAR(128) COLLATE utf8mb4_0900_as_ci NOT NULL,
+        PRIMARY KEY (group_name, user_id),
+        KEY (user_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS group_roles (
+        group_name VARCHAR(64) NOT NULL,
+        role VARCHAR(64) NOT NULL,
+        PRIMARY KEY (group_name, role)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS user_profiles (
+        user_id VARCHAR(128) COLLATE utf8mb4_0900_as_ci NOT NULL,
+        name VARCHAR(64) NOT NULL,
+        value VARCHAR(1024) NOT NULL,
+        PRIMARY KEY (user_id, name)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS deleted_users (
+        user_id VARCHAR(128) COLLATE utf8mb4_0900_as_ci NOT NULL,
+        deleted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
         PRIMARY KEY (user_id)
 ) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS invitations (
+        code_hash CHAR(128) NOT NULL,
+        max_uses INT NOT NULL DEFAULT 0,
+        uses INT NOT NULL DEFAULT 0,
+        expires_at DATETIME NULL,
+        created_at DATETIME NOT NULL,
+        PRIMARY KEY (code_hash)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,78 @@
+-- user IDs and email addresses are compared case-insensitively, as in MySQL
+CREATE EXTENSION IF NOT EXISTS citext;
+
+CREATE TABLE IF NOT EXISTS users (
+        user_id CITEXT NOT NULL,
+        password VARCHAR(512) NOT NULL,
+        totp_secret VARCHAR(512) NOT NULL,
+        pending_totp_secret VARCHAR(512) NOT NULL DEFAULT '',
+        secret_key_id VARCHAR(64) NOT NULL DEFAULT '',
+        totp_reset_required BOOLEAN NOT NULL DEFAULT FALSE,
+        email CITEXT NOT NULL,
+        email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+        pending_email VARCHAR(256) NOT NULL DEFAULT '',
+        previous_email VARCHAR(512) NOT NULL DEFAULT '',
+        phone_number VARCHAR(16) NOT NULL DEFAULT '',
+        phone_verified BOOLEAN NOT NULL DEFAULT FALSE,
+        created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        status VARCHAR(16) NOT NULL DEFAULT 'active',
+        status_reason VARCHAR(256) NOT NULL DEFAULT '',
+        status_expires_at TIMESTAMP WITH TIME ZONE NULL,
+        CONSTRAINT users_pkey PRIMARY KEY (user_id)
+);
+CREATE INDEX IF NOT EXISTS users_email_idx ON users (email);
+CREATE UNIQUE INDEX IF NOT EXISTS users_verified_email_key ON users (email) WHERE email_verified AND email <> '';
+CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+        user_id CITEXT NOT NULL,
+        role VARCHAR(64) NOT NULL,
+        PRIMARY KEY (user_id, role)
+);
+
+CREATE TABLE IF NOT EXISTS role_inheritance (
+        role VARCHAR(64) NOT NULL,
+        inherited_role VARCHAR(64) NOT NULL,
+        PRIMARY KEY (role, inherited_role)
+);
+
+CREATE TABLE IF NOT EXISTS user_groups (
+        group_name VARCHAR(64) NOT NULL,
+        created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (group_name)
+);
+
+CREATE TABLE IF NOT EXISTS group_members (
+        group_name VARCHAR(64) NOT NULL,
+        user_id CITEXT NOT NULL,
+        PRIMARY KEY (group_name, user_id)
+);
+CREATE INDEX IF NOT EXISTS group_members_user_id_idx ON group_members (user_id);
+
+CREATE TABLE IF NOT EXISTS group_roles (
+        group_name VARCHAR(64) NOT NULL,
+        role VARCHAR(64) NOT NULL,
+        PRIMARY KEY (group_name, role)
+);
+
+CREATE TABLE IF NOT EXISTS user_profiles (
+        user_id CITEXT NOT NULL,
+        name VARCHAR(64) NOT NULL,
+        value VARCHAR(1024) NOT NULL,
+        PRIMARY KEY (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS deleted_users (
+        user_id CITEXT NOT NULL,
+        deleted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS invitations (
+        code_hash CHAR(128) NOT NULL,
+        max_uses INT NOT NULL DEFAULT 0,
+        uses INT NOT NULL DEFAULT 0,
+        expires_at TIMESTAMP WITH TIME ZONE NULL,
+        created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+        PRIMARY KEY (code_hash)
+);
@@ -0,0 +1,90 @@
+-- Migrates the users table of the original schema, which has only
+-- user_id, password, totp_secret and email, to the current schema,
+-- and creates the tables added since then.
+--
+-- Users stored in RDB have verified their email address, so they are
+-- marked as verified. Verified email addresses must be unique, so resolve
+-- duplicates found by the following query before running this script:
+--
+--   SELECT email, COUNT(*) FROM users WHERE email <> '' GROUP BY email HAVING COUNT(*) > 1;
+--
+-- TOTP secrets are kept in plain text until ident users rekey is run.
+-- User IDs and email addresses are compared case-insensitively but
+-- accent-sensitively, as CITEXT in PostgreSQL. This requires MySQL 8.0.
+
+ALTER TABLE users
+        MODIFY COLUMN user_id VARCHAR(128) COLLATE utf8mb4_0900_as_ci NOT NULL,
+        MODIFY COLUMN email VARCHAR(256) COLLATE utf8mb4_0900_as_ci NOT NULL,
+        ADD COLUMN pending_totp_secret VARCHAR(512) NOT NULL DEFAULT '' AFTER totp_secret,
+        ADD COLUMN secret_key_id VARCHAR(64) NOT NULL DEFAULT '' AFTER pending_totp_secret,
+        ADD COLUMN totp_reset_required BOOLEAN NOT NULL DEFAULT FALSE AFTER secret_key_id,
+        ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE AFTER email,
+        ADD COLUMN pending_email VARCHAR(256) NOT NULL DEFAULT '' AFTER email_verified,
+        ADD COLUMN previous_email VARCHAR(512) NOT NULL DEFAULT '' AFTER pending_email,
+        ADD COLUMN phone_number VARCHAR(16) NOT NULL DEFAULT '' AFTER previous_email,
+        ADD COLUMN phone_verified BOOLEAN NOT NULL DEFAULT FALSE AFTER phone_number,
+        ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP AFTER phone_verified,
+        ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active' AFTER created_at,
+        ADD COLUMN status_reason VARCHAR(256) NOT NULL DEFAULT '' AFTER status,
+        ADD COLUMN status_expires_at DATETIME NULL AFTER status_reason;
+
+UPDATE users SET email_verified = TRUE WHERE email <> '';
+
+ALTER TABLE users
+        ADD COLUMN verified_email VARCHAR(256) COLLATE utf8mb4_0900_as_ci AS (IF(email_verified AND email <> '', email, NULL)) STORED AFTER previous_email,
+        ADD KEY (email),
+        ADD UNIQUE KEY (verified_email),
+        ADD KEY (created_at);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+        user_id VARCHAR(128) COLLATE utf8mb4_0900_as_ci NOT NULL,
+        role VARCHAR(64) NOT NULL,
+        PRIMARY KEY (user_id, role)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS role_inheritance (
+        role VARCHAR(64) NOT NULL,
+        inherited_role VARCHAR(64) NOT NULL,
+        PRIMARY KEY (role, inherited_role)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS user_groups (
+        group_name VARCHAR(64) NOT NULL,
+        created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (group_name)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS group_members (
+        group_name VARCHAR(64) NOT NULL,
+        user_id VARCHAR(128) COLLATE utf8mb4_0900_as_ci NOT NULL,
+        PRIMARY KEY (group_name, user_id),
+        KEY (user_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS group_roles (
+        group_name VARCHAR(64) NOT NULL,
+        role VARCHAR(64) NOT NULL,
+        PRIMARY KEY (group_name, role)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS user_profiles (
+        user_id VARCHAR(128) COLLATE utf8mb4_0900_as_ci NOT NULL,
+        name VARCHAR(64) NOT NULL,
+        value VARCHAR(1024) NOT NULL,
+        PRIMARY KEY (user_id, name)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS deleted_users (
+        user_id VARCHAR(128) COLLATE utf8mb4_0900_as_ci NOT NULL,
+        deleted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (user_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS invitations (
+        code_hash CHAR(128) NOT NULL,
+        max_uses INT NOT NULL DEFAULT 0,
+        uses INT NOT NULL DEFAULT 0,
+        expires_at DATETIME NULL,
+        created_at DATETIME NOT NULL,
+        PRIMARY KEY (code_hash)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
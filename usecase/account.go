@@ -0,0 +1,94 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	totp "github.com/nasa9084/go-totp"
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/usecase/input"
+	"github.com/nasa9084/ident/usecase/output"
+)
+
+// deleteUser deletes the user and all sessions of the user.
+func deleteUser(ctx context.Context, env *infra.Environment, u entity.User) (int, error) {
+	if err := env.GetUserRepository().DeleteUser(ctx, u); err != nil {
+		return statusFromError(err), err
+	}
+	if err := env.GetSessionRepository().DeleteUserSessions(ctx, u.ID); err != nil {
+		return statusFromError(err), err
+	}
+	return http.StatusOK, nil
+}
+
+// DeleteAccount deletes the session user.
+// Both password and TOTP token are required to confirm the deletion.
+func DeleteAccount(ctx context.Context, req input.DeleteAccountRequest, env *infra.Environment) output.Response {
+	var resp output.DeleteAccountResponse
+
+	u, err := env.GetUserRepository().FindUserBySessionID(ctx, req.SessionID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if status, retryAfter, err := checkLockout(ctx, env, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		resp.RetryAfter = retryAfter
+		return resp
+	}
+	ok, err := verifyPassword(ctx, env, u, req.Password)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if !ok {
+		resp.Status, resp.Err = authFailed(ctx, env, u.ID, errors.New("password invalid"))
+		return resp
+	}
+	if !u.TOTPVerified || totp.New(u.TOTPSecret).GenerateString() != req.Token {
+		resp.Status, resp.Err = authFailed(ctx, env, u.ID, errors.New("token invalid"))
+		return resp
+	}
+
+	if status, err := deleteUser(ctx, env, u); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// DeleteUser deletes the user by admin.
+func DeleteUser(ctx context.Context, req input.DeleteUserRequest, env *infra.Environment) output.Response {
+	var resp output.DeleteUserResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	u, err := env.GetUserRepository().FindUserByID(ctx, req.UserID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		if err == sql.ErrNoRows {
+			resp.Err = errors.New("user not found")
+			resp.Status = http.StatusNotFound
+		}
+		return resp
+	}
+	if status, err := deleteUser(ctx, env, u); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
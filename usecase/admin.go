@@ -0,0 +1,226 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/usecase/input"
+	"github.com/nasa9084/ident/usecase/output"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// authorizeAdmin authorizes a request to administrative API.
+// The request must have the admin token, or the session of a user who
+// has admin role, directly or through groups and inheritance, and has completed the authentication flow.
+func authorizeAdmin(ctx context.Context, env *infra.Environment, token, sessid string) (int, error) {
+	if token != "" {
+		return authorizeAdminToken(env, token)
+	}
+	u, err := env.GetUserRepository().FindUserBySessionID(ctx, sessid)
+	if err != nil {
+		return statusFromError(err), err
+	}
+	if status, err := checkStatus(u); err != nil {
+		return status, err
+	}
+	ac, err := env.GetSessionRepository().FindAuthContext(ctx, sessid)
+	if err != nil {
+		return statusFromError(err), err
+	}
+	if !authFlow(env).Completed(ac) {
+		return http.StatusUnauthorized, errors.New("authentication flow has not been completed")
+	}
+	authz, err := findAuthorization(ctx, env, u.ID)
+	if err != nil {
+		return statusFromError(err), err
+	}
+	if !authz.HasRole(entity.RoleAdmin) {
+		return http.StatusForbidden, errors.New("admin role is required")
+	}
+	return http.StatusOK, nil
+}
+
+// authorizeAdminToken checks given token is the admin token.
+func authorizeAdminToken(env *infra.Environment, token string) (int, error) {
+	if env.AdminToken == "" {
+		return http.StatusForbidden, errors.New("admin token is disabled")
+	}
+	if subtle.ConstantTimeCompare([]byte(env.AdminToken), []byte(token)) != 1 {
+		return http.StatusForbidden, errors.New("admin token invalid")
+	}
+	return http.StatusOK, nil
+}
+
+// normalizeEmail returns the email address parsed by entity.ParseEmail,
+// or as is if it cannot be parsed, since such address never matches any user.
+func normalizeEmail(email string) string {
+	if email == "" {
+		return ""
+	}
+	normalized, err := entity.ParseEmail(email)
+	if err != nil {
+		return email
+	}
+	return normalized
+}
+
+// parseUserFilter parses query parameters of ListUsers into UserFilter.
+func parseUserFilter(req input.ListUsersRequest) (repository.UserFilter, error) {
+	filter := repository.UserFilter{
+		IDPrefix: req.IDPrefix,
+		Email:    normalizeEmail(req.Email),
+		After:    req.After,
+		Limit:    defaultListLimit,
+	}
+	if req.Verified != "" {
+		verified, err := strconv.ParseBool(req.Verified)
+		if err != nil {
+			return filter, errors.New("verified must be true or false")
+		}
+		filter.Verified = &verified
+	}
+	if req.CreatedAfter != "" {
+		t, err := time.Parse(time.RFC3339, req.CreatedAfter)
+		if err != nil {
+			return filter, errors.New("created_after must be RFC 3339 format")
+		}
+		filter.CreatedAfter = t
+	}
+	if req.CreatedBefore != "" {
+		t, err := time.Parse(time.RFC3339, req.CreatedBefore)
+		if err != nil {
+			return filter, errors.New("created_before must be RFC 3339 format")
+		}
+		filter.CreatedBefore = t
+	}
+	if req.Limit != "" {
+		limit, err := strconv.Atoi(req.Limit)
+		if err != nil || limit < 1 || maxListLimit < limit {
+			return filter, errors.New("limit must be between 1 and 1000")
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// ListUsers returns users matching the filter, including pending
+// registrations. Users are paginated by user ID.
+func ListUsers(ctx context.Context, req input.ListUsersRequest, env *infra.Environment) output.Response {
+	var resp output.ListUsersResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	filter, err := parseUserFilter(req)
+	if err != nil {
+		resp.Err = err
+		resp.Status = http.StatusBadRequest
+		return resp
+	}
+	users, err := env.GetUserRepository().ListUsers(ctx, filter)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Users = make([]output.User, len(users))
+	for i, u := range users {
+		resp.Users[i] = output.NewUser(u, nil)
+	}
+	if len(users) == filter.Limit {
+		resp.Next = users[len(users)-1].ID
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// GetUser returns the user including pending registration.
+func GetUser(ctx context.Context, req input.GetUserRequest, env *infra.Environment) output.Response {
+	var resp output.GetUserResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	u, err := env.GetUserRepository().FindUserByID(ctx, req.UserID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		if err == sql.ErrNoRows {
+			resp.Err = errors.New("user not found")
+			resp.Status = http.StatusNotFound
+		}
+		return resp
+	}
+	roles, err := env.GetRoleRepository().FindRoles(ctx, u.ID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.User = output.NewUser(u, roles)
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// GrantRole grants the role to the user.
+func GrantRole(ctx context.Context, req input.GrantRoleRequest, env *infra.Environment) output.Response {
+	var resp output.GrantRoleResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	u, err := env.GetUserRepository().FindUserByID(ctx, req.UserID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if u.Pending {
+		resp.Err = errors.New("registration of the user has not been completed")
+		resp.Status = http.StatusForbidden
+		return resp
+	}
+	if err := env.GetRoleRepository().AddRole(ctx, u.ID, req.Role); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// RevokeRole revokes the role from the user.
+func RevokeRole(ctx context.Context, req input.RevokeRoleRequest, env *infra.Environment) output.Response {
+	var resp output.RevokeRoleResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if err := env.GetRoleRepository().RemoveRole(ctx, normalizeUserID(env, req.UserID), req.Role); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/generator"
+	"github.com/nasa9084/ident/infra"
+)
+
+func authFlow(env *infra.Environment) entity.AuthFlow {
+	if len(env.AuthFlow) == 0 {
+		return entity.DefaultAuthFlow
+	}
+	return env.AuthFlow
+}
+
+// authStep is a result of an authentication step.
+type authStep struct {
+	sessionID string
+	// next is the methods for the next step separated by space.
+	// empty if the flow has been completed.
+	next string
+	// token is JWT token issued when the flow has been completed.
+	token string
+}
+
+// beginStep returns the authentication context of the session and
+// checks that the method is allowed at this step of the flow.
+// If sessid is empty, the user starts the flow from the first step.
+func beginStep(ctx context.Context, env *infra.Environment, u entity.User, sessid string, method entity.AuthMethod) (entity.AuthContext, int, error) {
+	if status, err := checkStatus(u); err != nil {
+		return nil, status, err
+	}
+	ac := entity.AuthContext{}
+	if sessid != "" {
+		su, err := env.GetUserRepository().FindUserBySessionID(ctx, sessid)
+		if err != nil {
+			return nil, statusFromError(err), err
+		}
+		if su.ID != u.ID {
+			return nil, http.StatusForbidden, errors.New("session belongs to another user")
+		}
+		if ac, err = env.GetSessionRepository().FindAuthContext(ctx, sessid); err != nil {
+			return nil, statusFromError(err), err
+		}
+	}
+	if !authFlow(env).Allows(ac, method) {
+		return nil, http.StatusForbidden, fmt.Errorf("authentication by %s is not allowed at this step", method)
+	}
+	return ac, http.StatusOK, nil
+}
+
+// completeStep records the method satisfied by the user into the session
+// and decides the next step. A new session is created if sessid is empty.
+// JWT token is issued when all steps of the flow have been completed.
+func completeStep(ctx context.Context, env *infra.Environment, u entity.User, sessid string, ac entity.AuthContext, method entity.AuthMethod) (authStep, int, error) {
+	var step authStep
+	if status, err := checkStatus(u); err != nil {
+		return step, status, err
+	}
+	if sessid == "" {
+		var err error
+		if sessid, err = env.GetUserRepository().CreateSession(u, env.GetSessionTTL()); err != nil {
+			return step, statusFromError(err), err
+		}
+	}
+	now := generator.TimeFunc()
+	if err := env.GetSessionRepository().AddAuthMethod(ctx, sessid, method, now); err != nil {
+		return step, statusFromError(err), err
+	}
+	if ac == nil {
+		ac = entity.AuthContext{}
+	}
+	ac[method] = now
+	step.sessionID = sessid
+
+	if next := authFlow(env).Next(ac); next != nil {
+		methods := make([]string, len(next))
+		for i, m := range next {
+			methods[i] = string(m)
+		}
+		step.next = strings.Join(methods, " ")
+		return step, http.StatusOK, nil
+	}
+	token, status, err := newToken(ctx, env, u, "", ac)
+	if err != nil {
+		return step, status, err
+	}
+	step.token = token
+	return step, http.StatusOK, nil
+}
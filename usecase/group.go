@@ -0,0 +1,187 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/usecase/input"
+	"github.com/nasa9084/ident/usecase/output"
+)
+
+// findAuthorization returns the groups of the user, and the roles granted
+// to the user directly or through the groups, expanded by role inheritance.
+func findAuthorization(ctx context.Context, env *infra.Environment, userID string) (entity.Authorization, error) {
+	var authz entity.Authorization
+	roles, err := env.GetRoleRepository().FindRoles(ctx, userID)
+	if err != nil {
+		return authz, err
+	}
+	groupRepo := env.GetGroupRepository()
+	groupRoles, err := groupRepo.FindGroupRoles(ctx, userID)
+	if err != nil {
+		return authz, err
+	}
+	inheritance, err := env.GetRoleRepository().FindInheritance(ctx)
+	if err != nil {
+		return authz, err
+	}
+	authz.Roles = inheritance.Expand(append(roles, groupRoles...))
+	if authz.Groups, err = groupRepo.FindGroups(ctx, userID); err != nil {
+		return authz, err
+	}
+	return authz, nil
+}
+
+// GetGroup returns the group with its roles and members.
+func GetGroup(ctx context.Context, req input.GetGroupRequest, env *infra.Environment) output.Response {
+	var resp output.GetGroupResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	g, err := env.GetGroupRepository().FindGroup(ctx, req.Group)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Group = output.NewGroup(g)
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// AddGroupMember adds the user to the group.
+// The group is created if it does not exist.
+func AddGroupMember(ctx context.Context, req input.AddGroupMemberRequest, env *infra.Environment) output.Response {
+	var resp output.AddGroupMemberResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	u, err := env.GetUserRepository().FindUserByID(ctx, req.UserID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		if err == sql.ErrNoRows {
+			resp.Err = errors.New("user not found")
+			resp.Status = http.StatusNotFound
+		}
+		return resp
+	}
+	if u.Pending {
+		resp.Err = errors.New("registration of the user has not been completed")
+		resp.Status = http.StatusForbidden
+		return resp
+	}
+	if err := env.GetGroupRepository().AddMember(ctx, req.Group, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// RemoveGroupMember removes the user from the group.
+func RemoveGroupMember(ctx context.Context, req input.RemoveGroupMemberRequest, env *infra.Environment) output.Response {
+	var resp output.RemoveGroupMemberResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if err := env.GetGroupRepository().RemoveMember(ctx, req.Group, normalizeUserID(env, req.UserID)); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// GrantGroupRole grants the role to all members of the group.
+// The group is created if it does not exist.
+func GrantGroupRole(ctx context.Context, req input.GrantGroupRoleRequest, env *infra.Environment) output.Response {
+	var resp output.GrantGroupRoleResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if err := env.GetGroupRepository().AddGroupRole(ctx, req.Group, req.Role); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// RevokeGroupRole revokes the role from the group.
+func RevokeGroupRole(ctx context.Context, req input.RevokeGroupRoleRequest, env *infra.Environment) output.Response {
+	var resp output.RevokeGroupRoleResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if err := env.GetGroupRepository().RemoveGroupRole(ctx, req.Group, req.Role); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// AddRoleInheritance makes users who have the role also have the inherited role.
+func AddRoleInheritance(ctx context.Context, req input.AddRoleInheritanceRequest, env *infra.Environment) output.Response {
+	var resp output.AddRoleInheritanceResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if req.Role == req.InheritedRole {
+		resp.Err = errors.New("role cannot inherit itself")
+		resp.Status = http.StatusBadRequest
+		return resp
+	}
+	if err := env.GetRoleRepository().AddInheritance(ctx, req.Role, req.InheritedRole); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// RemoveRoleInheritance removes the inheritance relation between the roles.
+func RemoveRoleInheritance(ctx context.Context, req input.RemoveRoleInheritanceRequest, env *infra.Environment) output.Response {
+	var resp output.RemoveRoleInheritanceResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if err := env.GetRoleRepository().RemoveInheritance(ctx, req.Role, req.InheritedRole); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
@@ -1,6 +1,7 @@
 package input_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/nasa9084/ident/usecase/input"
@@ -27,6 +28,20 @@ func TestRequestValidate(t *testing.T) {
 	t.Run("VerifyEmailRequest", testVerifyEmailValidate)
 	t.Run("AuthByTOTPRequest", testAuthByTOTPValidate)
 	t.Run("AuthByPasswordReqeust", testAuthByPasswordValidate)
+	t.Run("UpdatePhoneNumberRequest", testUpdatePhoneNumberValidate)
+	t.Run("AuthBySMSRequest", testAuthBySMSValidate)
+	t.Run("EnrollTOTPRequest", testEnrollTOTPValidate)
+	t.Run("ResetTOTPRequest", testResetTOTPValidate)
+	t.Run("UnlockUserRequest", testUnlockUserValidate)
+	t.Run("IssueTokenRequest", testIssueTokenValidate)
+	t.Run("ListUsersRequest", testListUsersValidate)
+	t.Run("GetUserRequest", testGetUserValidate)
+	t.Run("GrantRoleRequest", testGrantRoleValidate)
+	t.Run("DeleteAccountRequest", testDeleteAccountValidate)
+	t.Run("SuspendUserRequest", testSuspendUserValidate)
+	t.Run("UpdateProfileRequest", testUpdateProfileValidate)
+	t.Run("AddGroupMemberRequest", testAddGroupMemberValidate)
+	t.Run("CreateInvitationRequest", testCreateInvitationValidate)
 }
 
 func testCreateUserValidate(t *testing.T) {
@@ -91,7 +106,9 @@ func testVerifyEmailValidate(t *testing.T) {
 		request input.VerifyEmailRequest
 		hasErr  bool
 	}{
-		{input.VerifyEmailRequest{SessionID: "foo"}, false},
+		{input.VerifyEmailRequest{UserID: "foo", Token: "bar"}, false},
+		{input.VerifyEmailRequest{Token: "bar"}, true},
+		{input.VerifyEmailRequest{UserID: "foo"}, true},
 		{input.VerifyEmailRequest{}, true},
 	}
 	for _, c := range candidates {
@@ -105,6 +122,7 @@ func testAuthByTOTPValidate(t *testing.T) {
 		hasErr  bool
 	}{
 		{input.AuthByTOTPRequest{UserID: "foo", Token: "000000"}, false},
+		{input.AuthByTOTPRequest{UserID: "foo", Token: "000000", SessionID: "bar"}, false},
 		{input.AuthByTOTPRequest{UserID: "foo", Token: "abcdef"}, true},
 		{input.AuthByTOTPRequest{UserID: "foo", Token: "1"}, true},
 		{input.AuthByTOTPRequest{UserID: "foo"}, true},
@@ -123,8 +141,9 @@ func testAuthByPasswordValidate(t *testing.T) {
 		hasErr  bool
 	}{
 		{input.AuthByPasswordRequest{SessionID: "foo", Password: "bar"}, false},
+		{input.AuthByPasswordRequest{UserID: "foo", Password: "bar"}, false},
 		{input.AuthByPasswordRequest{SessionID: "foo"}, true},
-		{input.AuthByPasswordRequest{Password: "bar"}, true},
+		{input.AuthByPasswordRequest{UserID: "foo"}, true},
 		{input.AuthByPasswordRequest{}, true},
 	}
 	for _, c := range candidates {
@@ -132,8 +151,229 @@ func testAuthByPasswordValidate(t *testing.T) {
 	}
 }
 
+func testUpdatePhoneNumberValidate(t *testing.T) {
+	candidates := []struct {
+		request input.UpdatePhoneNumberRequest
+		hasErr  bool
+	}{
+		{input.UpdatePhoneNumberRequest{PhoneNumber: "+819012345678", SessionID: "bar"}, false},
+		{input.UpdatePhoneNumberRequest{PhoneNumber: "+819012345678"}, true},
+		{input.UpdatePhoneNumberRequest{SessionID: "bar"}, true},
+	}
+	for _, c := range candidates {
+		checkValidate(t, c.request, c.hasErr)
+	}
+}
+
+func testAuthBySMSValidate(t *testing.T) {
+	candidates := []struct {
+		request input.AuthBySMSRequest
+		hasErr  bool
+	}{
+		{input.AuthBySMSRequest{UserID: "foo", Token: "000000"}, false},
+		{input.AuthBySMSRequest{UserID: "foo", Token: "abcdef"}, true},
+		{input.AuthBySMSRequest{UserID: "foo", Token: "1"}, true},
+		{input.AuthBySMSRequest{UserID: "foo"}, true},
+		{input.AuthBySMSRequest{Token: "000000"}, true},
+		{input.AuthBySMSRequest{}, true},
+	}
+	for _, c := range candidates {
+		checkValidate(t, c.request, c.hasErr)
+	}
+}
+
+func testEnrollTOTPValidate(t *testing.T) {
+	candidates := []struct {
+		request input.EnrollTOTPRequest
+		hasErr  bool
+	}{
+		{input.EnrollTOTPRequest{Password: "foo", SessionID: "bar"}, false},
+		{input.EnrollTOTPRequest{Password: "foo"}, true},
+		{input.EnrollTOTPRequest{SessionID: "bar"}, true},
+	}
+	for _, c := range candidates {
+		checkValidate(t, c.request, c.hasErr)
+	}
+}
+
+func testResetTOTPValidate(t *testing.T) {
+	candidates := []struct {
+		request input.ResetTOTPRequest
+		hasErr  bool
+	}{
+		{input.ResetTOTPRequest{UserID: "foo", AdminToken: "bar"}, false},
+		{input.ResetTOTPRequest{UserID: "foo", SessionID: "bar"}, false},
+		{input.ResetTOTPRequest{UserID: "foo"}, true},
+		{input.ResetTOTPRequest{AdminToken: "bar"}, true},
+	}
+	for _, c := range candidates {
+		checkValidate(t, c.request, c.hasErr)
+	}
+}
+
+func testUnlockUserValidate(t *testing.T) {
+	candidates := []struct {
+		request input.UnlockUserRequest
+		hasErr  bool
+	}{
+		{input.UnlockUserRequest{UserID: "foo", AdminToken: "bar"}, false},
+		{input.UnlockUserRequest{UserID: "foo"}, true},
+		{input.UnlockUserRequest{AdminToken: "bar"}, true},
+	}
+	for _, c := range candidates {
+		checkValidate(t, c.request, c.hasErr)
+	}
+}
+
+func testIssueTokenValidate(t *testing.T) {
+	candidates := []struct {
+		request input.IssueTokenRequest
+		hasErr  bool
+	}{
+		{input.IssueTokenRequest{SessionID: "foo"}, false},
+		{input.IssueTokenRequest{AMR: "otp", MaxAge: "300", SessionID: "foo"}, false},
+		{input.IssueTokenRequest{MaxAge: "5m", SessionID: "foo"}, true},
+		{input.IssueTokenRequest{AMR: "otp"}, true},
+	}
+	for _, c := range candidates {
+		checkValidate(t, c.request, c.hasErr)
+	}
+}
+
+func testListUsersValidate(t *testing.T) {
+	candidates := []struct {
+		request input.ListUsersRequest
+		hasErr  bool
+	}{
+		{input.ListUsersRequest{AdminToken: "foo"}, false},
+		{input.ListUsersRequest{SessionID: "foo", IDPrefix: "bar"}, false},
+		{input.ListUsersRequest{IDPrefix: "bar"}, true},
+	}
+	for _, c := range candidates {
+		checkValidate(t, c.request, c.hasErr)
+	}
+}
+
+func testGetUserValidate(t *testing.T) {
+	candidates := []struct {
+		request input.GetUserRequest
+		hasErr  bool
+	}{
+		{input.GetUserRequest{UserID: "foo", AdminToken: "bar"}, false},
+		{input.GetUserRequest{UserID: "foo", SessionID: "bar"}, false},
+		{input.GetUserRequest{UserID: "foo"}, true},
+		{input.GetUserRequest{AdminToken: "bar"}, true},
+	}
+	for _, c := range candidates {
+		checkValidate(t, c.request, c.hasErr)
+	}
+}
+
+func testGrantRoleValidate(t *testing.T) {
+	candidates := []struct {
+		request input.GrantRoleRequest
+		hasErr  bool
+	}{
+		{input.GrantRoleRequest{UserID: "foo", Role: "admin", AdminToken: "bar"}, false},
+		{input.GrantRoleRequest{UserID: "foo", AdminToken: "bar"}, true},
+		{input.GrantRoleRequest{Role: "admin", AdminToken: "bar"}, true},
+		{input.GrantRoleRequest{UserID: "foo", Role: "admin"}, true},
+	}
+	for _, c := range candidates {
+		checkValidate(t, c.request, c.hasErr)
+	}
+}
+
+func testDeleteAccountValidate(t *testing.T) {
+	candidates := []struct {
+		request input.DeleteAccountRequest
+		hasErr  bool
+	}{
+		{input.DeleteAccountRequest{Password: "foo", Token: "000000", SessionID: "bar"}, false},
+		{input.DeleteAccountRequest{Password: "foo", Token: "abcdef", SessionID: "bar"}, true},
+		{input.DeleteAccountRequest{Password: "foo", SessionID: "bar"}, true},
+		{input.DeleteAccountRequest{Token: "000000", SessionID: "bar"}, true},
+		{input.DeleteAccountRequest{Password: "foo", Token: "000000"}, true},
+	}
+	for _, c := range candidates {
+		checkValidate(t, c.request, c.hasErr)
+	}
+}
+
+func testSuspendUserValidate(t *testing.T) {
+	candidates := []struct {
+		request input.SuspendUserRequest
+		hasErr  bool
+	}{
+		{input.SuspendUserRequest{UserID: "foo", AdminToken: "bar"}, false},
+		{input.SuspendUserRequest{UserID: "foo", Reason: "spam", SessionID: "bar"}, false},
+		{input.SuspendUserRequest{UserID: "foo", Reason: strings.Repeat("a", 257), AdminToken: "bar"}, true},
+		{input.SuspendUserRequest{AdminToken: "bar"}, true},
+		{input.SuspendUserRequest{UserID: "foo"}, true},
+	}
+	for _, c := range candidates {
+		checkValidate(t, c.request, c.hasErr)
+	}
+}
+
+func testUpdateProfileValidate(t *testing.T) {
+	candidates := []struct {
+		request input.UpdateProfileRequest
+		hasErr  bool
+	}{
+		{input.UpdateProfileRequest{Profile: input.Profile{"locale": "ja"}, SessionID: "foo"}, false},
+		{input.UpdateProfileRequest{Profile: input.Profile{}, SessionID: "foo"}, false},
+		{input.UpdateProfileRequest{SessionID: "foo"}, true},
+		{input.UpdateProfileRequest{Profile: input.Profile{"locale": "ja"}}, true},
+	}
+	for _, c := range candidates {
+		checkValidate(t, c.request, c.hasErr)
+	}
+}
+
+func testAddGroupMemberValidate(t *testing.T) {
+	candidates := []struct {
+		request input.AddGroupMemberRequest
+		hasErr  bool
+	}{
+		{input.AddGroupMemberRequest{Group: "staff", UserID: "foo", AdminToken: "bar"}, false},
+		{input.AddGroupMemberRequest{Group: "staff", UserID: "foo", SessionID: "bar"}, false},
+		{input.AddGroupMemberRequest{UserID: "foo", AdminToken: "bar"}, true},
+		{input.AddGroupMemberRequest{Group: "staff", AdminToken: "bar"}, true},
+		{input.AddGroupMemberRequest{Group: "staff", UserID: "foo"}, true},
+	}
+	for _, c := range candidates {
+		checkValidate(t, c.request, c.hasErr)
+	}
+}
+
+func testCreateInvitationValidate(t *testing.T) {
+	candidates := []struct {
+		request input.CreateInvitationRequest
+		hasErr  bool
+	}{
+		{input.CreateInvitationRequest{AdminToken: "bar"}, false},
+		{input.CreateInvitationRequest{MaxUses: 1, ExpiresAt: "2018-01-02T03:04:05Z", SessionID: "bar"}, false},
+		{input.CreateInvitationRequest{MaxUses: 1}, true},
+	}
+	for _, c := range candidates {
+		checkValidate(t, c.request, c.hasErr)
+	}
+}
+
 const sessid = "foobarbaz"
 
+func TestSetArgs(t *testing.T) {
+	req := input.TOTPQRCodeRequest{}
+	req.SetQueryArgs(map[string]string{"size": "128", "level": "H"})
+	req.SetHeaderArgs(map[string]string{"Accept": "image/svg+xml"})
+	expected := input.TOTPQRCodeRequest{Size: "128", Level: "H", Accept: "image/svg+xml"}
+	if req != expected {
+		t.Errorf("%+v != %+v", req, expected)
+		return
+	}
+}
+
 func TestSetSessionID(t *testing.T) {
 	t.Run("TOTPQRCode", testTOTPQRCodeSetSessionID)
 	t.Run("VerifyTOTP", testVerifyTOTPSetSessionID)
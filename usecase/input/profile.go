@@ -0,0 +1,4 @@
+package input
+
+// Profile is a set of profile attributes keyed by attribute name.
+type Profile map[string]string
@@ -25,25 +25,47 @@ type PathArgsRequest interface {
 	SetPathArgs(map[string]string)
 }
 
+type QueryArgsRequest interface {
+	Request
+	SetQueryArgs(map[string]string)
+}
+
+type HeaderArgsRequest interface {
+	Request
+	SetHeaderArgs(map[string]string)
+}
+
+type AdminRequest interface {
+	Request
+	SetAdminToken(string)
+}
+
 type VerifyEmailRequest struct {
-	SessionID string `json:"-"`
+	UserID string `json:"-"`
+
+	Token string `json:"-"`
 }
 
 func (r VerifyEmailRequest) Validate() error {
 	switch {
-	case r.SessionID == "":
-		return errors.New("sessid is required")
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.Token == "":
+		return errors.New("token is required")
 	}
 	return nil
 }
 
 func (r *VerifyEmailRequest) SetPathArgs(args map[string]string) {
-	r.SessionID = args[`sessid`]
+	r.UserID = args[`user_id`]
+	r.Token = args[`token`]
 }
 
 type AuthByTOTPRequest struct {
 	UserID string `json:"user_id"`
 	Token  string `json:"token"`
+
+	SessionID string `json:"-"`
 }
 
 func (r AuthByTOTPRequest) Validate() error {
@@ -60,7 +82,12 @@ func (r AuthByTOTPRequest) Validate() error {
 	return nil
 }
 
+func (r *AuthByTOTPRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
 type AuthByPasswordRequest struct {
+	UserID   string `json:"user_id"`
 	Password string `json:"password"`
 
 	SessionID string `json:"-"`
@@ -68,8 +95,6 @@ type AuthByPasswordRequest struct {
 
 func (r AuthByPasswordRequest) Validate() error {
 	switch {
-	case r.SessionID == "":
-		return errors.New("authorization header is required")
 	case r.Password == "":
 		return errors.New("password is required ")
 	}
@@ -97,8 +122,9 @@ func (r *ExistsUserRequest) SetPathArgs(args map[string]string) {
 }
 
 type CreateUserRequest struct {
-	UserID   string `json:"user_id"`
-	Password string `json:"password"`
+	UserID         string `json:"user_id"`
+	Password       string `json:"password"`
+	InvitationCode string `json:"invitation_code"`
 }
 
 func (r CreateUserRequest) Validate() error {
@@ -113,6 +139,12 @@ func (r CreateUserRequest) Validate() error {
 
 type TOTPQRCodeRequest struct {
 	SessionID string `json:"-"`
+
+	Size string `json:"-"`
+
+	Level string `json:"-"`
+
+	Accept string `json:"-"`
 }
 
 func (r TOTPQRCodeRequest) Validate() error {
@@ -127,6 +159,15 @@ func (r *TOTPQRCodeRequest) SetSessionID(sessid string) {
 	r.SessionID = sessid
 }
 
+func (r *TOTPQRCodeRequest) SetQueryArgs(args map[string]string) {
+	r.Size = args[`size`]
+	r.Level = args[`level`]
+}
+
+func (r *TOTPQRCodeRequest) SetHeaderArgs(args map[string]string) {
+	r.Accept = args[`Accept`]
+}
+
 type VerifyTOTPRequest struct {
 	Token string `json:"token"`
 
@@ -170,3 +211,1015 @@ func (r UpdateEmailRequest) Validate() error {
 func (r *UpdateEmailRequest) SetSessionID(sessid string) {
 	r.SessionID = sessid
 }
+
+type UpdatePhoneNumberRequest struct {
+	PhoneNumber string `json:"phone_number"`
+
+	SessionID string `json:"-"`
+}
+
+func (r UpdatePhoneNumberRequest) Validate() error {
+	switch {
+	case r.SessionID == "":
+		return errors.New("authorization header is required")
+	case r.PhoneNumber == "":
+		return errors.New("phone_number is required ")
+	}
+	return nil
+}
+
+func (r *UpdatePhoneNumberRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+type VerifyPhoneNumberRequest struct {
+	Token string `json:"token"`
+
+	SessionID string `json:"-"`
+}
+
+func (r VerifyPhoneNumberRequest) Validate() error {
+	switch {
+	case r.SessionID == "":
+		return errors.New("authorization header is required")
+	case r.Token == "":
+		return errors.New("token is required ")
+	case len(r.Token) != 6:
+		return errors.New("length of token is not valid")
+	case !util.IsDigit(r.Token):
+		return errors.New("token must be digit")
+	}
+	return nil
+}
+
+func (r *VerifyPhoneNumberRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+type SendSMSCodeRequest struct {
+	UserID string `json:"user_id"`
+}
+
+func (r SendSMSCodeRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required ")
+	}
+	return nil
+}
+
+type AuthBySMSRequest struct {
+	UserID string `json:"user_id"`
+	Token  string `json:"token"`
+
+	SessionID string `json:"-"`
+}
+
+func (r AuthBySMSRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required ")
+	case r.Token == "":
+		return errors.New("token is required ")
+	case len(r.Token) != 6:
+		return errors.New("length of token is not valid")
+	case !util.IsDigit(r.Token):
+		return errors.New("token must be digit")
+	}
+	return nil
+}
+
+func (r *AuthBySMSRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+type EnrollTOTPRequest struct {
+	Password string `json:"password"`
+
+	SessionID string `json:"-"`
+}
+
+func (r EnrollTOTPRequest) Validate() error {
+	switch {
+	case r.SessionID == "":
+		return errors.New("authorization header is required")
+	case r.Password == "":
+		return errors.New("password is required ")
+	}
+	return nil
+}
+
+func (r *EnrollTOTPRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+type AuthForTOTPResetRequest struct {
+	UserID   string `json:"user_id"`
+	Password string `json:"password"`
+}
+
+func (r AuthForTOTPResetRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required ")
+	case r.Password == "":
+		return errors.New("password is required ")
+	}
+	return nil
+}
+
+type ResetTOTPRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	UserID string `json:"-"`
+}
+
+func (r ResetTOTPRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *ResetTOTPRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *ResetTOTPRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *ResetTOTPRequest) SetPathArgs(args map[string]string) {
+	r.UserID = args[`user_id`]
+}
+
+type UnlockUserRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	UserID string `json:"-"`
+}
+
+func (r UnlockUserRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *UnlockUserRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *UnlockUserRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *UnlockUserRequest) SetPathArgs(args map[string]string) {
+	r.UserID = args[`user_id`]
+}
+
+type IssueTokenRequest struct {
+	AMR      string `json:"amr"`
+	MaxAge   string `json:"max_age"`
+	Audience string `json:"audience"`
+
+	SessionID string `json:"-"`
+}
+
+func (r IssueTokenRequest) Validate() error {
+	switch {
+	case r.SessionID == "":
+		return errors.New("authorization header is required")
+	case !util.IsDigit(r.MaxAge):
+		return errors.New("max_age must be digit")
+	}
+	return nil
+}
+
+func (r *IssueTokenRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+type GrantRoleRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	UserID string `json:"-"`
+
+	Role string `json:"-"`
+}
+
+func (r GrantRoleRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.Role == "":
+		return errors.New("role is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *GrantRoleRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *GrantRoleRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *GrantRoleRequest) SetPathArgs(args map[string]string) {
+	r.UserID = args[`user_id`]
+	r.Role = args[`role`]
+}
+
+type RevokeRoleRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	UserID string `json:"-"`
+
+	Role string `json:"-"`
+}
+
+func (r RevokeRoleRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.Role == "":
+		return errors.New("role is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *RevokeRoleRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *RevokeRoleRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *RevokeRoleRequest) SetPathArgs(args map[string]string) {
+	r.UserID = args[`user_id`]
+	r.Role = args[`role`]
+}
+
+type ListUsersRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	IDPrefix string `json:"-"`
+
+	Email string `json:"-"`
+
+	Verified string `json:"-"`
+
+	CreatedAfter string `json:"-"`
+
+	CreatedBefore string `json:"-"`
+
+	After string `json:"-"`
+
+	Limit string `json:"-"`
+}
+
+func (r ListUsersRequest) Validate() error {
+	switch {
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *ListUsersRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *ListUsersRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *ListUsersRequest) SetQueryArgs(args map[string]string) {
+	r.IDPrefix = args[`prefix`]
+	r.Email = args[`email`]
+	r.Verified = args[`verified`]
+	r.CreatedAfter = args[`created_after`]
+	r.CreatedBefore = args[`created_before`]
+	r.After = args[`after`]
+	r.Limit = args[`limit`]
+}
+
+type GetUserRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	UserID string `json:"-"`
+}
+
+func (r GetUserRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *GetUserRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *GetUserRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *GetUserRequest) SetPathArgs(args map[string]string) {
+	r.UserID = args[`user_id`]
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	Token    string `json:"token"`
+
+	SessionID string `json:"-"`
+}
+
+func (r DeleteAccountRequest) Validate() error {
+	switch {
+	case r.SessionID == "":
+		return errors.New("authorization header is required")
+	case r.Password == "":
+		return errors.New("password is required ")
+	case r.Token == "":
+		return errors.New("token is required ")
+	case len(r.Token) != 6:
+		return errors.New("length of token is not valid")
+	case !util.IsDigit(r.Token):
+		return errors.New("token must be digit")
+	}
+	return nil
+}
+
+func (r *DeleteAccountRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+type DeleteUserRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	UserID string `json:"-"`
+}
+
+func (r DeleteUserRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *DeleteUserRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *DeleteUserRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *DeleteUserRequest) SetPathArgs(args map[string]string) {
+	r.UserID = args[`user_id`]
+}
+
+type SuspendUserRequest struct {
+	Status    string `json:"status"`
+	Reason    string `json:"reason"`
+	ExpiresAt string `json:"expires_at"`
+
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	UserID string `json:"-"`
+}
+
+func (r SuspendUserRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	case len(r.Reason) > 256:
+		return errors.New("length of reason is over")
+	}
+	return nil
+}
+
+func (r *SuspendUserRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *SuspendUserRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *SuspendUserRequest) SetPathArgs(args map[string]string) {
+	r.UserID = args[`user_id`]
+}
+
+type ReactivateUserRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	UserID string `json:"-"`
+}
+
+func (r ReactivateUserRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *ReactivateUserRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *ReactivateUserRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *ReactivateUserRequest) SetPathArgs(args map[string]string) {
+	r.UserID = args[`user_id`]
+}
+
+type GetUserProfileRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	UserID string `json:"-"`
+}
+
+func (r GetUserProfileRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *GetUserProfileRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *GetUserProfileRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *GetUserProfileRequest) SetPathArgs(args map[string]string) {
+	r.UserID = args[`user_id`]
+}
+
+type UpdateUserProfileRequest struct {
+	Profile Profile `json:"profile"`
+
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	UserID string `json:"-"`
+}
+
+func (r UpdateUserProfileRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	case r.Profile == nil:
+		return errors.New("profile is required ")
+	}
+	return nil
+}
+
+func (r *UpdateUserProfileRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *UpdateUserProfileRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *UpdateUserProfileRequest) SetPathArgs(args map[string]string) {
+	r.UserID = args[`user_id`]
+}
+
+type GetProfileRequest struct {
+	SessionID string `json:"-"`
+}
+
+func (r GetProfileRequest) Validate() error {
+	switch {
+	case r.SessionID == "":
+		return errors.New("authorization header is required")
+	}
+	return nil
+}
+
+func (r *GetProfileRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+type UpdateProfileRequest struct {
+	Profile Profile `json:"profile"`
+
+	SessionID string `json:"-"`
+}
+
+func (r UpdateProfileRequest) Validate() error {
+	switch {
+	case r.SessionID == "":
+		return errors.New("authorization header is required")
+	case r.Profile == nil:
+		return errors.New("profile is required ")
+	}
+	return nil
+}
+
+func (r *UpdateProfileRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+type GetGroupRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	Group string `json:"-"`
+}
+
+func (r GetGroupRequest) Validate() error {
+	switch {
+	case r.Group == "":
+		return errors.New("group is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *GetGroupRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *GetGroupRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *GetGroupRequest) SetPathArgs(args map[string]string) {
+	r.Group = args[`group`]
+}
+
+type AddGroupMemberRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	Group string `json:"-"`
+
+	UserID string `json:"-"`
+}
+
+func (r AddGroupMemberRequest) Validate() error {
+	switch {
+	case r.Group == "":
+		return errors.New("group is required")
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *AddGroupMemberRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *AddGroupMemberRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *AddGroupMemberRequest) SetPathArgs(args map[string]string) {
+	r.Group = args[`group`]
+	r.UserID = args[`user_id`]
+}
+
+type RemoveGroupMemberRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	Group string `json:"-"`
+
+	UserID string `json:"-"`
+}
+
+func (r RemoveGroupMemberRequest) Validate() error {
+	switch {
+	case r.Group == "":
+		return errors.New("group is required")
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *RemoveGroupMemberRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *RemoveGroupMemberRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *RemoveGroupMemberRequest) SetPathArgs(args map[string]string) {
+	r.Group = args[`group`]
+	r.UserID = args[`user_id`]
+}
+
+type GrantGroupRoleRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	Group string `json:"-"`
+
+	Role string `json:"-"`
+}
+
+func (r GrantGroupRoleRequest) Validate() error {
+	switch {
+	case r.Group == "":
+		return errors.New("group is required")
+	case r.Role == "":
+		return errors.New("role is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *GrantGroupRoleRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *GrantGroupRoleRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *GrantGroupRoleRequest) SetPathArgs(args map[string]string) {
+	r.Group = args[`group`]
+	r.Role = args[`role`]
+}
+
+type RevokeGroupRoleRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	Group string `json:"-"`
+
+	Role string `json:"-"`
+}
+
+func (r RevokeGroupRoleRequest) Validate() error {
+	switch {
+	case r.Group == "":
+		return errors.New("group is required")
+	case r.Role == "":
+		return errors.New("role is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *RevokeGroupRoleRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *RevokeGroupRoleRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *RevokeGroupRoleRequest) SetPathArgs(args map[string]string) {
+	r.Group = args[`group`]
+	r.Role = args[`role`]
+}
+
+type AddRoleInheritanceRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	Role string `json:"-"`
+
+	InheritedRole string `json:"-"`
+}
+
+func (r AddRoleInheritanceRequest) Validate() error {
+	switch {
+	case r.Role == "":
+		return errors.New("role is required")
+	case r.InheritedRole == "":
+		return errors.New("inherited_role is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *AddRoleInheritanceRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *AddRoleInheritanceRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *AddRoleInheritanceRequest) SetPathArgs(args map[string]string) {
+	r.Role = args[`role`]
+	r.InheritedRole = args[`inherited_role`]
+}
+
+type RemoveRoleInheritanceRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	Role string `json:"-"`
+
+	InheritedRole string `json:"-"`
+}
+
+func (r RemoveRoleInheritanceRequest) Validate() error {
+	switch {
+	case r.Role == "":
+		return errors.New("role is required")
+	case r.InheritedRole == "":
+		return errors.New("inherited_role is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *RemoveRoleInheritanceRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *RemoveRoleInheritanceRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *RemoveRoleInheritanceRequest) SetPathArgs(args map[string]string) {
+	r.Role = args[`role`]
+	r.InheritedRole = args[`inherited_role`]
+}
+
+type RevokeInvitationRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	Code string `json:"-"`
+}
+
+func (r RevokeInvitationRequest) Validate() error {
+	switch {
+	case r.Code == "":
+		return errors.New("code is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *RevokeInvitationRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *RevokeInvitationRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *RevokeInvitationRequest) SetPathArgs(args map[string]string) {
+	r.Code = args[`code`]
+}
+
+type ApproveRegistrationRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	UserID string `json:"-"`
+}
+
+func (r ApproveRegistrationRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *ApproveRegistrationRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *ApproveRegistrationRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *ApproveRegistrationRequest) SetPathArgs(args map[string]string) {
+	r.UserID = args[`user_id`]
+}
+
+type RejectRegistrationRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+
+	UserID string `json:"-"`
+}
+
+func (r RejectRegistrationRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *RejectRegistrationRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *RejectRegistrationRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+func (r *RejectRegistrationRequest) SetPathArgs(args map[string]string) {
+	r.UserID = args[`user_id`]
+}
+
+type CreateInvitationRequest struct {
+	MaxUses   int    `json:"max_uses"`
+	ExpiresAt string `json:"expires_at"`
+
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+}
+
+func (r CreateInvitationRequest) Validate() error {
+	switch {
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *CreateInvitationRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *CreateInvitationRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+type ListRegistrationsRequest struct {
+	AdminToken string `json:"-"`
+
+	SessionID string `json:"-"`
+}
+
+func (r ListRegistrationsRequest) Validate() error {
+	switch {
+	case r.SessionID == "" && r.AdminToken == "":
+		return errors.New("authorization header or admin token is required")
+	}
+	return nil
+}
+
+func (r *ListRegistrationsRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+func (r *ListRegistrationsRequest) SetAdminToken(token string) {
+	r.AdminToken = token
+}
+
+type RevertEmailRequest struct {
+	UserID string `json:"-"`
+
+	Code string `json:"-"`
+}
+
+func (r RevertEmailRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required")
+	case r.Code == "":
+		return errors.New("code is required")
+	}
+	return nil
+}
+
+func (r *RevertEmailRequest) SetPathArgs(args map[string]string) {
+	r.UserID = args[`user_id`]
+	r.Code = args[`code`]
+}
+
+type GetEmailVerificationRequest struct {
+	SessionID string `json:"-"`
+}
+
+func (r GetEmailVerificationRequest) Validate() error {
+	switch {
+	case r.SessionID == "":
+		return errors.New("authorization header is required")
+	}
+	return nil
+}
+
+func (r *GetEmailVerificationRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+type ResendEmailVerificationRequest struct {
+	SessionID string `json:"-"`
+}
+
+func (r ResendEmailVerificationRequest) Validate() error {
+	switch {
+	case r.SessionID == "":
+		return errors.New("authorization header is required")
+	}
+	return nil
+}
+
+func (r *ResendEmailVerificationRequest) SetSessionID(sessid string) {
+	r.SessionID = sessid
+}
+
+type ResumeRegistrationRequest struct {
+	UserID   string `json:"user_id"`
+	Password string `json:"password"`
+}
+
+func (r ResumeRegistrationRequest) Validate() error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is required ")
+	case r.Password == "":
+		return errors.New("password is required ")
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/usecase/input"
+	"github.com/nasa9084/ident/usecase/output"
+)
+
+type clientIPKey struct{}
+
+// WithClientIP returns a copy of ctx with the client IP address,
+// which is used for brute-force protection.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func clientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+type lockoutKey struct {
+	key       string
+	threshold int
+}
+
+// lockoutKeys returns keys to count authentication failures:
+// one for the user and one for the client IP.
+// Only the client IP is counted if userID is empty.
+func lockoutKeys(ctx context.Context, env *infra.Environment, userID string) []lockoutKey {
+	var keys []lockoutKey
+	if userID != "" && env.Lockout.UserThreshold > 0 {
+		keys = append(keys, lockoutKey{"user:" + userID, env.Lockout.UserThreshold})
+	}
+	if ip := clientIP(ctx); ip != "" && env.Lockout.IPThreshold > 0 {
+		keys = append(keys, lockoutKey{"ip:" + ip, env.Lockout.IPThreshold})
+	}
+	return keys
+}
+
+// lockDuration returns lockout duration for given number of failures.
+// The duration is doubled on each failure over the threshold.
+func lockDuration(policy infra.LockoutConfig, failures, threshold int) time.Duration {
+	d := policy.BaseDelay
+	for i := threshold; i < failures && (policy.MaxDelay <= 0 || d < policy.MaxDelay); i++ {
+		d *= 2
+	}
+	if policy.MaxDelay > 0 && d > policy.MaxDelay {
+		d = policy.MaxDelay
+	}
+	return d
+}
+
+// checkLockout returns whether the user or the client is locked out.
+// Retry-After seconds are also returned if locked out.
+func checkLockout(ctx context.Context, env *infra.Environment, userID string) (int, int, error) {
+	repo := env.GetLockoutRepository()
+	var lockedFor time.Duration
+	for _, k := range lockoutKeys(ctx, env, userID) {
+		d, err := repo.LockedFor(ctx, k.key)
+		if err != nil {
+			return statusFromError(err), 0, err
+		}
+		if d > lockedFor {
+			lockedFor = d
+		}
+	}
+	if lockedFor > 0 {
+		return http.StatusTooManyRequests, int(math.Ceil(lockedFor.Seconds())), errors.New("too many failed attempts, try again later")
+	}
+	return http.StatusOK, 0, nil
+}
+
+// authFailed records an authentication failure and locks out the user
+// or the client if the failures reach the threshold.
+// Returns the status and the error to respond.
+func authFailed(ctx context.Context, env *infra.Environment, userID string, err error) (int, error) {
+	if rerr := recordFailure(ctx, env, userID); rerr != nil {
+		return statusFromError(rerr), rerr
+	}
+	return http.StatusUnauthorized, err
+}
+
+func recordFailure(ctx context.Context, env *infra.Environment, userID string) error {
+	repo := env.GetLockoutRepository()
+	ttl := env.Lockout.Window + env.Lockout.MaxDelay
+	for _, k := range lockoutKeys(ctx, env, userID) {
+		failures, err := repo.RecordFailure(ctx, k.key, ttl)
+		if err != nil {
+			return err
+		}
+		if failures < k.threshold {
+			continue
+		}
+		if err := repo.Lock(ctx, k.key, lockDuration(env.Lockout, failures, k.threshold)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loginFailed handles the error finding the user to authenticate.
+// Unknown users are counted as authentication failures of the client IP,
+// so that logins cannot be guessed without limit by changing user IDs.
+// Returns the status, Retry-After seconds and the error to respond.
+func loginFailed(ctx context.Context, env *infra.Environment, err error) (int, int, error) {
+	if err != sql.ErrNoRows {
+		return statusFromError(err), 0, err
+	}
+	if status, retryAfter, lerr := checkLockout(ctx, env, ""); lerr != nil {
+		return status, retryAfter, lerr
+	}
+	if rerr := recordFailure(ctx, env, ""); rerr != nil {
+		return statusFromError(rerr), 0, rerr
+	}
+	return statusFromError(err), 0, err
+}
+
+// resetAuthFailures clears authentication failures of the user after
+// successful authentication. Failures of the client IP are left to
+// expire, so that a client cannot reset its counter by logging in to
+// its own account between guesses.
+func resetAuthFailures(ctx context.Context, env *infra.Environment, userID string) error {
+	if userID == "" || env.Lockout.UserThreshold <= 0 {
+		return nil
+	}
+	return env.GetLockoutRepository().Reset(ctx, "user:"+userID)
+}
+
+// UnlockUser unlocks the user locked out by authentication failures.
+func UnlockUser(ctx context.Context, req input.UnlockUserRequest, env *infra.Environment) output.Response {
+	var resp output.UnlockUserResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if err := env.GetLockoutRepository().Reset(ctx, "user:"+normalizeUserID(env, req.UserID)); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
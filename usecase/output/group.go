@@ -0,0 +1,26 @@
+package output
+
+import "github.com/nasa9084/ident/domain/entity"
+
+// Group is a representation of a group for administrative API.
+type Group struct {
+	Name    string   `json:"name"`
+	Roles   []string `json:"roles"`
+	Members []string `json:"members"`
+}
+
+// NewGroup returns a new Group built from the entity.
+func NewGroup(g entity.Group) Group {
+	group := Group{
+		Name:    g.Name,
+		Roles:   g.Roles,
+		Members: g.Members,
+	}
+	if group.Roles == nil {
+		group.Roles = []string{}
+	}
+	if group.Members == nil {
+		group.Members = []string{}
+	}
+	return group
+}
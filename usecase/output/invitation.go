@@ -0,0 +1,29 @@
+package output
+
+import (
+	"time"
+
+	"github.com/nasa9084/ident/domain/entity"
+)
+
+// Invitation is a representation of an invitation for administrative API.
+// The code is only returned when the invitation is created.
+type Invitation struct {
+	Code      string     `json:"code"`
+	MaxUses   int        `json:"max_uses"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NewInvitation returns a new Invitation built from the entity.
+func NewInvitation(inv entity.Invitation) Invitation {
+	invitation := Invitation{
+		Code:      inv.Code,
+		MaxUses:   inv.MaxUses,
+		CreatedAt: inv.CreatedAt,
+	}
+	if !inv.ExpiresAt.IsZero() {
+		invitation.ExpiresAt = &inv.ExpiresAt
+	}
+	return invitation
+}
@@ -6,6 +6,10 @@ import (
 	"net/http"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/generator"
 )
 
 type mockResponseWriter struct {
@@ -52,3 +56,187 @@ func TestRenderJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestTOTPQRCodeRender(t *testing.T) {
+	candidates := []struct {
+		contentType string
+		status      int
+	}{
+		{"image/png", http.StatusOK},
+		{"image/svg+xml", http.StatusOK},
+		{"application/json", http.StatusOK},
+		{"", http.StatusInternalServerError},
+	}
+	for _, c := range candidates {
+		w := &mockResponseWriter{header: http.Header{}}
+		resp := TOTPQRCodeResponse{
+			Status:      http.StatusOK,
+			QRCode:      []byte("png"),
+			SVG:         []byte("<svg/>"),
+			URI:         "otpauth://totp/ident:alice",
+			ContentType: c.contentType,
+		}
+		resp.Render(w)
+		if w.status != c.status {
+			t.Errorf("%d != %d (content type: %s)", w.status, c.status, c.contentType)
+			continue
+		}
+		if c.status == http.StatusOK && w.header.Get("Content-Type") != c.contentType {
+			t.Errorf("%s != %s", w.header.Get("Content-Type"), c.contentType)
+		}
+	}
+}
+
+func TestRetryAfterRender(t *testing.T) {
+	candidates := []struct {
+		retryAfter int
+		expected   string
+	}{
+		{0, ""},
+		{60, "60"},
+	}
+	for _, c := range candidates {
+		w := &mockResponseWriter{header: http.Header{}}
+		resp := AuthByTOTPResponse{
+			Status:     http.StatusTooManyRequests,
+			Err:        errors.New("too many failed attempts"),
+			RetryAfter: c.retryAfter,
+		}
+		resp.Render(w)
+		if w.header.Get("Retry-After") != c.expected {
+			t.Errorf("%s != %s", w.header.Get("Retry-After"), c.expected)
+		}
+	}
+}
+
+func TestAuthStepRender(t *testing.T) {
+	candidates := []struct {
+		resp     AuthByPasswordResponse
+		expected map[string]interface{}
+	}{
+		{
+			AuthByPasswordResponse{Status: http.StatusOK, SessionID: "foo", Next: "otp sms"},
+			map[string]interface{}{"next": "otp sms", "token": ""},
+		},
+		{
+			AuthByPasswordResponse{Status: http.StatusOK, SessionID: "foo", Token: "bar"},
+			map[string]interface{}{"next": "", "token": "bar"},
+		},
+	}
+	for _, c := range candidates {
+		w := &mockResponseWriter{header: http.Header{}}
+		c.resp.Render(w)
+		if w.header.Get("X-SESSION-ID") != c.resp.SessionID {
+			t.Errorf("%s != %s", w.header.Get("X-SESSION-ID"), c.resp.SessionID)
+			continue
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(w.body, &body); err != nil {
+			t.Error(err)
+			continue
+		}
+		if !reflect.DeepEqual(body, c.expected) {
+			t.Errorf("%v != %v", body, c.expected)
+		}
+	}
+}
+
+func TestUserRender(t *testing.T) {
+	u := entity.User{
+		ID:         "alice",
+		Password:   "password",
+		TOTPSecret: "secret",
+		Email:      "alice@example.com",
+		CreatedAt:  time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	w := &mockResponseWriter{header: http.Header{}}
+	GetUserResponse{Status: http.StatusOK, User: NewUser(u, []string{"admin"})}.Render(w)
+
+	var body map[string]map[string]interface{}
+	if err := json.Unmarshal(w.body, &body); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{
+		"user_id":             "alice",
+		"email":               "alice@example.com",
+		"email_verified":      false,
+		"phone_number":        "",
+		"phone_verified":      false,
+		"totp_verified":       false,
+		"totp_reset_required": false,
+		"pending":             false,
+		"status":              "active",
+		"roles":               []interface{}{"admin"},
+		"created_at":          "2018-01-02T03:04:05Z",
+	}
+	if !reflect.DeepEqual(body["user"], expected) {
+		t.Errorf("%v != %v", body["user"], expected)
+	}
+}
+
+func TestUserStatusExpiry(t *testing.T) {
+	expiresAt := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	u := entity.User{
+		ID:              "alice",
+		Status:          entity.StatusSuspended,
+		StatusReason:    "reason",
+		StatusExpiresAt: expiresAt,
+	}
+	defer func() { generator.TimeFunc = time.Now }()
+
+	generator.TimeFunc = func() time.Time { return expiresAt.Add(-time.Minute) }
+	if user := NewUser(u, nil); user.Status != string(entity.StatusSuspended) || user.StatusReason != "reason" {
+		t.Errorf("unexpected status: %s (reason: %s)", user.Status, user.StatusReason)
+		return
+	}
+	generator.TimeFunc = func() time.Time { return expiresAt.Add(time.Minute) }
+	if user := NewUser(u, nil); user.Status != string(entity.StatusActive) || user.StatusReason != "" {
+		t.Errorf("unexpected status: %s (reason: %s)", user.Status, user.StatusReason)
+		return
+	}
+}
+
+func TestGroupRender(t *testing.T) {
+	g := entity.Group{Name: "staff", Members: []string{"alice", "bob"}}
+	w := &mockResponseWriter{header: http.Header{}}
+	GetGroupResponse{Status: http.StatusOK, Group: NewGroup(g)}.Render(w)
+
+	var body map[string]map[string]interface{}
+	if err := json.Unmarshal(w.body, &body); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{
+		"name":    "staff",
+		"roles":   []interface{}{},
+		"members": []interface{}{"alice", "bob"},
+	}
+	if !reflect.DeepEqual(body["group"], expected) {
+		t.Errorf("%v != %v", body["group"], expected)
+	}
+}
+
+func TestInvitationRender(t *testing.T) {
+	inv := entity.Invitation{
+		Code:      "code",
+		MaxUses:   1,
+		CreatedAt: time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	w := &mockResponseWriter{header: http.Header{}}
+	CreateInvitationResponse{Status: http.StatusCreated, Invitation: NewInvitation(inv)}.Render(w)
+
+	var body map[string]map[string]interface{}
+	if err := json.Unmarshal(w.body, &body); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{
+		"code":       "code",
+		"max_uses":   float64(1),
+		"created_at": "2018-01-02T03:04:05Z",
+	}
+	if !reflect.DeepEqual(body["invitation"], expected) {
+		t.Errorf("%v != %v", body["invitation"], expected)
+	}
+}
@@ -0,0 +1,4 @@
+package output
+
+// Profile is a set of profile attributes keyed by attribute name.
+type Profile map[string]string
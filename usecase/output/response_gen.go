@@ -5,6 +5,7 @@ package output
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/lestrrat-go/bufferpool"
 )
@@ -60,6 +61,24 @@ func renderPNG(w http.ResponseWriter, status int, png []byte) {
 	w.Write(png)
 }
 
+func renderSVG(w http.ResponseWriter, status int, svg []byte) {
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.WriteHeader(status)
+	w.Write(svg)
+}
+
+func setRetryAfter(w http.ResponseWriter, seconds int) {
+	if seconds > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+}
+
+func setSessionID(w http.ResponseWriter, sessid string) {
+	if sessid != "" {
+		w.Header().Set("X-SESSION-ID", sessid)
+	}
+}
+
 func renderJSONWithSessionID(w http.ResponseWriter, status int, err error, sessid string) {
 	if err != nil {
 		renderJSON(w, status, err)
@@ -88,32 +107,50 @@ type AuthByTOTPResponse struct {
 	Status int
 	Err    error
 
-	Message string
+	Next  string
+	Token string
 
 	SessionID string
+
+	RetryAfter int
 }
 
 func (resp AuthByTOTPResponse) Render(w http.ResponseWriter) {
+	setRetryAfter(w, resp.RetryAfter)
 	if resp.Err != nil {
 		renderJSON(w, resp.Status, resp.Err)
 		return
 	}
-	renderJSONWithSessionID(w, resp.Status, resp.Err, resp.SessionID)
+	setSessionID(w, resp.SessionID)
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"next":  resp.Next,
+		"token": resp.Token,
+	})
 }
 
 type AuthByPasswordResponse struct {
 	Status int
 	Err    error
 
+	Next  string
 	Token string
+
+	SessionID string
+
+	RetryAfter int
 }
 
 func (resp AuthByPasswordResponse) Render(w http.ResponseWriter) {
+	setRetryAfter(w, resp.RetryAfter)
 	if resp.Err != nil {
 		renderJSON(w, resp.Status, resp.Err)
 		return
 	}
-	renderJSON(w, resp.Status, okBody)
+	setSessionID(w, resp.SessionID)
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"next":  resp.Next,
+		"token": resp.Token,
+	})
 }
 
 type GetPublicKeyResponse struct {
@@ -143,14 +180,16 @@ func (resp ExistsUserResponse) Render(w http.ResponseWriter) {
 		renderJSON(w, resp.Status, resp.Err)
 		return
 	}
-	renderJSON(w, resp.Status, okBody)
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"exists": resp.Exists,
+	})
 }
 
 type CreateUserResponse struct {
 	Status int
 	Err    error
 
-	Message string
+	ExpiresAt string
 
 	SessionID string
 }
@@ -160,7 +199,10 @@ func (resp CreateUserResponse) Render(w http.ResponseWriter) {
 		renderJSON(w, resp.Status, resp.Err)
 		return
 	}
-	renderJSONWithSessionID(w, resp.Status, resp.Err, resp.SessionID)
+	setSessionID(w, resp.SessionID)
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"expires_at": resp.ExpiresAt,
+	})
 }
 
 type TOTPQRCodeResponse struct {
@@ -168,6 +210,13 @@ type TOTPQRCodeResponse struct {
 	Err    error
 
 	QRCode []byte
+	SVG    []byte
+	URI    string
+	Secret string
+	Issuer string
+	Label  string
+
+	ContentType string
 }
 
 func (resp TOTPQRCodeResponse) Render(w http.ResponseWriter) {
@@ -175,7 +224,24 @@ func (resp TOTPQRCodeResponse) Render(w http.ResponseWriter) {
 		renderJSON(w, resp.Status, resp.Err)
 		return
 	}
-	renderPNG(w, resp.Status, resp.QRCode)
+	switch resp.ContentType {
+	case "application/json":
+		renderJSON(w, resp.Status, map[string]interface{}{
+			"issuer": resp.Issuer,
+			"label":  resp.Label,
+			"secret": resp.Secret,
+			"uri":    resp.URI,
+		})
+	case "image/png":
+		renderPNG(w, resp.Status, resp.QRCode)
+	case "image/svg+xml":
+		renderSVG(w, resp.Status, resp.SVG)
+	default:
+		renderJSON(w, http.StatusInternalServerError, jsonErr{
+			Message: "unknown content type",
+			Error:   http.StatusText(http.StatusInternalServerError),
+		})
+	}
 }
 
 type VerifyTOTPResponse struct {
@@ -207,3 +273,623 @@ func (resp UpdateEmailResponse) Render(w http.ResponseWriter) {
 	}
 	renderJSON(w, resp.Status, okBody)
 }
+
+type UpdatePhoneNumberResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp UpdatePhoneNumberResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type VerifyPhoneNumberResponse struct {
+	Status int
+	Err    error
+
+	Message string
+
+	RetryAfter int
+}
+
+func (resp VerifyPhoneNumberResponse) Render(w http.ResponseWriter) {
+	setRetryAfter(w, resp.RetryAfter)
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type SendSMSCodeResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp SendSMSCodeResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type AuthBySMSResponse struct {
+	Status int
+	Err    error
+
+	Next  string
+	Token string
+
+	SessionID string
+
+	RetryAfter int
+}
+
+func (resp AuthBySMSResponse) Render(w http.ResponseWriter) {
+	setRetryAfter(w, resp.RetryAfter)
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	setSessionID(w, resp.SessionID)
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"next":  resp.Next,
+		"token": resp.Token,
+	})
+}
+
+type EnrollTOTPResponse struct {
+	Status int
+	Err    error
+
+	Message string
+
+	RetryAfter int
+}
+
+func (resp EnrollTOTPResponse) Render(w http.ResponseWriter) {
+	setRetryAfter(w, resp.RetryAfter)
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type AuthForTOTPResetResponse struct {
+	Status int
+	Err    error
+
+	Message string
+
+	SessionID string
+
+	RetryAfter int
+}
+
+func (resp AuthForTOTPResetResponse) Render(w http.ResponseWriter) {
+	setRetryAfter(w, resp.RetryAfter)
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSONWithSessionID(w, resp.Status, resp.Err, resp.SessionID)
+}
+
+type ResetTOTPResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp ResetTOTPResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type UnlockUserResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp UnlockUserResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type IssueTokenResponse struct {
+	Status int
+	Err    error
+
+	Token string
+}
+
+func (resp IssueTokenResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"token": resp.Token,
+	})
+}
+
+type ListUsersResponse struct {
+	Status int
+	Err    error
+
+	Users []User
+	Next  string
+}
+
+func (resp ListUsersResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"next":  resp.Next,
+		"users": resp.Users,
+	})
+}
+
+type GetUserResponse struct {
+	Status int
+	Err    error
+
+	User User
+}
+
+func (resp GetUserResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"user": resp.User,
+	})
+}
+
+type GrantRoleResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp GrantRoleResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type RevokeRoleResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp RevokeRoleResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type DeleteUserResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp DeleteUserResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type DeleteAccountResponse struct {
+	Status int
+	Err    error
+
+	Message string
+
+	RetryAfter int
+}
+
+func (resp DeleteAccountResponse) Render(w http.ResponseWriter) {
+	setRetryAfter(w, resp.RetryAfter)
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type SuspendUserResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp SuspendUserResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type ReactivateUserResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp ReactivateUserResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type GetProfileResponse struct {
+	Status int
+	Err    error
+
+	Profile Profile
+}
+
+func (resp GetProfileResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"profile": resp.Profile,
+	})
+}
+
+type UpdateProfileResponse struct {
+	Status int
+	Err    error
+
+	Profile Profile
+}
+
+func (resp UpdateProfileResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"profile": resp.Profile,
+	})
+}
+
+type GetUserProfileResponse struct {
+	Status int
+	Err    error
+
+	Profile Profile
+}
+
+func (resp GetUserProfileResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"profile": resp.Profile,
+	})
+}
+
+type UpdateUserProfileResponse struct {
+	Status int
+	Err    error
+
+	Profile Profile
+}
+
+func (resp UpdateUserProfileResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"profile": resp.Profile,
+	})
+}
+
+type GetGroupResponse struct {
+	Status int
+	Err    error
+
+	Group Group
+}
+
+func (resp GetGroupResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"group": resp.Group,
+	})
+}
+
+type AddGroupMemberResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp AddGroupMemberResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type RemoveGroupMemberResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp RemoveGroupMemberResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type GrantGroupRoleResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp GrantGroupRoleResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type RevokeGroupRoleResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp RevokeGroupRoleResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type AddRoleInheritanceResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp AddRoleInheritanceResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type RemoveRoleInheritanceResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp RemoveRoleInheritanceResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type CreateInvitationResponse struct {
+	Status int
+	Err    error
+
+	Invitation Invitation
+}
+
+func (resp CreateInvitationResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"invitation": resp.Invitation,
+	})
+}
+
+type ListRegistrationsResponse struct {
+	Status int
+	Err    error
+
+	Users []User
+}
+
+func (resp ListRegistrationsResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"users": resp.Users,
+	})
+}
+
+type RevokeInvitationResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp RevokeInvitationResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type ApproveRegistrationResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp ApproveRegistrationResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type RejectRegistrationResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp RejectRegistrationResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type RevertEmailResponse struct {
+	Status int
+	Err    error
+
+	Message string
+}
+
+func (resp RevertEmailResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type GetEmailVerificationResponse struct {
+	Status int
+	Err    error
+
+	Email         string
+	EmailVerified bool
+	PendingEmail  string
+	Pending       bool
+}
+
+func (resp GetEmailVerificationResponse) Render(w http.ResponseWriter) {
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"email":          resp.Email,
+		"email_verified": resp.EmailVerified,
+		"pending":        resp.Pending,
+		"pending_email":  resp.PendingEmail,
+	})
+}
+
+type ResendEmailVerificationResponse struct {
+	Status int
+	Err    error
+
+	Message string
+
+	RetryAfter int
+}
+
+func (resp ResendEmailVerificationResponse) Render(w http.ResponseWriter) {
+	setRetryAfter(w, resp.RetryAfter)
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	renderJSON(w, resp.Status, okBody)
+}
+
+type ResumeRegistrationResponse struct {
+	Status int
+	Err    error
+
+	ExpiresAt string
+
+	SessionID string
+
+	RetryAfter int
+}
+
+func (resp ResumeRegistrationResponse) Render(w http.ResponseWriter) {
+	setRetryAfter(w, resp.RetryAfter)
+	if resp.Err != nil {
+		renderJSON(w, resp.Status, resp.Err)
+		return
+	}
+	setSessionID(w, resp.SessionID)
+	renderJSON(w, resp.Status, map[string]interface{}{
+		"expires_at": resp.ExpiresAt,
+	})
+}
@@ -0,0 +1,54 @@
+package output
+
+import (
+	"time"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/generator"
+)
+
+// User is a representation of a user for administrative API.
+// Secrets such as password and TOTP secret are never included.
+type User struct {
+	ID                string     `json:"user_id"`
+	Email             string     `json:"email"`
+	EmailVerified     bool       `json:"email_verified"`
+	PendingEmail      string     `json:"pending_email,omitempty"`
+	PhoneNumber       string     `json:"phone_number"`
+	PhoneVerified     bool       `json:"phone_verified"`
+	TOTPVerified      bool       `json:"totp_verified"`
+	TOTPResetRequired bool       `json:"totp_reset_required"`
+	Pending           bool       `json:"pending"`
+	AwaitingApproval  bool       `json:"awaiting_approval,omitempty"`
+	Status            string     `json:"status"`
+	StatusReason      string     `json:"status_reason,omitempty"`
+	StatusExpiresAt   *time.Time `json:"status_expires_at,omitempty"`
+	Roles             []string   `json:"roles,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// NewUser returns a new User built from the entity.
+func NewUser(u entity.User, roles []string) User {
+	user := User{
+		ID:                u.ID,
+		Email:             u.Email,
+		EmailVerified:     u.EmailVerified,
+		PendingEmail:      u.PendingEmail,
+		PhoneNumber:       u.PhoneNumber,
+		PhoneVerified:     u.PhoneVerified,
+		TOTPVerified:      u.TOTPVerified,
+		TOTPResetRequired: u.TOTPResetRequired,
+		Pending:           u.Pending,
+		AwaitingApproval:  u.AwaitingApproval,
+		Status:            string(u.StatusAt(generator.TimeFunc())),
+		Roles:             roles,
+		CreatedAt:         u.CreatedAt,
+	}
+	if user.Status != string(entity.StatusActive) {
+		user.StatusReason = u.StatusReason
+		if !u.StatusExpiresAt.IsZero() {
+			user.StatusExpiresAt = &u.StatusExpiresAt
+		}
+	}
+	return user
+}
@@ -0,0 +1,135 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/usecase/input"
+	"github.com/nasa9084/ident/usecase/output"
+)
+
+func profileSchema(env *infra.Environment) entity.ProfileSchema {
+	if env.ProfileSchema == nil {
+		return entity.DefaultProfileSchema()
+	}
+	return env.ProfileSchema
+}
+
+// findProfile returns the profile attributes of the user visible
+// to the user, or to admin if admin is true.
+func findProfile(ctx context.Context, env *infra.Environment, userID string, admin bool) (output.Profile, int, error) {
+	p, err := env.GetProfileRepository().FindProfile(ctx, userID)
+	if err != nil {
+		return nil, statusFromError(err), err
+	}
+	return output.Profile(profileSchema(env).Visible(p, admin)), http.StatusOK, nil
+}
+
+// updateProfile validates and applies the update to the profile of the user,
+// and returns the updated profile.
+func updateProfile(ctx context.Context, env *infra.Environment, u entity.User, update input.Profile, admin bool) (output.Profile, int, error) {
+	if u.Pending {
+		return nil, http.StatusForbidden, errors.New("registration of the user has not been completed")
+	}
+	p := entity.Profile(update)
+	if err := profileSchema(env).ValidateUpdate(p, admin); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if err := env.GetProfileRepository().UpdateProfile(ctx, u.ID, p); err != nil {
+		return nil, statusFromError(err), err
+	}
+	return findProfile(ctx, env, u.ID, admin)
+}
+
+// GetProfile returns the profile attributes of the session user.
+// Admin-only attributes are not included.
+func GetProfile(ctx context.Context, req input.GetProfileRequest, env *infra.Environment) output.Response {
+	var resp output.GetProfileResponse
+
+	u, err := env.GetUserRepository().FindUserBySessionID(ctx, req.SessionID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	profile, status, err := findProfile(ctx, env, u.ID, false)
+	if err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	resp.Profile = profile
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// UpdateProfile updates the profile attributes of the session user.
+// Only the attributes writable by the user can be updated.
+func UpdateProfile(ctx context.Context, req input.UpdateProfileRequest, env *infra.Environment) output.Response {
+	var resp output.UpdateProfileResponse
+
+	u, err := env.GetUserRepository().FindUserBySessionID(ctx, req.SessionID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	profile, status, err := updateProfile(ctx, env, u, req.Profile, false)
+	if err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	resp.Profile = profile
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// GetUserProfile returns all profile attributes of the user.
+func GetUserProfile(ctx context.Context, req input.GetUserProfileRequest, env *infra.Environment) output.Response {
+	var resp output.GetUserProfileResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	profile, status, err := findProfile(ctx, env, normalizeUserID(env, req.UserID), true)
+	if err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	resp.Profile = profile
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// UpdateUserProfile updates profile attributes of the user by admin.
+func UpdateUserProfile(ctx context.Context, req input.UpdateUserProfileRequest, env *infra.Environment) output.Response {
+	var resp output.UpdateUserProfileResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	u, err := env.GetUserRepository().FindUserByID(ctx, req.UserID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	profile, status, err := updateProfile(ctx, env, u, req.Profile, true)
+	if err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	resp.Profile = profile
+	resp.Status = http.StatusOK
+	return resp
+}
@@ -0,0 +1,299 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/domain/repository"
+	"github.com/nasa9084/ident/generator"
+	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/usecase/input"
+	"github.com/nasa9084/ident/usecase/output"
+)
+
+// useInvitation consumes a use of the invitation code
+// if registration mode is invite.
+// The user ID is checked before, so that the use is not wasted
+// for the user ID which cannot be registered.
+func useInvitation(ctx context.Context, env *infra.Environment, userID, code string) (int, error) {
+	if env.GetRegistrationMode() != entity.RegistrationInvite {
+		return http.StatusOK, nil
+	}
+	if code == "" {
+		return http.StatusForbidden, errors.New("invitation code is required")
+	}
+	exists, err := env.GetUserRepository().ExistsUser(ctx, userID)
+	if err != nil {
+		return statusFromError(err), err
+	}
+	if exists {
+		return http.StatusConflict, repository.ErrUserExists
+	}
+	if err := env.GetInvitationRepository().UseInvitation(ctx, code); err != nil {
+		return statusFromError(err), err
+	}
+	return http.StatusOK, nil
+}
+
+// awaitApproval queues the registration for approval by admin, and
+// notifies admins. Failure of the notification is only logged, since
+// the registration has been queued and admins can find it in the list.
+func awaitApproval(ctx context.Context, env *infra.Environment, u entity.User) error {
+	if u.AwaitingApproval {
+		return nil
+	}
+	if err := env.GetUserRepository().AwaitApproval(ctx, u); err != nil {
+		return err
+	}
+	if err := env.SendApprovalRequestMail(u.ID); err != nil {
+		log.Printf("[ERROR] failed to notify approval request of %s: %s", u.ID, err)
+	}
+	return nil
+}
+
+// notifyApprovalResult notifies the user of the decision by admin.
+// Failure of the notification is only logged, since the decision
+// has been made.
+func notifyApprovalResult(env *infra.Environment, u entity.User, approved bool) {
+	if u.Email == "" {
+		return
+	}
+	if err := env.SendApprovalResultMail(u.Email, approved); err != nil {
+		log.Printf("[ERROR] failed to notify approval result to %s: %s", u.ID, err)
+	}
+}
+
+// registrationExpiresAt returns when the registration created or
+// resumed now expires, formatted in RFC 3339.
+func registrationExpiresAt(ttl time.Duration) string {
+	return generator.TimeFunc().Add(ttl).UTC().Format(time.RFC3339)
+}
+
+// ResumeRegistration authenticates the pending registration with
+// the password given on registration, and returns a new session.
+// The expiry of the registration is extended.
+func ResumeRegistration(ctx context.Context, req input.ResumeRegistrationRequest, env *infra.Environment) output.Response {
+	var resp output.ResumeRegistrationResponse
+	repo := env.GetUserRepository()
+	u, err := repo.FindUserByID(ctx, req.UserID)
+	if err == sql.ErrNoRows || err == nil && !u.Pending {
+		// counted against the client IP as well as unknown users on login
+		status, retryAfter, lerr := loginFailed(ctx, env, sql.ErrNoRows)
+		if lerr == sql.ErrNoRows {
+			status, lerr = http.StatusNotFound, errors.New("pending registration not found")
+		}
+		resp.Status, resp.RetryAfter, resp.Err = status, retryAfter, lerr
+		return resp
+	}
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if status, retryAfter, err := checkLockout(ctx, env, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		resp.RetryAfter = retryAfter
+		return resp
+	}
+	ok, err := verifyPassword(ctx, env, u, req.Password)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if !ok {
+		resp.Status, resp.Err = authFailed(ctx, env, u.ID, errors.New("password invalid"))
+		return resp
+	}
+	if err := resetAuthFailures(ctx, env, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if u.AwaitingApproval {
+		resp.Err = errors.New("the registration is awaiting approval by admin")
+		resp.Status = http.StatusForbidden
+		return resp
+	}
+	ttl := env.GetRegistrationTTL()
+	sessid, err := repo.ResumeRegistration(ctx, u, ttl)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.SessionID = sessid
+	resp.ExpiresAt = registrationExpiresAt(ttl)
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// CreateInvitation issues a new invitation code.
+func CreateInvitation(ctx context.Context, req input.CreateInvitationRequest, env *infra.Environment) output.Response {
+	var resp output.CreateInvitationResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if req.MaxUses < 0 {
+		resp.Err = errors.New("max_uses must not be negative")
+		resp.Status = http.StatusBadRequest
+		return resp
+	}
+	inv := entity.Invitation{
+		MaxUses:   req.MaxUses,
+		CreatedAt: generator.TimeFunc(),
+	}
+	if req.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			resp.Err = errors.New("expires_at must be RFC 3339 format")
+			resp.Status = http.StatusBadRequest
+			return resp
+		}
+		if !t.After(inv.CreatedAt) {
+			resp.Err = errors.New("expires_at must be in the future")
+			resp.Status = http.StatusBadRequest
+			return resp
+		}
+		inv.ExpiresAt = t
+	}
+	code, err := generator.NewInvitationCode()
+	if err != nil {
+		resp.Err = err
+		resp.Status = http.StatusInternalServerError
+		return resp
+	}
+	inv.Code = code
+	if err := env.GetInvitationRepository().CreateInvitation(ctx, inv); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Invitation = output.NewInvitation(inv)
+	resp.Status = http.StatusCreated
+	return resp
+}
+
+// RevokeInvitation revokes the invitation code.
+// Users who have registered with the code are not affected.
+func RevokeInvitation(ctx context.Context, req input.RevokeInvitationRequest, env *infra.Environment) output.Response {
+	var resp output.RevokeInvitationResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if err := env.GetInvitationRepository().DeleteInvitation(ctx, req.Code); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// ListRegistrations returns registrations awaiting approval.
+func ListRegistrations(ctx context.Context, req input.ListRegistrationsRequest, env *infra.Environment) output.Response {
+	var resp output.ListRegistrationsResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	pending := false
+	users, err := env.GetUserRepository().ListUsers(ctx, repository.UserFilter{Verified: &pending})
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Users = []output.User{}
+	for _, u := range users {
+		if u.AwaitingApproval {
+			resp.Users = append(resp.Users, output.NewUser(u, nil))
+		}
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// findRegistration returns the registration awaiting approval.
+func findRegistration(ctx context.Context, env *infra.Environment, userID string) (entity.User, int, error) {
+	u, err := env.GetUserRepository().FindUserByID(ctx, userID)
+	if err == sql.ErrNoRows || err == nil && !u.AwaitingApproval {
+		return u, http.StatusNotFound, errors.New("registration awaiting approval is not found")
+	}
+	if err != nil {
+		return u, statusFromError(err), err
+	}
+	return u, http.StatusOK, nil
+}
+
+// ApproveRegistration completes the registration awaiting approval,
+// and notifies the user.
+func ApproveRegistration(ctx context.Context, req input.ApproveRegistrationRequest, env *infra.Environment) output.Response {
+	var resp output.ApproveRegistrationResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	u, status, err := findRegistration(ctx, env, req.UserID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if err := env.GetUserRepository().Verify(ctx, u); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	notifyApprovalResult(env, u, true)
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// RejectRegistration deletes the registration awaiting approval with
+// its sessions, and notifies the user.
+// The user ID can be registered again.
+func RejectRegistration(ctx context.Context, req input.RejectRegistrationRequest, env *infra.Environment) output.Response {
+	var resp output.RejectRegistrationResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	u, status, err := findRegistration(ctx, env, req.UserID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if err := env.GetUserRepository().DeleteRegistration(ctx, u); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if err := env.GetSessionRepository().DeleteUserSessions(ctx, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	notifyApprovalResult(env, u, false)
+	resp.Status = http.StatusOK
+	return resp
+}
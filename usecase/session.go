@@ -0,0 +1,144 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/generator"
+	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/usecase/input"
+	"github.com/nasa9084/ident/usecase/output"
+)
+
+// newToken issues JWT token for the user to the audience, including roles
+// and groups of the user, and profile attributes which are configured to be claims.
+func newToken(ctx context.Context, env *infra.Environment, u entity.User, audience string, ac entity.AuthContext) (string, int, error) {
+	authz, err := findAuthorization(ctx, env, u.ID)
+	if err != nil {
+		return "", statusFromError(err), err
+	}
+	var claims map[string]interface{}
+	if schema := profileSchema(env); schema.HasClaims() {
+		p, err := env.GetProfileRepository().FindProfile(ctx, u.ID)
+		if err != nil {
+			return "", statusFromError(err), err
+		}
+		claims = schema.Claims(p)
+	}
+	token, err := generator.NewToken(env.PrivateKey, u.ID, audience, ac, authz, claims)
+	if err != nil {
+		return "", statusFromError(err), err
+	}
+	return token, http.StatusOK, nil
+}
+
+// allowsAudience returns true if clients can request tokens for the audience.
+func allowsAudience(env *infra.Environment, audience string) bool {
+	if audience == "" || len(env.Audiences) == 0 {
+		return true
+	}
+	for _, aud := range env.Audiences {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAuthContext returns an error if the authentication context
+// does not satisfy the required method or max age.
+func checkAuthContext(ac entity.AuthContext, amr, maxAge string) (int, error) {
+	authTime := ac.AuthTime()
+	switch method := entity.AuthMethod(amr); method {
+	case "":
+	case entity.AuthMethodMFA:
+		if ac.ACR() != entity.ACRMultiFactor {
+			return http.StatusUnauthorized, errors.New("multi-factor authentication is required")
+		}
+	case entity.AuthMethodPassword, entity.AuthMethodOTP, entity.AuthMethodSMS:
+		at, ok := ac[method]
+		if !ok {
+			return http.StatusUnauthorized, fmt.Errorf("authentication by %s is required", method)
+		}
+		authTime = at
+	default:
+		return http.StatusBadRequest, fmt.Errorf("unknown authentication method: %s", method)
+	}
+	if maxAge == "" {
+		return http.StatusOK, nil
+	}
+	sec, err := strconv.ParseInt(maxAge, 10, 64)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if generator.TimeFunc().Sub(authTime) > time.Duration(sec)*time.Second {
+		if amr == "" {
+			return http.StatusUnauthorized, errors.New("re-authentication is required")
+		}
+		return http.StatusUnauthorized, fmt.Errorf("re-authentication by %s is required", amr)
+	}
+	return http.StatusOK, nil
+}
+
+// IssueToken returns JWT token for the session which has completed
+// the authentication flow. If the client requires an authentication method (amr) or recent
+// authentication (max_age) which the session does not satisfy, the user
+// needs to step up by authenticating again with the session.
+// If audience is given, the token is issued to the audience and
+// includes roles and groups scoped to the audience.
+func IssueToken(ctx context.Context, req input.IssueTokenRequest, env *infra.Environment) output.Response {
+	var resp output.IssueTokenResponse
+
+	u, err := env.GetUserRepository().FindUserBySessionID(ctx, req.SessionID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if status, err := checkStatus(u); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if u.TOTPResetRequired {
+		resp.Err = errors.New("TOTP has been reset, re-enrollment is required")
+		resp.Status = http.StatusForbidden
+		return resp
+	}
+	ac, err := env.GetSessionRepository().FindAuthContext(ctx, req.SessionID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if !authFlow(env).Completed(ac) {
+		resp.Err = errors.New("authentication flow has not been completed")
+		resp.Status = http.StatusUnauthorized
+		return resp
+	}
+	if status, err := checkAuthContext(ac, req.AMR, req.MaxAge); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+
+	if !allowsAudience(env, req.Audience) {
+		resp.Err = fmt.Errorf("unknown audience: %s", req.Audience)
+		resp.Status = http.StatusBadRequest
+		return resp
+	}
+	token, status, err := newToken(ctx, env, u, req.Audience, ac)
+	if err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	resp.Token = token
+	resp.Status = http.StatusOK
+	return resp
+}
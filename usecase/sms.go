@@ -0,0 +1,252 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/generator"
+	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/usecase/input"
+	"github.com/nasa9084/ident/usecase/output"
+	"github.com/nasa9084/ident/util"
+)
+
+const (
+	otpPurposePhone = "phone"
+	otpPurposeSMS   = "sms"
+
+	smsCodeTTL = 5 * time.Minute
+
+	// maxSMSCodeAttempts is the number of wrong guesses after which
+	// the passcode is invalidated.
+	maxSMSCodeAttempts = 5
+)
+
+// sendSMSCode generates a new one-time passcode and sends it to the phone number.
+// The number of SMS sent to each phone number and requested from each
+// client IP is limited by env.GetSMSRateLimit and env.GetSMSIPRateLimit.
+func sendSMSCode(ctx context.Context, env *infra.Environment, purpose, userID, phoneNumber string) (int, error) {
+	limits := map[string]int{"sms:" + phoneNumber: env.GetSMSRateLimit()}
+	if ip := clientIP(ctx); ip != "" {
+		limits["sms_ip:"+ip] = env.GetSMSIPRateLimit()
+	}
+	for key, limit := range limits {
+		if limit <= 0 {
+			continue
+		}
+		count, err := env.GetRateLimitRepository().Incr(ctx, key, env.GetSMSRateWindow())
+		if err != nil {
+			return statusFromError(err), err
+		}
+		if count > limit {
+			return http.StatusTooManyRequests, errors.New("too many SMS requests")
+		}
+	}
+	code, err := generator.NewOTP()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err := env.GetOTPRepository().SaveOTP(ctx, purpose, userID, code, smsCodeTTL); err != nil {
+		return statusFromError(err), err
+	}
+	if err := env.GetLockoutRepository().Reset(ctx, smsCodeAttemptsKey(purpose, userID)); err != nil {
+		return statusFromError(err), err
+	}
+	if err := env.SendSMSCode(phoneNumber, code); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func smsCodeAttemptsKey(purpose, userID string) string {
+	return "otp:" + purpose + ":" + userID
+}
+
+// consumeSMSCode returns whether the passcode sent via SMS is valid or not.
+// The passcode is invalidated after maxSMSCodeAttempts wrong guesses,
+// so that it cannot be brute-forced within its TTL.
+func consumeSMSCode(ctx context.Context, env *infra.Environment, purpose, userID, code string) (bool, error) {
+	ok, err := env.GetOTPRepository().ConsumeOTP(ctx, purpose, userID, code)
+	if err != nil || ok {
+		return ok, err
+	}
+	key := smsCodeAttemptsKey(purpose, userID)
+	failures, err := env.GetLockoutRepository().RecordFailure(ctx, key, smsCodeTTL)
+	if err != nil {
+		return false, err
+	}
+	if failures < maxSMSCodeAttempts {
+		return false, nil
+	}
+	if err := env.GetOTPRepository().DeleteOTP(ctx, purpose, userID); err != nil {
+		return false, err
+	}
+	return false, env.GetLockoutRepository().Reset(ctx, key)
+}
+
+// UpdatePhoneNumber registers phone number for the user and sends verification code.
+func UpdatePhoneNumber(ctx context.Context, req input.UpdatePhoneNumberRequest, env *infra.Environment) output.Response {
+	var resp output.UpdatePhoneNumberResponse
+
+	phoneNumber, err := util.NormalizePhoneNumber(req.PhoneNumber)
+	if err != nil {
+		resp.Err = err
+		resp.Status = http.StatusBadRequest
+		return resp
+	}
+	repo := env.GetUserRepository()
+	u, err := repo.FindUserBySessionID(ctx, req.SessionID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	u.PhoneNumber = phoneNumber
+	u.PhoneVerified = false
+	if err := repo.UpdateUser(ctx, u); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if status, err := sendSMSCode(ctx, env, otpPurposePhone, u.ID, u.PhoneNumber); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// VerifyPhoneNumber verifies the phone number using the code sent via SMS.
+// Wrong codes are counted as authentication failures.
+func VerifyPhoneNumber(ctx context.Context, req input.VerifyPhoneNumberRequest, env *infra.Environment) output.Response {
+	var resp output.VerifyPhoneNumberResponse
+
+	repo := env.GetUserRepository()
+	u, err := repo.FindUserBySessionID(ctx, req.SessionID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if u.PhoneNumber == "" {
+		resp.Err = errors.New("phone number has not been registered")
+		resp.Status = http.StatusBadRequest
+		return resp
+	}
+	if status, retryAfter, err := checkLockout(ctx, env, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		resp.RetryAfter = retryAfter
+		return resp
+	}
+	ok, err := consumeSMSCode(ctx, env, otpPurposePhone, u.ID, req.Token)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if !ok {
+		resp.Status, resp.Err = authFailed(ctx, env, u.ID, errors.New("token invalid"))
+		return resp
+	}
+	u.PhoneVerified = true
+	if err := repo.UpdateUser(ctx, u); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// SendSMSCode sends one-time passcode to the verified phone number of the user.
+// The user is identified by user ID or verified email address.
+func SendSMSCode(ctx context.Context, req input.SendSMSCodeRequest, env *infra.Environment) output.Response {
+	var resp output.SendSMSCodeResponse
+
+	u, err := findUserByLogin(ctx, env, req.UserID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if status, err := checkStatus(u); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if !u.PhoneVerified {
+		resp.Err = errors.New("phone number has not been verified")
+		resp.Status = http.StatusForbidden
+		return resp
+	}
+	if status, err := sendSMSCode(ctx, env, otpPurposeSMS, u.ID, u.PhoneNumber); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// AuthBySMS authenticates using user ID or verified email address,
+// and one-time passcode sent via SMS
+// as a step of authentication flow. Returns SessionID, and JWT Token
+// if the flow has been completed.
+func AuthBySMS(ctx context.Context, req input.AuthBySMSRequest, env *infra.Environment) output.Response {
+	var resp output.AuthBySMSResponse
+
+	u, err := findUserByLogin(ctx, env, req.UserID)
+	if err != nil {
+		resp.Status, resp.RetryAfter, resp.Err = loginFailed(ctx, env, err)
+		return resp
+	}
+	if status, retryAfter, err := checkLockout(ctx, env, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		resp.RetryAfter = retryAfter
+		return resp
+	}
+	if !u.PhoneVerified {
+		resp.Err = errors.New("phone number has not been verified")
+		resp.Status = http.StatusForbidden
+		return resp
+	}
+	ac, status, err := beginStep(ctx, env, u, req.SessionID, entity.AuthMethodSMS)
+	if err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	ok, err := consumeSMSCode(ctx, env, otpPurposeSMS, u.ID, req.Token)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if !ok {
+		resp.Status, resp.Err = authFailed(ctx, env, u.ID, errors.New("token invalid"))
+		return resp
+	}
+	if err := resetAuthFailures(ctx, env, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+
+	step, status, err := completeStep(ctx, env, u, req.SessionID, ac, entity.AuthMethodSMS)
+	if err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	resp.SessionID = step.sessionID
+	resp.Next = step.next
+	resp.Token = step.token
+	resp.Status = http.StatusOK
+	return resp
+}
@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/generator"
+	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/usecase/input"
+	"github.com/nasa9084/ident/usecase/output"
+)
+
+// checkStatus returns an error if the user is suspended or locked.
+func checkStatus(u entity.User) (int, error) {
+	if status := u.StatusAt(generator.TimeFunc()); status.Blocked() {
+		return http.StatusForbidden, fmt.Errorf("user is %s", status)
+	}
+	return http.StatusOK, nil
+}
+
+// findUserForStatus finds the user whose status is changed by admin.
+func findUserForStatus(ctx context.Context, env *infra.Environment, userID string) (entity.User, int, error) {
+	u, err := env.GetUserRepository().FindUserByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return u, http.StatusNotFound, errors.New("user not found")
+		}
+		return u, statusFromError(err), err
+	}
+	if u.Pending {
+		return u, http.StatusForbidden, errors.New("registration of the user has not been completed")
+	}
+	return u, http.StatusOK, nil
+}
+
+// SuspendUser suspends or locks the user until expires_at, or until
+// the user is reactivated if expires_at is not given.
+// All sessions of the user are revoked immediately.
+func SuspendUser(ctx context.Context, req input.SuspendUserRequest, env *infra.Environment) output.Response {
+	var resp output.SuspendUserResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	status := entity.StatusSuspended
+	if req.Status != "" {
+		var err error
+		if status, err = entity.ParseUserStatus(req.Status); err != nil || !status.Blocked() {
+			resp.Err = errors.New("status must be suspended or locked")
+			resp.Status = http.StatusBadRequest
+			return resp
+		}
+	}
+	var expiresAt time.Time
+	if req.ExpiresAt != "" {
+		var err error
+		if expiresAt, err = time.Parse(time.RFC3339, req.ExpiresAt); err != nil {
+			resp.Err = errors.New("expires_at must be RFC 3339 format")
+			resp.Status = http.StatusBadRequest
+			return resp
+		}
+		if !expiresAt.After(generator.TimeFunc()) {
+			resp.Err = errors.New("expires_at must be in the future")
+			resp.Status = http.StatusBadRequest
+			return resp
+		}
+	}
+	u, code, err := findUserForStatus(ctx, env, req.UserID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = code
+		return resp
+	}
+
+	u.Status = status
+	u.StatusReason = req.Reason
+	u.StatusExpiresAt = expiresAt
+	if err := env.GetUserRepository().UpdateUser(ctx, u); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if err := env.GetSessionRepository().DeleteUserSessions(ctx, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// ReactivateUser makes the suspended or locked user active.
+func ReactivateUser(ctx context.Context, req input.ReactivateUserRequest, env *infra.Environment) output.Response {
+	var resp output.ReactivateUserResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	u, code, err := findUserForStatus(ctx, env, req.UserID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = code
+		return resp
+	}
+
+	u.Status = entity.StatusActive
+	u.StatusReason = ""
+	u.StatusExpiresAt = time.Time{}
+	if err := env.GetUserRepository().UpdateUser(ctx, u); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
@@ -0,0 +1,239 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/generator"
+	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/usecase/input"
+	"github.com/nasa9084/ident/usecase/output"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// qrCodeContentTypes are content types TOTPQRCode can respond,
+// in the order of preference.
+var qrCodeContentTypes = []string{"image/png", "image/svg+xml", "application/json"}
+
+const (
+	defaultQRCodeSize = 256
+	minQRCodeSize     = 64
+	maxQRCodeSize     = 1024
+
+	defaultTOTPIssuer      = "ident"
+	defaultTOTPLabelFormat = "{user_id}"
+)
+
+var qrCodeLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+func totpIssuer(env *infra.Environment) string {
+	if env.TOTPIssuer == "" {
+		return defaultTOTPIssuer
+	}
+	return env.TOTPIssuer
+}
+
+// totpLabel returns the account label of otpauth URI for the user.
+func totpLabel(env *infra.Environment, u entity.User) string {
+	format := env.TOTPLabelFormat
+	if format == "" {
+		format = defaultTOTPLabelFormat
+	}
+	return strings.NewReplacer(
+		"{user_id}", u.ID,
+		"{email}", u.Email,
+		"{issuer}", totpIssuer(env),
+	).Replace(format)
+}
+
+// parseQRCodeOptions parses size and error correction level of QR code.
+// Default values are used for empty strings.
+func parseQRCodeOptions(sizeStr, levelStr string) (int, qrcode.RecoveryLevel, error) {
+	size := defaultQRCodeSize
+	if sizeStr != "" {
+		var err error
+		size, err = strconv.Atoi(sizeStr)
+		if err != nil {
+			return 0, 0, errors.New("size must be digit")
+		}
+		if size < minQRCodeSize || maxQRCodeSize < size {
+			return 0, 0, fmt.Errorf("size must be between %d and %d", minQRCodeSize, maxQRCodeSize)
+		}
+	}
+	level := qrcode.Medium
+	if levelStr != "" {
+		var ok bool
+		level, ok = qrCodeLevels[strings.ToUpper(levelStr)]
+		if !ok {
+			return 0, 0, errors.New("level must be one of L, M, Q or H")
+		}
+	}
+	return size, level, nil
+}
+
+// qrCodeSVG renders the QR code as SVG image.
+func qrCodeSVG(q *qrcode.QRCode, size int) []byte {
+	bitmap := q.Bitmap()
+	n := len(bitmap)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#ffffff"/>`, n, n)
+	buf.WriteString(`<path fill="#000000" d="`)
+	for y, row := range bitmap {
+		for x, black := range row {
+			if black {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
+
+// EnrollTOTP starts TOTP re-enrollment.
+// A new pending secret is generated, which can be got using TOTPQRCode
+// and replaces the current secret after verified with VerifyTOTP.
+func EnrollTOTP(ctx context.Context, req input.EnrollTOTPRequest, env *infra.Environment) output.Response {
+	var resp output.EnrollTOTPResponse
+
+	repo := env.GetUserRepository()
+	u, err := repo.FindUserBySessionID(ctx, req.SessionID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if !u.TOTPVerified {
+		resp.Err = errors.New("TOTP verification has not done")
+		resp.Status = http.StatusForbidden
+		return resp
+	}
+	if status, err := checkStatus(u); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if status, retryAfter, err := checkLockout(ctx, env, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		resp.RetryAfter = retryAfter
+		return resp
+	}
+	ok, err := verifyPassword(ctx, env, u, req.Password)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if !ok {
+		resp.Status, resp.Err = authFailed(ctx, env, u.ID, errors.New("password invalid"))
+		return resp
+	}
+	if err := resetAuthFailures(ctx, env, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	u.PendingTOTPSecret = generator.NewSecret()
+	if err := repo.UpdateUser(ctx, u); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// AuthForTOTPReset authenticates using user ID or verified email address,
+// and password for the user whose TOTP has been reset by admin.
+// The returned session is used to re-enroll TOTP.
+func AuthForTOTPReset(ctx context.Context, req input.AuthForTOTPResetRequest, env *infra.Environment) output.Response {
+	var resp output.AuthForTOTPResetResponse
+
+	u, err := findUserByLogin(ctx, env, req.UserID)
+	if err != nil {
+		resp.Status, resp.RetryAfter, resp.Err = loginFailed(ctx, env, err)
+		return resp
+	}
+	if status, retryAfter, err := checkLockout(ctx, env, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		resp.RetryAfter = retryAfter
+		return resp
+	}
+	if status, err := checkStatus(u); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if !u.TOTPResetRequired {
+		resp.Err = errors.New("TOTP has not been reset")
+		resp.Status = http.StatusForbidden
+		return resp
+	}
+	ok, err := verifyPassword(ctx, env, u, req.Password)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if !ok {
+		resp.Status, resp.Err = authFailed(ctx, env, u.ID, errors.New("password invalid"))
+		return resp
+	}
+	if err := resetAuthFailures(ctx, env, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+
+	// this is a recovery flow, which does not follow the authentication flow
+	step, status, err := completeStep(ctx, env, u, "", nil, entity.AuthMethodPassword)
+	if err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	resp.SessionID = step.sessionID
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// ResetTOTP resets TOTP of the user.
+// The user cannot authenticate until a new TOTP device is enrolled.
+func ResetTOTP(ctx context.Context, req input.ResetTOTPRequest, env *infra.Environment) output.Response {
+	var resp output.ResetTOTPResponse
+
+	if status, err := authorizeAdmin(ctx, env, req.AdminToken, req.SessionID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	repo := env.GetUserRepository()
+	u, err := repo.FindUserByID(ctx, req.UserID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	u.PendingTOTPSecret = generator.NewSecret()
+	u.TOTPResetRequired = true
+	if err := repo.UpdateUser(ctx, u); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
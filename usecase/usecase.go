@@ -3,13 +3,19 @@ package usecase
 import (
 	"context"
 	"crypto/x509"
+	"database/sql"
 	"encoding/pem"
 	"errors"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/gomodule/redigo/redis"
+	"github.com/lib/pq"
 	totp "github.com/nasa9084/go-totp"
+	"github.com/nasa9084/ident/domain/entity"
 	"github.com/nasa9084/ident/domain/repository"
 	"github.com/nasa9084/ident/generator"
 	"github.com/nasa9084/ident/infra"
@@ -21,22 +27,81 @@ import (
 
 func statusFromError(err error) int {
 	switch err.(type) {
-	case redis.Error, *mysql.MySQLError:
+	case redis.Error, *mysql.MySQLError, *pq.Error:
 		return http.StatusInternalServerError
 	}
 	switch err {
-	case repository.ErrUserExists:
+	case repository.ErrUserExists, repository.ErrUserDeleted, repository.ErrGroupExists, repository.ErrEmailExists:
 		return http.StatusConflict
-	case redis.ErrNil:
+	case redis.ErrNil, repository.ErrGroupNotFound, repository.ErrInvitationNotFound:
 		return http.StatusNotFound
+	case repository.ErrInvitationInvalid:
+		return http.StatusForbidden
 	}
 	return http.StatusBadRequest
 }
 
+// normalizeUserID returns the user ID normalized by the policy.
+// The ID is returned as is if it cannot be normalized,
+// since such ID never matches any user.
+func normalizeUserID(env *infra.Environment, userID string) string {
+	normalized, err := env.GetUserIDPolicy().Normalize(userID)
+	if err != nil {
+		return userID
+	}
+	return normalized
+}
+
+// findUserByLogin finds the user by verified email address or user ID.
+// Email address takes precedence, since user IDs may look like
+// email addresses but only one user can verify an email address.
+// checkEmailConflict refuses to verify the user ID of another user
+// as an email address, so a login never shadows another user's ID.
+func findUserByLogin(ctx context.Context, env *infra.Environment, login string) (entity.User, error) {
+	repo := env.GetUserRepository()
+	if strings.Contains(login, "@") {
+		u, err := repo.FindUserByEmail(ctx, login)
+		if err != sql.ErrNoRows {
+			return u, err
+		}
+	}
+	return repo.FindUserByID(ctx, login)
+}
+
+// checkEmailConflict returns an error if another user has verified
+// the email address of the user, or if the email address is the user ID
+// of another user, which would be shadowed on login by findUserByLogin.
+func checkEmailConflict(ctx context.Context, env *infra.Environment, u entity.User) (int, error) {
+	repo := env.GetUserRepository()
+	other, err := repo.FindUserByEmail(ctx, u.Email)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return statusFromError(err), err
+	case other.ID != u.ID:
+		return http.StatusConflict, repository.ErrEmailExists
+	}
+	other, err = repo.FindUserByID(ctx, u.Email)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return statusFromError(err), err
+	case other.ID != u.ID:
+		return http.StatusConflict, repository.ErrEmailExists
+	}
+	return http.StatusOK, nil
+}
+
 // ExistsUser returns given user id has been used or not.
+// User IDs which are not allowed by the policy are bad request.
 func ExistsUser(ctx context.Context, req input.ExistsUserRequest, env *infra.Environment) output.Response {
 	var resp output.ExistsUserResponse
 
+	if _, err := env.GetUserIDPolicy().NormalizeNew(req.UserID); err != nil {
+		resp.Err = err
+		resp.Status = http.StatusBadRequest
+		return resp
+	}
 	repo := env.GetUserRepository()
 	exists, err := repo.ExistsUser(ctx, req.UserID)
 	if err != nil {
@@ -50,22 +115,39 @@ func ExistsUser(ctx context.Context, req input.ExistsUserRequest, env *infra.Env
 }
 
 // CreateUser creates a new user.
+// If registration mode is invite, a valid invitation code is required.
+// The registration expires unless completed or resumed in time.
 func CreateUser(ctx context.Context, req input.CreateUserRequest, env *infra.Environment) output.Response {
 	var resp output.CreateUserResponse
 
+	userID, err := env.GetUserIDPolicy().NormalizeNew(req.UserID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = http.StatusBadRequest
+		return resp
+	}
+	if status, err := useInvitation(ctx, env, userID, req.InvitationCode); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
 	repo := env.GetUserRepository()
-	sessid, err := repo.CreateUser(ctx, req.UserID, req.Password)
+	ttl := env.GetRegistrationTTL()
+	sessid, err := repo.CreateUser(ctx, userID, req.Password, ttl)
 	if err != nil {
 		resp.Err = err
 		resp.Status = statusFromError(err)
 		return resp
 	}
 	resp.SessionID = sessid
+	resp.ExpiresAt = registrationExpiresAt(ttl)
 	resp.Status = http.StatusCreated
 	return resp
 }
 
 // TOTPQRCode returns a QR code including TOTP URI associated to given user.
+// The QR code is rendered as PNG or SVG, or the URI and the secret
+// are returned as JSON, depending on Accept header.
 func TOTPQRCode(ctx context.Context, req input.TOTPQRCodeRequest, env *infra.Environment) output.Response {
 	var resp output.TOTPQRCodeResponse
 
@@ -76,19 +158,60 @@ func TOTPQRCode(ctx context.Context, req input.TOTPQRCodeRequest, env *infra.Env
 		resp.Status = statusFromError(err)
 		return resp
 	}
-	g := totp.New(u.TOTPSecret)
-	png, err := qrcode.Encode(g.URI("ident", u.ID), qrcode.Medium, 256)
+	secret := u.TOTPSecret
+	switch {
+	case u.PendingTOTPSecret != "":
+		secret = u.PendingTOTPSecret
+	case u.TOTPVerified:
+		resp.Err = errors.New("TOTP has been already enrolled")
+		resp.Status = http.StatusForbidden
+		return resp
+	}
+	resp.ContentType = util.NegotiateContentType(req.Accept, qrCodeContentTypes)
+	if resp.ContentType == "" {
+		resp.Err = errors.New("acceptable content type is not available")
+		resp.Status = http.StatusNotAcceptable
+		return resp
+	}
+	resp.Issuer = totpIssuer(env)
+	resp.Label = totpLabel(env, u)
+	resp.Secret = secret
+	resp.URI = totp.New(secret).URI(resp.Issuer, resp.Label)
+	if resp.ContentType == "application/json" {
+		resp.Status = http.StatusOK
+		return resp
+	}
+
+	size, level, err := parseQRCodeOptions(req.Size, req.Level)
+	if err != nil {
+		resp.Err = err
+		resp.Status = http.StatusBadRequest
+		return resp
+	}
+	q, err := qrcode.New(resp.URI, level)
 	if err != nil {
 		resp.Err = err
 		resp.Status = http.StatusInternalServerError
 		return resp
 	}
-	resp.QRCode = png
+	switch resp.ContentType {
+	case "image/svg+xml":
+		resp.SVG = qrCodeSVG(q, size)
+	default:
+		resp.QRCode, err = q.PNG(size)
+		if err != nil {
+			resp.Err = err
+			resp.Status = http.StatusInternalServerError
+			return resp
+		}
+	}
 	resp.Status = http.StatusOK
 	return resp
 }
 
 // VerifyTOTP verifies the TOTP configuration is successfully done.
+// If the user is re-enrolling TOTP, the pending secret replaces
+// the current one after verification.
 func VerifyTOTP(ctx context.Context, req input.VerifyTOTPRequest, env *infra.Environment) output.Response {
 	var resp output.VerifyTOTPResponse
 
@@ -99,12 +222,19 @@ func VerifyTOTP(ctx context.Context, req input.VerifyTOTPRequest, env *infra.Env
 		resp.Status = statusFromError(err)
 		return resp
 	}
-	g := totp.New(u.TOTPSecret)
+	secret := u.TOTPSecret
+	if u.PendingTOTPSecret != "" {
+		secret = u.PendingTOTPSecret
+	}
+	g := totp.New(secret)
 	if g.GenerateString() != req.Token {
 		resp.Err = errors.New("token invalid")
 		resp.Status = http.StatusUnauthorized
 		return resp
 	}
+	u.TOTPSecret = secret
+	u.PendingTOTPSecret = ""
+	u.TOTPResetRequired = false
 	u.TOTPVerified = true
 	if err := repo.UpdateUser(ctx, u); err != nil {
 		resp.Err = err
@@ -116,10 +246,18 @@ func VerifyTOTP(ctx context.Context, req input.VerifyTOTPRequest, env *infra.Env
 	return resp
 }
 
-// UpdateEmail updates email for the user.
+// UpdateEmail requests to change email for the user.
+// The email address must be allowed by the email policy.
+// The current address is kept until the new one is verified.
 func UpdateEmail(ctx context.Context, req input.UpdateEmailRequest, env *infra.Environment) output.Response {
 	var resp output.UpdateEmailResponse
 
+	email, err := env.EmailPolicy.Normalize(req.Email)
+	if err != nil {
+		resp.Err = err
+		resp.Status = http.StatusBadRequest
+		return resp
+	}
 	repo := env.GetUserRepository()
 	u, err := repo.FindUserBySessionID(ctx, req.SessionID)
 	if err != nil {
@@ -132,20 +270,13 @@ func UpdateEmail(ctx context.Context, req input.UpdateEmailRequest, env *infra.E
 		resp.Status = http.StatusForbidden
 		return resp
 	}
-	u.Email = req.Email
+	u.PendingEmail = email
 	if err := repo.UpdateUser(ctx, u); err != nil {
 		resp.Err = err
 		resp.Status = statusFromError(err)
 		return resp
 	}
-	sessid, err := repo.CreateSession(u)
-	if err != nil {
-		resp.Err = err
-		resp.Status = statusFromError(err)
-		return resp
-	}
-	// Mail here
-	if err := env.SendVerifyMail(env.MailFrom, u.Email, sessid); err != nil {
+	if err := sendVerification(ctx, env, u); err != nil {
 		resp.Err = err
 		resp.Status = statusFromError(err)
 		return resp
@@ -154,16 +285,84 @@ func UpdateEmail(ctx context.Context, req input.UpdateEmailRequest, env *infra.E
 	return resp
 }
 
-// VerifyEmail verifies the email is valid.
+// VerifyEmail verifies the pending email is valid using the token
+// sent to the address, and replaces the email of the user with it.
+// The token can be used only once, and only while the address is pending.
+// The email address cannot be verified if another user has verified it.
+// The previous address is notified of the change with the link to revert it.
+// If registration mode is approval, the registration waits for
+// approval by admin instead of being completed.
 func VerifyEmail(ctx context.Context, req input.VerifyEmailRequest, env *infra.Environment) output.Response {
 	var resp output.VerifyEmailResponse
 	repo := env.GetUserRepository()
-	u, err := repo.FindUserBySessionID(ctx, req.SessionID)
+	u, err := repo.FindUserByID(ctx, req.UserID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		if err == sql.ErrNoRows {
+			resp.Err = errVerificationTokenInvalid
+			resp.Status = http.StatusForbidden
+		}
+		return resp
+	}
+	if u.PendingEmail == "" {
+		resp.Err = errVerificationTokenInvalid
+		resp.Status = http.StatusForbidden
+		return resp
+	}
+	subject := verificationSubject(u)
+	previous := u.Email
+	u.Email = u.PendingEmail
+	u.PendingEmail = ""
+	// check before consuming the token, so that it can still be used
+	// once the conflict is resolved
+	if status, err := checkEmailConflict(ctx, env, u); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	ok, err := env.GetOTPRepository().ConsumeOTP(ctx, otpPurposeEmailVerify, subject, req.Token)
 	if err != nil {
 		resp.Err = err
 		resp.Status = statusFromError(err)
 		return resp
 	}
+	if !ok {
+		resp.Err = errVerificationTokenInvalid
+		resp.Status = http.StatusForbidden
+		return resp
+	}
+	u.EmailVerified = true
+	if !u.Pending {
+		// the email address has been changed after registration
+		if previous != "" && previous != u.Email {
+			u.PreviousEmail = previous
+		}
+		if err := repo.UpdateUser(ctx, u); err != nil {
+			resp.Err = err
+			resp.Status = statusFromError(err)
+			return resp
+		}
+		if u.PreviousEmail != "" {
+			notifyEmailChanged(ctx, env, u)
+		}
+		resp.Status = http.StatusOK
+		return resp
+	}
+	if env.GetRegistrationMode() == entity.RegistrationApproval {
+		if err := repo.UpdateUser(ctx, u); err != nil {
+			resp.Err = err
+			resp.Status = statusFromError(err)
+			return resp
+		}
+		if err := awaitApproval(ctx, env, u); err != nil {
+			resp.Err = err
+			resp.Status = statusFromError(err)
+			return resp
+		}
+		resp.Status = http.StatusAccepted
+		return resp
+	}
 	if err := repo.Verify(ctx, u); err != nil {
 		resp.Err = err
 		resp.Status = statusFromError(err)
@@ -174,63 +373,213 @@ func VerifyEmail(ctx context.Context, req input.VerifyEmailRequest, env *infra.E
 	return resp
 }
 
-// AuthByTOTP authenticates using user ID and TOTP token.
-// And returns SessionID.
-func AuthByTOTP(ctx context.Context, req input.AuthByTOTPRequest, env *infra.Environment) output.Response {
-	var resp output.AuthByTOTPResponse
+const (
+	otpPurposeEmailRevert = "email_revert"
+
+	// emailRevertTTL is how long the link to revert the change of email is valid.
+	emailRevertTTL = 7 * 24 * time.Hour
+)
+
+// notifyEmailChanged sends the link to revert the change of email
+// to the previous address of the user.
+// Errors are logged, since the change has already been completed.
+func notifyEmailChanged(ctx context.Context, env *infra.Environment, u entity.User) {
+	code, err := generator.NewRevertCode()
+	if err != nil {
+		log.Printf("[ERROR] %s", err)
+		return
+	}
+	if err := env.GetOTPRepository().SaveOTP(ctx, otpPurposeEmailRevert, u.ID, code, emailRevertTTL); err != nil {
+		log.Printf("[ERROR] %s", err)
+		return
+	}
+	if err := env.SendEmailChangedMail(u.PreviousEmail, u.ID, code); err != nil {
+		log.Printf("[ERROR] %s", err)
+	}
+}
+
+// RevertEmail restores the previous email of the user using the link
+// sent to the previous address, and revokes all sessions of the user
+// since the change may have been made by someone else.
+func RevertEmail(ctx context.Context, req input.RevertEmailRequest, env *infra.Environment) output.Response {
+	var resp output.RevertEmailResponse
 	repo := env.GetUserRepository()
 	u, err := repo.FindUserByID(ctx, req.UserID)
 	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		if err == sql.ErrNoRows {
+			// not to tell whether the user exists or not
+			resp.Err = errors.New("code invalid")
+			resp.Status = http.StatusForbidden
+		}
+		return resp
+	}
+	ok, err := env.GetOTPRepository().ConsumeOTP(ctx, otpPurposeEmailRevert, u.ID, req.Code)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if !ok || u.PreviousEmail == "" {
+		resp.Err = errors.New("code invalid")
+		resp.Status = http.StatusForbidden
+		return resp
+	}
+	u.Email = u.PreviousEmail
+	u.EmailVerified = true
+	u.PreviousEmail = ""
+	u.PendingEmail = ""
+	if status, err := checkEmailConflict(ctx, env, u); err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	if err := repo.UpdateUser(ctx, u); err != nil {
 		resp.Err = err
 		resp.Status = statusFromError(err)
 		return resp
 	}
+	if err := env.GetSessionRepository().DeleteUserSessions(ctx, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// AuthByTOTP authenticates using user ID or verified email address,
+// and TOTP token as a step of authentication flow.
+// Returns SessionID, and JWT Token if the flow has been completed.
+func AuthByTOTP(ctx context.Context, req input.AuthByTOTPRequest, env *infra.Environment) output.Response {
+	var resp output.AuthByTOTPResponse
+	u, err := findUserByLogin(ctx, env, req.UserID)
+	if err != nil {
+		resp.Status, resp.RetryAfter, resp.Err = loginFailed(ctx, env, err)
+		return resp
+	}
+	if status, retryAfter, err := checkLockout(ctx, env, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = status
+		resp.RetryAfter = retryAfter
+		return resp
+	}
+	if u.TOTPResetRequired {
+		resp.Err = errors.New("TOTP has been reset, re-enrollment is required")
+		resp.Status = http.StatusForbidden
+		return resp
+	}
+	ac, status, err := beginStep(ctx, env, u, req.SessionID, entity.AuthMethodOTP)
+	if err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
 	g := totp.New(u.TOTPSecret)
 	if g.GenerateString() != req.Token {
-		resp.Err = errors.New("token invalid")
-		resp.Status = http.StatusUnauthorized
+		resp.Status, resp.Err = authFailed(ctx, env, u.ID, errors.New("token invalid"))
+		return resp
+	}
+	if err := resetAuthFailures(ctx, env, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
 		return resp
 	}
 
-	sessid, err := repo.CreateSession(u)
+	step, status, err := completeStep(ctx, env, u, req.SessionID, ac, entity.AuthMethodOTP)
 	if err != nil {
 		resp.Err = err
-		resp.Status = statusFromError(err)
+		resp.Status = status
 		return resp
 	}
-	resp.SessionID = sessid
+	resp.SessionID = step.sessionID
+	resp.Next = step.next
+	resp.Token = step.token
 	resp.Status = http.StatusOK
 
 	return resp
 }
 
-// AuthByPassword authenticates using password and session ID.
-// Returns JWT Token.
+// verifyPassword verifies the password of the user.
+// If the password is verified using a legacy hash imported from other
+// systems, the hash is replaced with the current one.
+func verifyPassword(ctx context.Context, env *infra.Environment, u entity.User, password string) (bool, error) {
+	ok, rehash := util.VerifyPassword(u.Password, password, u.ID)
+	if !ok || !rehash {
+		return ok, nil
+	}
+	u.Password = util.Hash(password, u.ID)
+	if err := env.GetUserRepository().UpdateUser(ctx, u); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AuthByPassword authenticates using password as a step of authentication flow.
+// The user is identified by user ID or verified email address,
+// or session ID of the previous steps.
+// Returns SessionID, and JWT Token if the flow has been completed.
 func AuthByPassword(ctx context.Context, req input.AuthByPasswordRequest, env *infra.Environment) output.Response {
 	var resp output.AuthByPasswordResponse
 	repo := env.GetUserRepository()
-	u, err := repo.FindUserBySessionID(ctx, req.SessionID)
+	var u entity.User
+	var err error
+	switch {
+	case req.UserID != "":
+		u, err = findUserByLogin(ctx, env, req.UserID)
+	case req.SessionID != "":
+		u, err = repo.FindUserBySessionID(ctx, req.SessionID)
+	default:
+		err = errors.New("user_id or authorization header is required")
+	}
 	if err != nil {
+		resp.Status, resp.RetryAfter, resp.Err = loginFailed(ctx, env, err)
+		return resp
+	}
+	if status, retryAfter, err := checkLockout(ctx, env, u.ID); err != nil {
 		resp.Err = err
-		resp.Status = statusFromError(err)
+		resp.Status = status
+		resp.RetryAfter = retryAfter
 		return resp
 	}
-
-	req.Password = util.Hash(req.Password, u.ID)
-
-	if u.Password != req.Password {
-		resp.Err = errors.New("password invalid")
-		resp.Status = http.StatusUnauthorized
+	if u.TOTPResetRequired {
+		resp.Err = errors.New("TOTP has been reset, re-enrollment is required")
+		resp.Status = http.StatusForbidden
+		return resp
+	}
+	ac, status, err := beginStep(ctx, env, u, req.SessionID, entity.AuthMethodPassword)
+	if err != nil {
+		resp.Err = err
+		resp.Status = status
 		return resp
 	}
 
-	token, err := generator.NewToken(env.PrivateKey, u.ID)
+	ok, err := verifyPassword(ctx, env, u, req.Password)
 	if err != nil {
 		resp.Err = err
 		resp.Status = statusFromError(err)
 		return resp
 	}
-	resp.Token = token
+	if !ok {
+		resp.Status, resp.Err = authFailed(ctx, env, u.ID, errors.New("password invalid"))
+		return resp
+	}
+	if err := resetAuthFailures(ctx, env, u.ID); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+
+	step, status, err := completeStep(ctx, env, u, req.SessionID, ac, entity.AuthMethodPassword)
+	if err != nil {
+		resp.Err = err
+		resp.Status = status
+		return resp
+	}
+	resp.SessionID = step.sessionID
+	resp.Next = step.next
+	resp.Token = step.token
 	resp.Status = http.StatusOK
 	return resp
 }
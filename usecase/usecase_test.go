@@ -1,37 +1,37 @@
 package usecase_test
 
 import (
+	"bytes"
 	"context"
-	"database/sql"
 	"net/http"
+	"net/url"
 	"os"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
-	"github.com/go-sql-driver/mysql"
 	"github.com/gomodule/redigo/redis"
 	totp "github.com/nasa9084/go-totp"
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/generator"
 	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/infra/database"
 	"github.com/nasa9084/ident/infra/mail"
+	"github.com/nasa9084/ident/infra/sms"
 	"github.com/nasa9084/ident/usecase"
 	"github.com/nasa9084/ident/usecase/input"
 	"github.com/nasa9084/ident/usecase/output"
+	"github.com/nasa9084/ident/util"
 )
 
+// getEnv returns the environment for tests.
+// PostgreSQL is used instead of MySQL if STORAGE_DRIVER is postgres.
 func getEnv(t *testing.T) *infra.Environment {
-	dbname := os.Getenv("MYSQL_DB")
-	if dbname == "" {
-		dbname = "ident"
-	}
-	cfg := mysql.Config{
-		Net:       "tcp",
-		Addr:      "localhost:3306",
-		User:      "root",
-		DBName:    dbname,
-		ParseTime: true,
-	}
-	rdb, err := sql.Open("mysql", cfg.FormatDSN())
+	rdb, err := openRDB()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -53,10 +53,65 @@ func getEnv(t *testing.T) *infra.Environment {
 	return env
 }
 
+func openRDB() (*database.RDB, error) {
+	cfg := infra.StorageConfig{
+		Driver: os.Getenv("STORAGE_DRIVER"),
+		MySQL: infra.MySQLConfig{
+			Addr:   "localhost:3306",
+			User:   "root",
+			DBName: os.Getenv("MYSQL_DB"),
+		},
+		Postgres: infra.PostgresConfig{
+			Addr:    "localhost:5432",
+			User:    "postgres",
+			DBName:  os.Getenv("POSTGRES_DB"),
+			SSLMode: "disable",
+		},
+	}
+	if cfg.MySQL.DBName == "" {
+		cfg.MySQL.DBName = "ident"
+	}
+	if cfg.Postgres.DBName == "" {
+		cfg.Postgres.DBName = "ident"
+	}
+	return cfg.Open()
+}
+
 const (
 	mockPassword = "password"
-	mockEmail    = "email"
+	mockEmail    = "user@example.com"
 	aliceID      = "alice"
+	bobID        = "bob"
+	carolID      = "carol"
+	daveID       = "dave"
+	eveID        = "eve"
+	frankID      = "frank"
+	graceID      = "grace"
+	henryID      = "henry"
+	ivanID       = "ivan"
+	judyID       = "judy"
+	kevinID      = "kevin"
+	leoID        = "leo"
+	mikeID       = "mike"
+	nancyID      = "nancy"
+	oscarID      = "oscar"
+	peggyID      = "peggy"
+	quentinID    = "quentin"
+	ruthID       = "ruth"
+	sybilID      = "sybil"
+	trentID      = "trent"
+	victorID     = "victor"
+	wendyID      = "wendy"
+	xavierID     = "xavier"
+	yvonneID     = "yvonne"
+	zoeID        = "zoe"
+	amberID      = "amber"
+	brunoID      = "bruno"
+	chloeID      = "chloe"
+	dianaID      = "diana"
+	oliviaID     = "olivia"
+	mockAdmin    = "admintoken"
+	mockPhone    = "+81 90-1234-5678"
 )
 
 func TestUserCreationProcess(t *testing.T) {
@@ -99,6 +154,8 @@ func TestUserCreationProcess(t *testing.T) {
 		return
 	}
 
+	var mailBuf bytes.Buffer
+	env.Mail = mail.NewLog(&mailBuf)
 	umReq := input.UpdateEmailRequest{Email: mockEmail, SessionID: cResp.SessionID}
 	umResp := usecase.UpdateEmail(context.Background(), umReq, env).(output.UpdateEmailResponse)
 
@@ -108,22 +165,7 @@ func TestUserCreationProcess(t *testing.T) {
 		return
 	}
 
-	keys, err := redis.Strings(env.KVS.Do("KEYS", "session:*"))
-	if err != nil {
-		t.Fatal(err)
-	}
-	var sessid string
-	for _, key := range keys {
-		val, err := redis.String(env.KVS.Do("GET", key))
-		if err != nil {
-			t.Fatal(err)
-		}
-		if val == aliceID {
-			sessid = strings.Split(key, ":")[1]
-		}
-	}
-
-	vmReq := input.VerifyEmailRequest{SessionID: sessid}
+	vmReq := lastVerifyRequest(t, &mailBuf)
 	vmResp := usecase.VerifyEmail(context.Background(), vmReq, env).(output.VerifyEmailResponse)
 
 	if vmResp.Status != http.StatusOK {
@@ -165,4 +207,1758 @@ func TestUserCreationProcess(t *testing.T) {
 		t.Error("token appears not valid")
 		return
 	}
+	claims := tk.Claims.(jwt.MapClaims)
+	if claims["acr"] != "2" {
+		t.Errorf("%v != %s", claims["acr"], "2")
+		return
+	}
+}
+
+var verifyLinkRe = regexp.MustCompile(`/v1/user/email/verify/([^/\s]+)/(\w+)`)
+
+func lastVerifyRequest(t *testing.T, buf *bytes.Buffer) input.VerifyEmailRequest {
+	t.Helper()
+	m := verifyLinkRe.FindAllStringSubmatch(buf.String(), -1)
+	if len(m) == 0 {
+		t.Fatal("no verification mail has been sent")
+	}
+	userID, err := url.PathUnescape(m[len(m)-1][1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input.VerifyEmailRequest{UserID: userID, Token: m[len(m)-1][2]}
+}
+
+var smsCodeRe = regexp.MustCompile(`code is (\d{6})`)
+
+func lastSMSCode(t *testing.T, buf *bytes.Buffer) string {
+	t.Helper()
+	m := smsCodeRe.FindAllStringSubmatch(buf.String(), -1)
+	if len(m) == 0 {
+		t.Fatal("no SMS has been sent")
+	}
+	return m[len(m)-1][1]
+}
+
+func TestSMSFactor(t *testing.T) {
+	env := getEnv(t)
+	var buf bytes.Buffer
+	env.SMS = sms.NewLog(&buf)
+
+	cReq := input.CreateUserRequest{UserID: bobID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+
+	upReq := input.UpdatePhoneNumberRequest{PhoneNumber: mockPhone, SessionID: cResp.SessionID}
+	upResp := usecase.UpdatePhoneNumber(context.Background(), upReq, env).(output.UpdatePhoneNumberResponse)
+	if upResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", upResp.Status, http.StatusOK)
+		t.Log(upResp.Err)
+		return
+	}
+
+	// SMS factor cannot be used before the phone number is verified
+	scReq := input.SendSMSCodeRequest{UserID: bobID}
+	scResp := usecase.SendSMSCode(context.Background(), scReq, env).(output.SendSMSCodeResponse)
+	if scResp.Status != http.StatusForbidden {
+		t.Errorf("%d != %d", scResp.Status, http.StatusForbidden)
+		return
+	}
+
+	vpReq := input.VerifyPhoneNumberRequest{Token: lastSMSCode(t, &buf), SessionID: cResp.SessionID}
+	vpResp := usecase.VerifyPhoneNumber(context.Background(), vpReq, env).(output.VerifyPhoneNumberResponse)
+	if vpResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vpResp.Status, http.StatusOK)
+		t.Log(vpResp.Err)
+		return
+	}
+
+	scResp = usecase.SendSMSCode(context.Background(), scReq, env).(output.SendSMSCodeResponse)
+	if scResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", scResp.Status, http.StatusOK)
+		t.Log(scResp.Err)
+		return
+	}
+	code := lastSMSCode(t, &buf)
+
+	asReq := input.AuthBySMSRequest{UserID: bobID, Token: code}
+	asResp := usecase.AuthBySMS(context.Background(), asReq, env).(output.AuthBySMSResponse)
+	if asResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", asResp.Status, http.StatusOK)
+		t.Log(asResp.Err)
+		return
+	}
+	if asResp.SessionID == "" {
+		t.Error("session id should be returned")
+		return
+	}
+
+	// passcode must not be reusable
+	asResp = usecase.AuthBySMS(context.Background(), asReq, env).(output.AuthBySMSResponse)
+	if asResp.Status != http.StatusUnauthorized {
+		t.Errorf("%d != %d", asResp.Status, http.StatusUnauthorized)
+		return
+	}
+}
+
+func TestSMSCodeAttempts(t *testing.T) {
+	env := getEnv(t)
+	var buf bytes.Buffer
+	env.SMS = sms.NewLog(&buf)
+
+	cReq := input.CreateUserRequest{UserID: dianaID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	upReq := input.UpdatePhoneNumberRequest{PhoneNumber: "+81 90-8765-4321", SessionID: cResp.SessionID}
+	upResp := usecase.UpdatePhoneNumber(context.Background(), upReq, env).(output.UpdatePhoneNumberResponse)
+	if upResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", upResp.Status, http.StatusOK)
+		t.Log(upResp.Err)
+		return
+	}
+	code := lastSMSCode(t, &buf)
+	wrong := []byte(code)
+	wrong[len(wrong)-1] = '0' + (wrong[len(wrong)-1]-'0'+1)%10
+
+	vpReq := input.VerifyPhoneNumberRequest{Token: string(wrong), SessionID: cResp.SessionID}
+	for i := 0; i < 5; i++ {
+		vpResp := usecase.VerifyPhoneNumber(context.Background(), vpReq, env).(output.VerifyPhoneNumberResponse)
+		if vpResp.Status != http.StatusUnauthorized {
+			t.Errorf("%d != %d", vpResp.Status, http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// the passcode has been invalidated by the wrong guesses
+	vpReq.Token = code
+	vpResp := usecase.VerifyPhoneNumber(context.Background(), vpReq, env).(output.VerifyPhoneNumberResponse)
+	if vpResp.Status != http.StatusUnauthorized {
+		t.Errorf("%d != %d", vpResp.Status, http.StatusUnauthorized)
+		return
+	}
+}
+
+func TestTOTPReEnrollment(t *testing.T) {
+	env := getEnv(t)
+	env.AdminToken = mockAdmin
+
+	cReq := input.CreateUserRequest{UserID: carolID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	secret, err := redis.String(env.KVS.Do("HGET", "user:"+carolID, "totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	vtReq := input.VerifyTOTPRequest{Token: totp.New(secret).GenerateString(), SessionID: cResp.SessionID}
+	vtResp := usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vtResp.Status, http.StatusOK)
+		t.Log(vtResp.Err)
+		return
+	}
+
+	// re-enroll by user
+	etReq := input.EnrollTOTPRequest{Password: mockPassword, SessionID: cResp.SessionID}
+	etResp := usecase.EnrollTOTP(context.Background(), etReq, env).(output.EnrollTOTPResponse)
+	if etResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", etResp.Status, http.StatusOK)
+		t.Log(etResp.Err)
+		return
+	}
+	pending, err := redis.String(env.KVS.Do("HGET", "user:"+carolID, "pending_totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	vtReq = input.VerifyTOTPRequest{Token: totp.New(pending).GenerateString(), SessionID: cResp.SessionID}
+	vtResp = usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vtResp.Status, http.StatusOK)
+		t.Log(vtResp.Err)
+		return
+	}
+	secret, err = redis.String(env.KVS.Do("HGET", "user:"+carolID, "totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if secret != pending {
+		t.Errorf("%s != %s", secret, pending)
+		return
+	}
+
+	// reset by admin
+	rtReq := input.ResetTOTPRequest{UserID: carolID, AdminToken: mockAdmin}
+	rtResp := usecase.ResetTOTP(context.Background(), rtReq, env).(output.ResetTOTPResponse)
+	if rtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", rtResp.Status, http.StatusOK)
+		t.Log(rtResp.Err)
+		return
+	}
+	atReq := input.AuthByTOTPRequest{UserID: carolID, Token: totp.New(secret).GenerateString()}
+	atResp := usecase.AuthByTOTP(context.Background(), atReq, env).(output.AuthByTOTPResponse)
+	if atResp.Status != http.StatusForbidden {
+		t.Errorf("%d != %d", atResp.Status, http.StatusForbidden)
+		return
+	}
+	arReq := input.AuthForTOTPResetRequest{UserID: carolID, Password: mockPassword}
+	arResp := usecase.AuthForTOTPReset(context.Background(), arReq, env).(output.AuthForTOTPResetResponse)
+	if arResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", arResp.Status, http.StatusOK)
+		t.Log(arResp.Err)
+		return
+	}
+	pending, err = redis.String(env.KVS.Do("HGET", "user:"+carolID, "pending_totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	vtReq = input.VerifyTOTPRequest{Token: totp.New(pending).GenerateString(), SessionID: arResp.SessionID}
+	vtResp = usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vtResp.Status, http.StatusOK)
+		t.Log(vtResp.Err)
+		return
+	}
+}
+
+func TestTOTPQRCode(t *testing.T) {
+	env := getEnv(t)
+	env.TOTPIssuer = "example"
+	env.TOTPLabelFormat = "{issuer}:{user_id}"
+
+	cReq := input.CreateUserRequest{UserID: daveID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	candidates := []struct {
+		req         input.TOTPQRCodeRequest
+		status      int
+		contentType string
+	}{
+		{input.TOTPQRCodeRequest{}, http.StatusOK, "image/png"},
+		{input.TOTPQRCodeRequest{Accept: "image/svg+xml", Size: "128", Level: "H"}, http.StatusOK, "image/svg+xml"},
+		{input.TOTPQRCodeRequest{Accept: "application/json"}, http.StatusOK, "application/json"},
+		{input.TOTPQRCodeRequest{Size: "10"}, http.StatusBadRequest, "image/png"},
+		{input.TOTPQRCodeRequest{Level: "X"}, http.StatusBadRequest, "image/png"},
+		{input.TOTPQRCodeRequest{Accept: "text/html"}, http.StatusNotAcceptable, ""},
+	}
+	for _, c := range candidates {
+		c.req.SessionID = cResp.SessionID
+		resp := usecase.TOTPQRCode(context.Background(), c.req, env).(output.TOTPQRCodeResponse)
+		if resp.Status != c.status {
+			t.Errorf("%d != %d", resp.Status, c.status)
+			t.Log(resp.Err)
+			continue
+		}
+		if resp.ContentType != c.contentType {
+			t.Errorf("%s != %s", resp.ContentType, c.contentType)
+			continue
+		}
+		if resp.Status == http.StatusOK && resp.Label != "example:"+daveID {
+			t.Errorf("%s != example:%s", resp.Label, daveID)
+		}
+	}
+}
+
+func TestLockout(t *testing.T) {
+	env := getEnv(t)
+	env.AdminToken = mockAdmin
+	env.Lockout = infra.LockoutConfig{
+		UserThreshold: 3,
+		Window:        time.Minute,
+		BaseDelay:     time.Minute,
+		MaxDelay:      time.Hour,
+	}
+
+	cReq := input.CreateUserRequest{UserID: eveID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	secret, err := redis.String(env.KVS.Do("HGET", "user:"+eveID, "totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	vtReq := input.VerifyTOTPRequest{Token: totp.New(secret).GenerateString(), SessionID: cResp.SessionID}
+	vtResp := usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vtResp.Status, http.StatusOK)
+		t.Log(vtResp.Err)
+		return
+	}
+
+	ctx := usecase.WithClientIP(context.Background(), "192.0.2.1")
+	badReq := input.AuthByTOTPRequest{UserID: eveID, Token: "invalid"}
+	for i := 0; i < env.Lockout.UserThreshold; i++ {
+		atResp := usecase.AuthByTOTP(ctx, badReq, env).(output.AuthByTOTPResponse)
+		if atResp.Status != http.StatusUnauthorized {
+			t.Errorf("%d != %d", atResp.Status, http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// locked out even if the token is valid
+	atReq := input.AuthByTOTPRequest{UserID: eveID, Token: totp.New(secret).GenerateString()}
+	atResp := usecase.AuthByTOTP(ctx, atReq, env).(output.AuthByTOTPResponse)
+	if atResp.Status != http.StatusTooManyRequests {
+		t.Errorf("%d != %d", atResp.Status, http.StatusTooManyRequests)
+		return
+	}
+	if atResp.RetryAfter <= 0 || atResp.RetryAfter > 60 {
+		t.Errorf("unexpected Retry-After: %d", atResp.RetryAfter)
+		return
+	}
+
+	uReq := input.UnlockUserRequest{UserID: eveID, AdminToken: mockAdmin}
+	uResp := usecase.UnlockUser(context.Background(), uReq, env).(output.UnlockUserResponse)
+	if uResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", uResp.Status, http.StatusOK)
+		t.Log(uResp.Err)
+		return
+	}
+	atResp = usecase.AuthByTOTP(ctx, atReq, env).(output.AuthByTOTPResponse)
+	if atResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", atResp.Status, http.StatusOK)
+		t.Log(atResp.Err)
+		return
+	}
+
+	// password guesses on TOTP re-enrollment count as failures
+	etReq := input.EnrollTOTPRequest{Password: "invalid", SessionID: cResp.SessionID}
+	for i := 0; i < env.Lockout.UserThreshold; i++ {
+		etResp := usecase.EnrollTOTP(ctx, etReq, env).(output.EnrollTOTPResponse)
+		if etResp.Status != http.StatusUnauthorized {
+			t.Errorf("%d != %d", etResp.Status, http.StatusUnauthorized)
+			return
+		}
+	}
+	etReq.Password = mockPassword
+	etResp := usecase.EnrollTOTP(ctx, etReq, env).(output.EnrollTOTPResponse)
+	if etResp.Status != http.StatusTooManyRequests {
+		t.Errorf("%d != %d", etResp.Status, http.StatusTooManyRequests)
+		return
+	}
+}
+
+func TestIPLockout(t *testing.T) {
+	env := getEnv(t)
+	env.Lockout = infra.LockoutConfig{
+		IPThreshold: 3,
+		Window:      time.Minute,
+		BaseDelay:   time.Minute,
+		MaxDelay:    time.Hour,
+	}
+
+	// logins by unknown users are counted against the client IP
+	ctx := usecase.WithClientIP(context.Background(), "192.0.2.2")
+	for i := 0; i < env.Lockout.IPThreshold; i++ {
+		req := input.AuthByPasswordRequest{UserID: "unknown" + strconv.Itoa(i), Password: mockPassword}
+		resp := usecase.AuthByPassword(ctx, req, env).(output.AuthByPasswordResponse)
+		if resp.Status == http.StatusOK || resp.Status == http.StatusTooManyRequests {
+			t.Errorf("unexpected status: %d", resp.Status)
+			return
+		}
+	}
+	req := input.AuthByTOTPRequest{UserID: "unknown", Token: "invalid"}
+	resp := usecase.AuthByTOTP(ctx, req, env).(output.AuthByTOTPResponse)
+	if resp.Status != http.StatusTooManyRequests {
+		t.Errorf("%d != %d", resp.Status, http.StatusTooManyRequests)
+		return
+	}
+	if resp.RetryAfter <= 0 {
+		t.Errorf("unexpected Retry-After: %d", resp.RetryAfter)
+		return
+	}
+}
+
+func TestStepUpAuthentication(t *testing.T) {
+	env := getEnv(t)
+
+	cReq := input.CreateUserRequest{UserID: frankID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	secret, err := redis.String(env.KVS.Do("HGET", "user:"+frankID, "totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	g := totp.New(secret)
+	vtReq := input.VerifyTOTPRequest{Token: g.GenerateString(), SessionID: cResp.SessionID}
+	vtResp := usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vtResp.Status, http.StatusOK)
+		t.Log(vtResp.Err)
+		return
+	}
+
+	atReq := input.AuthByTOTPRequest{UserID: frankID, Token: g.GenerateString()}
+	atResp := usecase.AuthByTOTP(context.Background(), atReq, env).(output.AuthByTOTPResponse)
+	if atResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", atResp.Status, http.StatusOK)
+		t.Log(atResp.Err)
+		return
+	}
+	// login sessions expire
+	pttl, err := redis.Int64(env.KVS.Do("PTTL", "session:"+atResp.SessionID))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if pttl <= 0 || pttl > int64(infra.DefaultSessionTTL/time.Millisecond) {
+		t.Errorf("unexpected session TTL: %dms", pttl)
+		return
+	}
+	apReq := input.AuthByPasswordRequest{SessionID: atResp.SessionID, Password: mockPassword}
+	apResp := usecase.AuthByPassword(context.Background(), apReq, env).(output.AuthByPasswordResponse)
+	if apResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", apResp.Status, http.StatusOK)
+		t.Log(apResp.Err)
+		return
+	}
+
+	// authenticated 10 minutes ago
+	defer func() { generator.TimeFunc = time.Now }()
+	generator.TimeFunc = func() time.Time { return time.Now().Add(10 * time.Minute) }
+
+	itReq := input.IssueTokenRequest{AMR: "otp", MaxAge: "300", SessionID: atResp.SessionID}
+	itResp := usecase.IssueToken(context.Background(), itReq, env).(output.IssueTokenResponse)
+	if itResp.Status != http.StatusUnauthorized {
+		t.Errorf("%d != %d", itResp.Status, http.StatusUnauthorized)
+		return
+	}
+
+	// step up with TOTP on the same session
+	atReq.SessionID = atResp.SessionID
+	suResp := usecase.AuthByTOTP(context.Background(), atReq, env).(output.AuthByTOTPResponse)
+	if suResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", suResp.Status, http.StatusOK)
+		t.Log(suResp.Err)
+		return
+	}
+	if suResp.SessionID != atResp.SessionID {
+		t.Errorf("%s != %s", suResp.SessionID, atResp.SessionID)
+		return
+	}
+	itResp = usecase.IssueToken(context.Background(), itReq, env).(output.IssueTokenResponse)
+	if itResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", itResp.Status, http.StatusOK)
+		t.Log(itResp.Err)
+		return
+	}
+}
+
+func TestAuthFlow(t *testing.T) {
+	env := getEnv(t)
+	env.AuthFlow = entity.AuthFlow{
+		{entity.AuthMethodPassword},
+		{entity.AuthMethodOTP},
+	}
+
+	cReq := input.CreateUserRequest{UserID: graceID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	secret, err := redis.String(env.KVS.Do("HGET", "user:"+graceID, "totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	g := totp.New(secret)
+	vtReq := input.VerifyTOTPRequest{Token: g.GenerateString(), SessionID: cResp.SessionID}
+	vtResp := usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vtResp.Status, http.StatusOK)
+		t.Log(vtResp.Err)
+		return
+	}
+
+	// TOTP is not allowed as the first step
+	atReq := input.AuthByTOTPRequest{UserID: graceID, Token: g.GenerateString()}
+	atResp := usecase.AuthByTOTP(context.Background(), atReq, env).(output.AuthByTOTPResponse)
+	if atResp.Status != http.StatusForbidden {
+		t.Errorf("%d != %d", atResp.Status, http.StatusForbidden)
+		return
+	}
+
+	apReq := input.AuthByPasswordRequest{UserID: graceID, Password: mockPassword}
+	apResp := usecase.AuthByPassword(context.Background(), apReq, env).(output.AuthByPasswordResponse)
+	if apResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", apResp.Status, http.StatusOK)
+		t.Log(apResp.Err)
+		return
+	}
+	if apResp.Next != "otp" {
+		t.Errorf("%s != %s", apResp.Next, "otp")
+		return
+	}
+	if apResp.Token != "" {
+		t.Error("token should not be issued before the flow is completed")
+		return
+	}
+
+	atReq.SessionID = apResp.SessionID
+	atResp = usecase.AuthByTOTP(context.Background(), atReq, env).(output.AuthByTOTPResponse)
+	if atResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", atResp.Status, http.StatusOK)
+		t.Log(atResp.Err)
+		return
+	}
+	if atResp.Next != "" {
+		t.Errorf("%s != %s", atResp.Next, "")
+		return
+	}
+	if atResp.Token == "" {
+		t.Error("token should be issued after the flow is completed")
+		return
+	}
+}
+
+func TestAdminAPI(t *testing.T) {
+	env := getEnv(t)
+	env.AdminToken = mockAdmin
+
+	cReq := input.CreateUserRequest{UserID: henryID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+
+	// ordinary session cannot access administrative API
+	luReq := input.ListUsersRequest{IDPrefix: henryID, Verified: "false", SessionID: cResp.SessionID}
+	luResp := usecase.ListUsers(context.Background(), luReq, env).(output.ListUsersResponse)
+	if luResp.Status != http.StatusUnauthorized {
+		t.Errorf("%d != %d", luResp.Status, http.StatusUnauthorized)
+		return
+	}
+
+	luReq = input.ListUsersRequest{IDPrefix: henryID, Verified: "false", AdminToken: mockAdmin}
+	luResp = usecase.ListUsers(context.Background(), luReq, env).(output.ListUsersResponse)
+	if luResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", luResp.Status, http.StatusOK)
+		t.Log(luResp.Err)
+		return
+	}
+	if len(luResp.Users) != 1 || luResp.Users[0].ID != henryID {
+		t.Errorf("unexpected users: %v", luResp.Users)
+		return
+	}
+
+	guReq := input.GetUserRequest{UserID: henryID, AdminToken: mockAdmin}
+	guResp := usecase.GetUser(context.Background(), guReq, env).(output.GetUserResponse)
+	if guResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", guResp.Status, http.StatusOK)
+		t.Log(guResp.Err)
+		return
+	}
+	if !guResp.User.Pending {
+		t.Error("user should be pending")
+		return
+	}
+
+	grReq := input.GrantRoleRequest{UserID: henryID, Role: "admin", AdminToken: mockAdmin}
+	grResp := usecase.GrantRole(context.Background(), grReq, env).(output.GrantRoleResponse)
+	if grResp.Status != http.StatusForbidden {
+		t.Errorf("%d != %d", grResp.Status, http.StatusForbidden)
+		return
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	env := getEnv(t)
+
+	cReq := input.CreateUserRequest{UserID: ivanID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	secret, err := redis.String(env.KVS.Do("HGET", "user:"+ivanID, "totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	g := totp.New(secret)
+	vtReq := input.VerifyTOTPRequest{Token: g.GenerateString(), SessionID: cResp.SessionID}
+	vtResp := usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vtResp.Status, http.StatusOK)
+		t.Log(vtResp.Err)
+		return
+	}
+
+	daReq := input.DeleteAccountRequest{Password: "invalid", Token: g.GenerateString(), SessionID: cResp.SessionID}
+	daResp := usecase.DeleteAccount(context.Background(), daReq, env).(output.DeleteAccountResponse)
+	if daResp.Status != http.StatusUnauthorized {
+		t.Errorf("%d != %d", daResp.Status, http.StatusUnauthorized)
+		return
+	}
+	daReq.Password = mockPassword
+	daResp = usecase.DeleteAccount(context.Background(), daReq, env).(output.DeleteAccountResponse)
+	if daResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", daResp.Status, http.StatusOK)
+		t.Log(daResp.Err)
+		return
+	}
+
+	// sessions are revoked
+	if _, err := redis.String(env.KVS.Do("GET", "session:"+cResp.SessionID)); err != redis.ErrNil {
+		t.Errorf("session should be deleted: %v", err)
+		return
+	}
+	// user ID cannot be registered again
+	cResp = usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusConflict {
+		t.Errorf("%d != %d", cResp.Status, http.StatusConflict)
+		return
+	}
+}
+
+func TestSuspendUser(t *testing.T) {
+	env := getEnv(t)
+	env.AdminToken = mockAdmin
+
+	cReq := input.CreateUserRequest{UserID: judyID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	secret, err := redis.String(env.KVS.Do("HGET", "user:"+judyID, "totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	g := totp.New(secret)
+	vtReq := input.VerifyTOTPRequest{Token: g.GenerateString(), SessionID: cResp.SessionID}
+	vtResp := usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vtResp.Status, http.StatusOK)
+		t.Log(vtResp.Err)
+		return
+	}
+
+	// pending user cannot be suspended
+	suReq := input.SuspendUserRequest{UserID: judyID, Reason: "spam", AdminToken: mockAdmin}
+	suResp := usecase.SuspendUser(context.Background(), suReq, env).(output.SuspendUserResponse)
+	if suResp.Status != http.StatusForbidden {
+		t.Errorf("%d != %d", suResp.Status, http.StatusForbidden)
+		return
+	}
+
+	var mailBuf bytes.Buffer
+	env.Mail = mail.NewLog(&mailBuf)
+	umReq := input.UpdateEmailRequest{Email: judyID + "@example.com", SessionID: cResp.SessionID}
+	umResp := usecase.UpdateEmail(context.Background(), umReq, env).(output.UpdateEmailResponse)
+	if umResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", umResp.Status, http.StatusOK)
+		t.Log(umResp.Err)
+		return
+	}
+	vmReq := lastVerifyRequest(t, &mailBuf)
+	vmResp := usecase.VerifyEmail(context.Background(), vmReq, env).(output.VerifyEmailResponse)
+	if vmResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vmResp.Status, http.StatusOK)
+		return
+	}
+
+	atReq := input.AuthByTOTPRequest{UserID: judyID, Token: g.GenerateString()}
+	atResp := usecase.AuthByTOTP(context.Background(), atReq, env).(output.AuthByTOTPResponse)
+	if atResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", atResp.Status, http.StatusOK)
+		t.Log(atResp.Err)
+		return
+	}
+
+	suReq = input.SuspendUserRequest{UserID: judyID, Status: "deleted", AdminToken: mockAdmin}
+	suResp = usecase.SuspendUser(context.Background(), suReq, env).(output.SuspendUserResponse)
+	if suResp.Status != http.StatusBadRequest {
+		t.Errorf("%d != %d", suResp.Status, http.StatusBadRequest)
+		return
+	}
+	suReq = input.SuspendUserRequest{UserID: judyID, Reason: "spam", AdminToken: mockAdmin}
+	suResp = usecase.SuspendUser(context.Background(), suReq, env).(output.SuspendUserResponse)
+	if suResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", suResp.Status, http.StatusOK)
+		t.Log(suResp.Err)
+		return
+	}
+
+	// live sessions are revoked
+	apReq := input.AuthByPasswordRequest{SessionID: atResp.SessionID, Password: mockPassword}
+	apResp := usecase.AuthByPassword(context.Background(), apReq, env).(output.AuthByPasswordResponse)
+	if apResp.Status == http.StatusOK {
+		t.Error("session should be revoked")
+		return
+	}
+	atResp = usecase.AuthByTOTP(context.Background(), atReq, env).(output.AuthByTOTPResponse)
+	if atResp.Status != http.StatusForbidden {
+		t.Errorf("%d != %d", atResp.Status, http.StatusForbidden)
+		return
+	}
+	guReq := input.GetUserRequest{UserID: judyID, AdminToken: mockAdmin}
+	guResp := usecase.GetUser(context.Background(), guReq, env).(output.GetUserResponse)
+	if guResp.User.Status != string(entity.StatusSuspended) || guResp.User.StatusReason != "spam" {
+		t.Errorf("unexpected status: %s (%s)", guResp.User.Status, guResp.User.StatusReason)
+		return
+	}
+
+	raReq := input.ReactivateUserRequest{UserID: judyID, AdminToken: mockAdmin}
+	raResp := usecase.ReactivateUser(context.Background(), raReq, env).(output.ReactivateUserResponse)
+	if raResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", raResp.Status, http.StatusOK)
+		t.Log(raResp.Err)
+		return
+	}
+	atResp = usecase.AuthByTOTP(context.Background(), atReq, env).(output.AuthByTOTPResponse)
+	if atResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", atResp.Status, http.StatusOK)
+		t.Log(atResp.Err)
+		return
+	}
+}
+
+func TestProfile(t *testing.T) {
+	env := getEnv(t)
+	env.AdminToken = mockAdmin
+	env.ProfileSchema = entity.DefaultProfileSchema()
+	env.ProfileSchema["locale"] = entity.ProfileAttribute{Type: entity.AttributeLocale, Visibility: entity.VisibilityUser, Claim: true}
+	env.ProfileSchema["employee_number"] = entity.ProfileAttribute{Type: entity.AttributeInt, Visibility: entity.VisibilityReadOnly, Claim: true}
+	env.ProfileSchema["note"] = entity.ProfileAttribute{Type: entity.AttributeString, Visibility: entity.VisibilityAdmin}
+
+	cReq := input.CreateUserRequest{UserID: kevinID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+
+	// pending user cannot have profile
+	upReq := input.UpdateProfileRequest{Profile: input.Profile{"locale": "ja-JP"}, SessionID: cResp.SessionID}
+	upResp := usecase.UpdateProfile(context.Background(), upReq, env).(output.UpdateProfileResponse)
+	if upResp.Status != http.StatusForbidden {
+		t.Errorf("%d != %d", upResp.Status, http.StatusForbidden)
+		return
+	}
+
+	secret, err := redis.String(env.KVS.Do("HGET", "user:"+kevinID, "totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	g := totp.New(secret)
+	vtReq := input.VerifyTOTPRequest{Token: g.GenerateString(), SessionID: cResp.SessionID}
+	vtResp := usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vtResp.Status, http.StatusOK)
+		t.Log(vtResp.Err)
+		return
+	}
+	var mailBuf bytes.Buffer
+	env.Mail = mail.NewLog(&mailBuf)
+	umReq := input.UpdateEmailRequest{Email: kevinID + "@example.com", SessionID: cResp.SessionID}
+	umResp := usecase.UpdateEmail(context.Background(), umReq, env).(output.UpdateEmailResponse)
+	if umResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", umResp.Status, http.StatusOK)
+		t.Log(umResp.Err)
+		return
+	}
+	vmReq := lastVerifyRequest(t, &mailBuf)
+	vmResp := usecase.VerifyEmail(context.Background(), vmReq, env).(output.VerifyEmailResponse)
+	if vmResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vmResp.Status, http.StatusOK)
+		return
+	}
+	atReq := input.AuthByTOTPRequest{UserID: kevinID, Token: g.GenerateString()}
+	atResp := usecase.AuthByTOTP(context.Background(), atReq, env).(output.AuthByTOTPResponse)
+	if atResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", atResp.Status, http.StatusOK)
+		t.Log(atResp.Err)
+		return
+	}
+	sessid := atResp.SessionID
+
+	candidates := []struct {
+		profile input.Profile
+		status  int
+	}{
+		{input.Profile{"locale": "ja-JP", "timezone": "Asia/Tokyo"}, http.StatusOK},
+		{input.Profile{"locale": "ja_JP"}, http.StatusBadRequest},
+		{input.Profile{"avatar_url": "javascript:alert(1)"}, http.StatusBadRequest},
+		{input.Profile{"employee_number": "42"}, http.StatusBadRequest},
+		{input.Profile{"note": "foo"}, http.StatusBadRequest},
+		{input.Profile{"unknown": "foo"}, http.StatusBadRequest},
+	}
+	for _, c := range candidates {
+		upReq := input.UpdateProfileRequest{Profile: c.profile, SessionID: sessid}
+		upResp := usecase.UpdateProfile(context.Background(), upReq, env).(output.UpdateProfileResponse)
+		if upResp.Status != c.status {
+			t.Errorf("%v: %d != %d", c.profile, upResp.Status, c.status)
+			t.Log(upResp.Err)
+			return
+		}
+	}
+
+	uupReq := input.UpdateUserProfileRequest{UserID: kevinID, Profile: input.Profile{"employee_number": "42", "note": "foo"}, AdminToken: mockAdmin}
+	uupResp := usecase.UpdateUserProfile(context.Background(), uupReq, env).(output.UpdateUserProfileResponse)
+	if uupResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", uupResp.Status, http.StatusOK)
+		t.Log(uupResp.Err)
+		return
+	}
+	if uupResp.Profile["note"] != "foo" {
+		t.Errorf("admin-only attribute should be visible to admin: %v", uupResp.Profile)
+		return
+	}
+
+	gpResp := usecase.GetProfile(context.Background(), input.GetProfileRequest{SessionID: sessid}, env).(output.GetProfileResponse)
+	if gpResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", gpResp.Status, http.StatusOK)
+		t.Log(gpResp.Err)
+		return
+	}
+	expected := output.Profile{"locale": "ja-JP", "timezone": "Asia/Tokyo", "employee_number": "42"}
+	if !reflect.DeepEqual(gpResp.Profile, expected) {
+		t.Errorf("%v != %v", gpResp.Profile, expected)
+		return
+	}
+
+	apReq := input.AuthByPasswordRequest{SessionID: sessid, Password: mockPassword}
+	apResp := usecase.AuthByPassword(context.Background(), apReq, env).(output.AuthByPasswordResponse)
+	if apResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", apResp.Status, http.StatusOK)
+		t.Log(apResp.Err)
+		return
+	}
+	tk, _ := jwt.Parse(apResp.Token, func(*jwt.Token) (interface{}, error) { return &env.PrivateKey.PublicKey, nil })
+	claims := tk.Claims.(jwt.MapClaims)
+	if claims["locale"] != "ja-JP" || claims["employee_number"] != float64(42) {
+		t.Errorf("unexpected claims: %v", claims)
+		return
+	}
+	if _, ok := claims["timezone"]; ok {
+		t.Error("timezone should not be a claim")
+		return
+	}
+}
+
+func TestRoleClaims(t *testing.T) {
+	env := getEnv(t)
+	env.AdminToken = mockAdmin
+	env.Audiences = []string{"billing", "wiki"}
+
+	cReq := input.CreateUserRequest{UserID: leoID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	secret, err := redis.String(env.KVS.Do("HGET", "user:"+leoID, "totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	g := totp.New(secret)
+	vtReq := input.VerifyTOTPRequest{Token: g.GenerateString(), SessionID: cResp.SessionID}
+	vtResp := usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vtResp.Status, http.StatusOK)
+		t.Log(vtResp.Err)
+		return
+	}
+
+	// pending user cannot join groups
+	amReq := input.AddGroupMemberRequest{Group: "staff", UserID: leoID, AdminToken: mockAdmin}
+	amResp := usecase.AddGroupMember(context.Background(), amReq, env).(output.AddGroupMemberResponse)
+	if amResp.Status != http.StatusForbidden {
+		t.Errorf("%d != %d", amResp.Status, http.StatusForbidden)
+		return
+	}
+
+	var mailBuf bytes.Buffer
+	env.Mail = mail.NewLog(&mailBuf)
+	umReq := input.UpdateEmailRequest{Email: leoID + "@example.com", SessionID: cResp.SessionID}
+	umResp := usecase.UpdateEmail(context.Background(), umReq, env).(output.UpdateEmailResponse)
+	if umResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", umResp.Status, http.StatusOK)
+		t.Log(umResp.Err)
+		return
+	}
+	vmReq := lastVerifyRequest(t, &mailBuf)
+	vmResp := usecase.VerifyEmail(context.Background(), vmReq, env).(output.VerifyEmailResponse)
+	if vmResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vmResp.Status, http.StatusOK)
+		return
+	}
+
+	amResp = usecase.AddGroupMember(context.Background(), amReq, env).(output.AddGroupMemberResponse)
+	if amResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", amResp.Status, http.StatusOK)
+		t.Log(amResp.Err)
+		return
+	}
+	for _, role := range []string{"billing:approver", "wiki:editor"} {
+		ggReq := input.GrantGroupRoleRequest{Group: "staff", Role: role, AdminToken: mockAdmin}
+		ggResp := usecase.GrantGroupRole(context.Background(), ggReq, env).(output.GrantGroupRoleResponse)
+		if ggResp.Status != http.StatusOK {
+			t.Errorf("%d != %d", ggResp.Status, http.StatusOK)
+			t.Log(ggResp.Err)
+			return
+		}
+	}
+	grReq := input.GrantRoleRequest{UserID: leoID, Role: "employee", AdminToken: mockAdmin}
+	grResp := usecase.GrantRole(context.Background(), grReq, env).(output.GrantRoleResponse)
+	if grResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", grResp.Status, http.StatusOK)
+		t.Log(grResp.Err)
+		return
+	}
+	riReq := input.AddRoleInheritanceRequest{Role: "billing:approver", InheritedRole: "billing:viewer", AdminToken: mockAdmin}
+	riResp := usecase.AddRoleInheritance(context.Background(), riReq, env).(output.AddRoleInheritanceResponse)
+	if riResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", riResp.Status, http.StatusOK)
+		t.Log(riResp.Err)
+		return
+	}
+
+	ggResp := usecase.GetGroup(context.Background(), input.GetGroupRequest{Group: "staff", AdminToken: mockAdmin}, env).(output.GetGroupResponse)
+	if ggResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", ggResp.Status, http.StatusOK)
+		t.Log(ggResp.Err)
+		return
+	}
+	if !reflect.DeepEqual(ggResp.Group.Members, []string{leoID}) {
+		t.Errorf("unexpected members: %v", ggResp.Group.Members)
+		return
+	}
+
+	atReq := input.AuthByTOTPRequest{UserID: leoID, Token: g.GenerateString()}
+	atResp := usecase.AuthByTOTP(context.Background(), atReq, env).(output.AuthByTOTPResponse)
+	if atResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", atResp.Status, http.StatusOK)
+		t.Log(atResp.Err)
+		return
+	}
+	apReq := input.AuthByPasswordRequest{SessionID: atResp.SessionID, Password: mockPassword}
+	apResp := usecase.AuthByPassword(context.Background(), apReq, env).(output.AuthByPasswordResponse)
+	if apResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", apResp.Status, http.StatusOK)
+		t.Log(apResp.Err)
+		return
+	}
+
+	itReq := input.IssueTokenRequest{Audience: "unknown", SessionID: atResp.SessionID}
+	itResp := usecase.IssueToken(context.Background(), itReq, env).(output.IssueTokenResponse)
+	if itResp.Status != http.StatusBadRequest {
+		t.Errorf("%d != %d", itResp.Status, http.StatusBadRequest)
+		return
+	}
+
+	candidates := []struct {
+		audience string
+		roles    []interface{}
+	}{
+		{"", []interface{}{"employee"}},
+		{"billing", []interface{}{"billing:approver", "billing:viewer", "employee"}},
+		{"wiki", []interface{}{"employee", "wiki:editor"}},
+	}
+	for _, c := range candidates {
+		itReq := input.IssueTokenRequest{Audience: c.audience, SessionID: atResp.SessionID}
+		itResp := usecase.IssueToken(context.Background(), itReq, env).(output.IssueTokenResponse)
+		if itResp.Status != http.StatusOK {
+			t.Errorf("%d != %d", itResp.Status, http.StatusOK)
+			t.Log(itResp.Err)
+			return
+		}
+		tk, _ := jwt.Parse(itResp.Token, func(*jwt.Token) (interface{}, error) { return &env.PrivateKey.PublicKey, nil })
+		claims := tk.Claims.(jwt.MapClaims)
+		if !reflect.DeepEqual(claims["roles"], c.roles) {
+			t.Errorf("%s: %v != %v", c.audience, claims["roles"], c.roles)
+			return
+		}
+		if !reflect.DeepEqual(claims["groups"], []interface{}{"staff"}) {
+			t.Errorf("%s: unexpected groups: %v", c.audience, claims["groups"])
+			return
+		}
+	}
+}
+
+func TestLegacyPasswordHash(t *testing.T) {
+	env := getEnv(t)
+	env.AuthFlow = entity.AuthFlow{{entity.AuthMethodPassword}}
+
+	cReq := input.CreateUserRequest{UserID: mikeID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	secret, err := redis.String(env.KVS.Do("HGET", "user:"+mikeID, "totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	vtReq := input.VerifyTOTPRequest{Token: totp.New(secret).GenerateString(), SessionID: cResp.SessionID}
+	vtResp := usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vtResp.Status, http.StatusOK)
+		t.Log(vtResp.Err)
+		return
+	}
+
+	// imported from htpasswd
+	repo := env.GetUserRepository()
+	u, err := repo.FindUserByID(context.Background(), mikeID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	u.Password = "{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g="
+	if err := repo.UpdateUser(context.Background(), u); err != nil {
+		t.Error(err)
+		return
+	}
+
+	apReq := input.AuthByPasswordRequest{UserID: mikeID, Password: "invalid"}
+	apResp := usecase.AuthByPassword(context.Background(), apReq, env).(output.AuthByPasswordResponse)
+	if apResp.Status != http.StatusUnauthorized {
+		t.Errorf("%d != %d", apResp.Status, http.StatusUnauthorized)
+		return
+	}
+	apReq.Password = mockPassword
+	apResp = usecase.AuthByPassword(context.Background(), apReq, env).(output.AuthByPasswordResponse)
+	if apResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", apResp.Status, http.StatusOK)
+		t.Log(apResp.Err)
+		return
+	}
+
+	// the hash is replaced with the current one
+	u, err = repo.FindUserByID(context.Background(), mikeID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if ok, rehash := util.VerifyPassword(u.Password, mockPassword, mikeID); !ok || rehash {
+		t.Errorf("password should be rehashed: %s", u.Password)
+		return
+	}
+}
+
+func TestUserIDNormalization(t *testing.T) {
+	env := getEnv(t)
+
+	cReq := input.CreateUserRequest{UserID: "Ｎancy", Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	for _, userID := range []string{nancyID, "NANCY", "Ｎａｎｃｙ"} {
+		eReq := input.ExistsUserRequest{UserID: userID}
+		eResp := usecase.ExistsUser(context.Background(), eReq, env).(output.ExistsUserResponse)
+		if eResp.Status != http.StatusOK {
+			t.Errorf("%d != %d", eResp.Status, http.StatusOK)
+			t.Log(eResp.Err)
+			return
+		}
+		if !eResp.Exists {
+			t.Errorf("%s should exist", userID)
+			return
+		}
+	}
+
+	for _, userID := range []string{"nancy:1", "nancy 1", "Admin", strings.Repeat("n", 65)} {
+		cReq := input.CreateUserRequest{UserID: userID, Password: mockPassword}
+		cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+		if cResp.Status != http.StatusBadRequest {
+			t.Errorf("%d != %d (user_id: %s)", cResp.Status, http.StatusBadRequest, userID)
+			return
+		}
+	}
+}
+
+func TestInvitation(t *testing.T) {
+	env := getEnv(t)
+	env.AdminToken = mockAdmin
+	env.RegistrationMode = entity.RegistrationInvite
+
+	cReq := input.CreateUserRequest{UserID: oscarID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusForbidden {
+		t.Errorf("%d != %d", cResp.Status, http.StatusForbidden)
+		return
+	}
+
+	ciReq := input.CreateInvitationRequest{MaxUses: 1, ExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339), AdminToken: mockAdmin}
+	ciResp := usecase.CreateInvitation(context.Background(), ciReq, env).(output.CreateInvitationResponse)
+	if ciResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", ciResp.Status, http.StatusCreated)
+		t.Log(ciResp.Err)
+		return
+	}
+	code := ciResp.Invitation.Code
+
+	cReq = input.CreateUserRequest{UserID: oscarID, Password: mockPassword, InvitationCode: code}
+	cResp = usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	// the code has been used up
+	cReq = input.CreateUserRequest{UserID: peggyID, Password: mockPassword, InvitationCode: code}
+	cResp = usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusForbidden {
+		t.Errorf("%d != %d", cResp.Status, http.StatusForbidden)
+		return
+	}
+
+	riReq := input.RevokeInvitationRequest{Code: code, AdminToken: mockAdmin}
+	riResp := usecase.RevokeInvitation(context.Background(), riReq, env).(output.RevokeInvitationResponse)
+	if riResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", riResp.Status, http.StatusOK)
+		t.Log(riResp.Err)
+		return
+	}
+	riResp = usecase.RevokeInvitation(context.Background(), riReq, env).(output.RevokeInvitationResponse)
+	if riResp.Status != http.StatusNotFound {
+		t.Errorf("%d != %d", riResp.Status, http.StatusNotFound)
+		return
+	}
+}
+
+// completeRegistration goes through the registration process
+// and returns the response of VerifyEmail.
+func completeRegistration(t *testing.T, env *infra.Environment, userID string) output.VerifyEmailResponse {
+	return completeRegistrationWithEmail(t, env, userID, userID+"@example.com")
+}
+
+func completeRegistrationWithEmail(t *testing.T, env *infra.Environment, userID, email string) output.VerifyEmailResponse {
+	cReq := input.CreateUserRequest{UserID: userID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Fatalf("%d != %d: %s", cResp.Status, http.StatusCreated, cResp.Err)
+	}
+	secret, err := redis.String(env.KVS.Do("HGET", "user:"+userID, "totp_secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vtReq := input.VerifyTOTPRequest{Token: totp.New(secret).GenerateString(), SessionID: cResp.SessionID}
+	vtResp := usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Fatalf("%d != %d: %s", vtResp.Status, http.StatusOK, vtResp.Err)
+	}
+	var mailBuf bytes.Buffer
+	env.Mail = mail.NewLog(&mailBuf)
+	umReq := input.UpdateEmailRequest{Email: email, SessionID: cResp.SessionID}
+	usecase.UpdateEmail(context.Background(), umReq, env)
+
+	vmReq := lastVerifyRequest(t, &mailBuf)
+	return usecase.VerifyEmail(context.Background(), vmReq, env).(output.VerifyEmailResponse)
+}
+
+func TestApprovalQueue(t *testing.T) {
+	env := getEnv(t)
+	env.AdminToken = mockAdmin
+	env.RegistrationMode = entity.RegistrationApproval
+
+	for _, userID := range []string{peggyID, quentinID} {
+		vmResp := completeRegistration(t, env, userID)
+		if vmResp.Status != http.StatusAccepted {
+			t.Errorf("%d != %d", vmResp.Status, http.StatusAccepted)
+			t.Log(vmResp.Err)
+			return
+		}
+	}
+
+	lrReq := input.ListRegistrationsRequest{AdminToken: mockAdmin}
+	lrResp := usecase.ListRegistrations(context.Background(), lrReq, env).(output.ListRegistrationsResponse)
+	if lrResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", lrResp.Status, http.StatusOK)
+		t.Log(lrResp.Err)
+		return
+	}
+	var userIDs []string
+	for _, u := range lrResp.Users {
+		userIDs = append(userIDs, u.ID)
+	}
+	if !reflect.DeepEqual(userIDs, []string{peggyID, quentinID}) {
+		t.Errorf("%v != %v", userIDs, []string{peggyID, quentinID})
+		return
+	}
+
+	arReq := input.ApproveRegistrationRequest{UserID: peggyID, AdminToken: mockAdmin}
+	arResp := usecase.ApproveRegistration(context.Background(), arReq, env).(output.ApproveRegistrationResponse)
+	if arResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", arResp.Status, http.StatusOK)
+		t.Log(arResp.Err)
+		return
+	}
+	guReq := input.GetUserRequest{UserID: peggyID, AdminToken: mockAdmin}
+	guResp := usecase.GetUser(context.Background(), guReq, env).(output.GetUserResponse)
+	if guResp.Status != http.StatusOK || guResp.User.Pending {
+		t.Errorf("%s should have completed registration", peggyID)
+		return
+	}
+
+	rrReq := input.RejectRegistrationRequest{UserID: quentinID, AdminToken: mockAdmin}
+	rrResp := usecase.RejectRegistration(context.Background(), rrReq, env).(output.RejectRegistrationResponse)
+	if rrResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", rrResp.Status, http.StatusOK)
+		t.Log(rrResp.Err)
+		return
+	}
+	// the rejected user ID can be registered again
+	euReq := input.ExistsUserRequest{UserID: quentinID}
+	euResp := usecase.ExistsUser(context.Background(), euReq, env).(output.ExistsUserResponse)
+	if euResp.Exists {
+		t.Errorf("%s should not exist", quentinID)
+		return
+	}
+	// approved registration is no longer in the queue
+	arResp = usecase.ApproveRegistration(context.Background(), arReq, env).(output.ApproveRegistrationResponse)
+	if arResp.Status != http.StatusNotFound {
+		t.Errorf("%d != %d", arResp.Status, http.StatusNotFound)
+		return
+	}
+}
+
+func TestEmailPolicy(t *testing.T) {
+	env := getEnv(t)
+	env.AdminToken = mockAdmin
+	env.EmailPolicy = entity.EmailPolicy{
+		DeniedDomains:     []string{"example.org"},
+		DisposableDomains: []string{"mailinator.com"},
+	}
+
+	cReq := input.CreateUserRequest{UserID: ruthID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	secret, err := redis.String(env.KVS.Do("HGET", "user:"+ruthID, "totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	vtReq := input.VerifyTOTPRequest{Token: totp.New(secret).GenerateString(), SessionID: cResp.SessionID}
+	vtResp := usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vtResp.Status, http.StatusOK)
+		t.Log(vtResp.Err)
+		return
+	}
+
+	for _, email := range []string{"ruth", "ruth@", "Ruth <ruth@example.com>", "ruth@localhost", "ruth@[127.0.0.1]", "ruth@mail.example.org", "ruth@mailinator.com"} {
+		umReq := input.UpdateEmailRequest{Email: email, SessionID: cResp.SessionID}
+		umResp := usecase.UpdateEmail(context.Background(), umReq, env).(output.UpdateEmailResponse)
+		if umResp.Status != http.StatusBadRequest {
+			t.Errorf("%d != %d (email: %s)", umResp.Status, http.StatusBadRequest, email)
+			return
+		}
+	}
+
+	umReq := input.UpdateEmailRequest{Email: "Ruth@BÜCHER.example", SessionID: cResp.SessionID}
+	umResp := usecase.UpdateEmail(context.Background(), umReq, env).(output.UpdateEmailResponse)
+	if umResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", umResp.Status, http.StatusOK)
+		t.Log(umResp.Err)
+		return
+	}
+	guReq := input.GetUserRequest{UserID: ruthID, AdminToken: mockAdmin}
+	guResp := usecase.GetUser(context.Background(), guReq, env).(output.GetUserResponse)
+	if guResp.User.PendingEmail != "Ruth@xn--bcher-kva.example" {
+		t.Errorf("%s != %s", guResp.User.PendingEmail, "Ruth@xn--bcher-kva.example")
+		return
+	}
+}
+
+func TestEmailLogin(t *testing.T) {
+	env := getEnv(t)
+	env.AdminToken = mockAdmin
+	const email = "sybil@example.com"
+
+	vmResp := completeRegistrationWithEmail(t, env, sybilID, email)
+	if vmResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vmResp.Status, http.StatusOK)
+		t.Log(vmResp.Err)
+		return
+	}
+
+	apReq := input.AuthByPasswordRequest{UserID: "sybil@Example.COM", Password: mockPassword}
+	apResp := usecase.AuthByPassword(context.Background(), apReq, env).(output.AuthByPasswordResponse)
+	if apResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", apResp.Status, http.StatusOK)
+		t.Log(apResp.Err)
+		return
+	}
+
+	vmResp = completeRegistrationWithEmail(t, env, trentID, email)
+	if vmResp.Status != http.StatusConflict {
+		t.Errorf("%d != %d", vmResp.Status, http.StatusConflict)
+		return
+	}
+	exists, err := redis.Bool(env.KVS.Do("EXISTS", "otp:email_verify:"+trentID))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !exists {
+		t.Error("verification token should not be consumed on conflict")
+		return
+	}
+	guReq := input.GetUserRequest{UserID: trentID, AdminToken: mockAdmin}
+	guResp := usecase.GetUser(context.Background(), guReq, env).(output.GetUserResponse)
+	if guResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", guResp.Status, http.StatusOK)
+		t.Log(guResp.Err)
+		return
+	}
+	if !guResp.User.Pending {
+		t.Error("registration should not be completed with the used email address")
+		return
+	}
+}
+
+func TestEmailUserIDCollision(t *testing.T) {
+	env := getEnv(t)
+	policy := entity.DefaultUserIDPolicy()
+	policy.Symbols += "@"
+	env.UserIDPolicy = &policy
+	const walterID = "walter@example.com"
+
+	cReq := input.CreateUserRequest{UserID: walterID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+
+	vmResp := completeRegistrationWithEmail(t, env, oliviaID, "Walter@example.com")
+	if vmResp.Status != http.StatusConflict {
+		t.Errorf("%d != %d", vmResp.Status, http.StatusConflict)
+		return
+	}
+	eReq := input.ExistsUserRequest{UserID: walterID}
+	eResp := usecase.ExistsUser(context.Background(), eReq, env).(output.ExistsUserResponse)
+	if !eResp.Exists {
+		t.Errorf("%s should exist", walterID)
+		return
+	}
+}
+
+func TestEmailChange(t *testing.T) {
+	env := getEnv(t)
+	env.AdminToken = mockAdmin
+	const oldEmail = "victor@example.com"
+	const newEmail = "victor@example.net"
+
+	vmResp := completeRegistrationWithEmail(t, env, victorID, oldEmail)
+	if vmResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vmResp.Status, http.StatusOK)
+		t.Log(vmResp.Err)
+		return
+	}
+	sessids, err := redis.Strings(env.KVS.Do("SMEMBERS", "user_sessions:"+victorID))
+	if err != nil || len(sessids) == 0 {
+		t.Errorf("session not found: %v", err)
+		return
+	}
+
+	var mailBuf bytes.Buffer
+	env.Mail = mail.NewLog(&mailBuf)
+	umReq := input.UpdateEmailRequest{Email: newEmail, SessionID: sessids[0]}
+	umResp := usecase.UpdateEmail(context.Background(), umReq, env).(output.UpdateEmailResponse)
+	if umResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", umResp.Status, http.StatusOK)
+		t.Log(umResp.Err)
+		return
+	}
+	guReq := input.GetUserRequest{UserID: victorID, AdminToken: mockAdmin}
+	guResp := usecase.GetUser(context.Background(), guReq, env).(output.GetUserResponse)
+	if guResp.User.Email != oldEmail || guResp.User.PendingEmail != newEmail {
+		t.Errorf("email should be kept until verified: %s, %s", guResp.User.Email, guResp.User.PendingEmail)
+		return
+	}
+
+	vmReq := lastVerifyRequest(t, &mailBuf)
+	vmResp = usecase.VerifyEmail(context.Background(), vmReq, env).(output.VerifyEmailResponse)
+	if vmResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vmResp.Status, http.StatusOK)
+		t.Log(vmResp.Err)
+		return
+	}
+	guResp = usecase.GetUser(context.Background(), guReq, env).(output.GetUserResponse)
+	if guResp.User.Email != newEmail || !guResp.User.EmailVerified {
+		t.Errorf("%s != %s", guResp.User.Email, newEmail)
+		return
+	}
+
+	code, err := redis.String(env.KVS.Do("GET", "otp:email_revert:"+victorID))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	reReq := input.RevertEmailRequest{UserID: victorID, Code: "invalid"}
+	reResp := usecase.RevertEmail(context.Background(), reReq, env).(output.RevertEmailResponse)
+	if reResp.Status != http.StatusForbidden {
+		t.Errorf("%d != %d", reResp.Status, http.StatusForbidden)
+		return
+	}
+	reReq.Code = code
+	reResp = usecase.RevertEmail(context.Background(), reReq, env).(output.RevertEmailResponse)
+	if reResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", reResp.Status, http.StatusOK)
+		t.Log(reResp.Err)
+		return
+	}
+	guResp = usecase.GetUser(context.Background(), guReq, env).(output.GetUserResponse)
+	if guResp.User.Email != oldEmail {
+		t.Errorf("%s != %s", guResp.User.Email, oldEmail)
+		return
+	}
+	n, err := redis.Int(env.KVS.Do("SCARD", "user_sessions:"+victorID))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if n != 0 {
+		t.Errorf("%d sessions remain after revert", n)
+		return
+	}
+}
+
+func TestEmailVerificationLink(t *testing.T) {
+	env := getEnv(t)
+	env.EmailResendCooldown = time.Minute
+	var mailBuf bytes.Buffer
+	env.Mail = mail.NewLog(&mailBuf)
+
+	cReq := input.CreateUserRequest{UserID: wendyID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	secret, err := redis.String(env.KVS.Do("HGET", "user:"+wendyID, "totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	vtReq := input.VerifyTOTPRequest{Token: totp.New(secret).GenerateString(), SessionID: cResp.SessionID}
+	vtResp := usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vtResp.Status, http.StatusOK)
+		t.Log(vtResp.Err)
+		return
+	}
+	umReq := input.UpdateEmailRequest{Email: "wendy@example.com", SessionID: cResp.SessionID}
+	umResp := usecase.UpdateEmail(context.Background(), umReq, env).(output.UpdateEmailResponse)
+	if umResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", umResp.Status, http.StatusOK)
+		t.Log(umResp.Err)
+		return
+	}
+	oldReq := lastVerifyRequest(t, &mailBuf)
+
+	gvReq := input.GetEmailVerificationRequest{SessionID: cResp.SessionID}
+	gvResp := usecase.GetEmailVerification(context.Background(), gvReq, env).(output.GetEmailVerificationResponse)
+	if !gvResp.Pending || gvResp.PendingEmail != "wendy@example.com" {
+		t.Errorf("verification should be pending: %+v", gvResp)
+		return
+	}
+
+	rvReq := input.ResendEmailVerificationRequest{SessionID: cResp.SessionID}
+	rvResp := usecase.ResendEmailVerification(context.Background(), rvReq, env).(output.ResendEmailVerificationResponse)
+	if rvResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", rvResp.Status, http.StatusOK)
+		t.Log(rvResp.Err)
+		return
+	}
+	vmReq := lastVerifyRequest(t, &mailBuf)
+	rvResp = usecase.ResendEmailVerification(context.Background(), rvReq, env).(output.ResendEmailVerificationResponse)
+	if rvResp.Status != http.StatusTooManyRequests {
+		t.Errorf("%d != %d", rvResp.Status, http.StatusTooManyRequests)
+		return
+	}
+	if rvResp.RetryAfter != 60 {
+		t.Errorf("%d != %d", rvResp.RetryAfter, 60)
+		return
+	}
+
+	invalids := []input.VerifyEmailRequest{
+		oldReq,
+		{UserID: wendyID, Token: vmReq.Token + "x"},
+		{UserID: wendyID, Token: cResp.SessionID},
+		{UserID: "nobody", Token: vmReq.Token},
+	}
+	for _, invalid := range invalids {
+		vmResp := usecase.VerifyEmail(context.Background(), invalid, env).(output.VerifyEmailResponse)
+		if vmResp.Status != http.StatusForbidden {
+			t.Errorf("%d != %d (request: %+v)", vmResp.Status, http.StatusForbidden, invalid)
+			return
+		}
+	}
+
+	vmResp := usecase.VerifyEmail(context.Background(), vmReq, env).(output.VerifyEmailResponse)
+	if vmResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vmResp.Status, http.StatusOK)
+		t.Log(vmResp.Err)
+		return
+	}
+	vmResp = usecase.VerifyEmail(context.Background(), vmReq, env).(output.VerifyEmailResponse)
+	if vmResp.Status != http.StatusForbidden {
+		t.Errorf("token should be used only once: %d != %d", vmResp.Status, http.StatusForbidden)
+		return
+	}
+
+	gvResp = usecase.GetEmailVerification(context.Background(), gvReq, env).(output.GetEmailVerificationResponse)
+	if gvResp.Pending || !gvResp.EmailVerified || gvResp.Email != "wendy@example.com" {
+		t.Errorf("email should be verified: %+v", gvResp)
+		return
+	}
+}
+
+func TestResumeRegistration(t *testing.T) {
+	env := getEnv(t)
+	env.RegistrationTTL = time.Hour
+
+	cReq := input.CreateUserRequest{UserID: xavierID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	expiresAt, err := time.Parse(time.RFC3339, cResp.ExpiresAt)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if d := time.Until(expiresAt); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("registration should expire in an hour: %s", cResp.ExpiresAt)
+		return
+	}
+	ttl, err := redis.Int(env.KVS.Do("TTL", "user:"+xavierID))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if ttl <= 10*60 {
+		t.Errorf("TTL of the registration is not configured: %d", ttl)
+		return
+	}
+
+	candidates := []struct {
+		userID   string
+		password string
+		status   int
+	}{
+		{xavierID, "invalid", http.StatusUnauthorized},
+		{"unknown", mockPassword, http.StatusNotFound},
+		{aliceID, mockPassword, http.StatusNotFound},
+	}
+	for _, c := range candidates {
+		rrReq := input.ResumeRegistrationRequest{UserID: c.userID, Password: c.password}
+		rrResp := usecase.ResumeRegistration(context.Background(), rrReq, env).(output.ResumeRegistrationResponse)
+		if rrResp.Status != c.status {
+			t.Errorf("%s: %d != %d", c.userID, rrResp.Status, c.status)
+			return
+		}
+	}
+
+	rrReq := input.ResumeRegistrationRequest{UserID: xavierID, Password: mockPassword}
+	rrResp := usecase.ResumeRegistration(context.Background(), rrReq, env).(output.ResumeRegistrationResponse)
+	if rrResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", rrResp.Status, http.StatusOK)
+		t.Log(rrResp.Err)
+		return
+	}
+	if rrResp.SessionID == "" || rrResp.SessionID == cResp.SessionID {
+		t.Error("a new session should be returned")
+		return
+	}
+	secret, err := redis.String(env.KVS.Do("HGET", "user:"+xavierID, "totp_secret"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	vtReq := input.VerifyTOTPRequest{Token: totp.New(secret).GenerateString(), SessionID: rrResp.SessionID}
+	vtResp := usecase.VerifyTOTP(context.Background(), vtReq, env).(output.VerifyTOTPResponse)
+	if vtResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vtResp.Status, http.StatusOK)
+		t.Log(vtResp.Err)
+		return
+	}
+}
+
+func TestDuplicateRegistration(t *testing.T) {
+	env := getEnv(t)
+
+	vmResp := completeRegistration(t, env, yvonneID)
+	if vmResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vmResp.Status, http.StatusOK)
+		t.Log(vmResp.Err)
+		return
+	}
+	cReq := input.CreateUserRequest{UserID: yvonneID, Password: mockPassword}
+	cResp := usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusConflict {
+		t.Errorf("%d != %d", cResp.Status, http.StatusConflict)
+		return
+	}
+
+	cReq = input.CreateUserRequest{UserID: zoeID, Password: mockPassword}
+	cResp = usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	cReq = input.CreateUserRequest{UserID: strings.ToUpper(zoeID), Password: "another"}
+	cResp = usecase.CreateUser(context.Background(), cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusConflict {
+		t.Errorf("%d != %d", cResp.Status, http.StatusConflict)
+		return
+	}
+	// the pending registration is not overwritten
+	rrReq := input.ResumeRegistrationRequest{UserID: zoeID, Password: mockPassword}
+	rrResp := usecase.ResumeRegistration(context.Background(), rrReq, env).(output.ResumeRegistrationResponse)
+	if rrResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", rrResp.Status, http.StatusOK)
+		t.Log(rrResp.Err)
+		return
+	}
+}
+
+func TestInterruptedPromotion(t *testing.T) {
+	env := getEnv(t)
+	ctx := context.Background()
+	marked := time.Now().Add(-time.Hour).Unix()
+
+	// crashed before inserted into MySQL
+	cReq := input.CreateUserRequest{UserID: amberID, Password: mockPassword}
+	cResp := usecase.CreateUser(ctx, cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	if _, err := env.KVS.Do("HSET", "user:"+amberID, "totp_verified", true, "promoting", marked); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// crashed before deleted from Redis
+	vmResp := completeRegistration(t, env, brunoID)
+	if vmResp.Status != http.StatusOK {
+		t.Errorf("%d != %d", vmResp.Status, http.StatusOK)
+		t.Log(vmResp.Err)
+		return
+	}
+	u, err := env.GetUserRepository().FindUserByID(ctx, brunoID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := env.KVS.Do("HSET", "user:"+brunoID, "password", u.Password, "created_at", u.CreatedAt.Unix(), "promoting", marked); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// running promotions are not reconciled
+	cReq = input.CreateUserRequest{UserID: chloeID, Password: mockPassword}
+	cResp = usecase.CreateUser(ctx, cReq, env).(output.CreateUserResponse)
+	if cResp.Status != http.StatusCreated {
+		t.Errorf("%d != %d", cResp.Status, http.StatusCreated)
+		t.Log(cResp.Err)
+		return
+	}
+	if _, err := env.KVS.Do("HSET", "user:"+chloeID, "promoting", time.Now().Unix()); err != nil {
+		t.Error(err)
+		return
+	}
+
+	dryResult, err := database.ReconcilePromotions(ctx, env.RDB, env.KVS, time.Minute, true)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	result, err := database.ReconcilePromotions(ctx, env.RDB, env.KVS, time.Minute, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if result.Promoted < 1 || result.Cleaned < 1 {
+		t.Errorf("unexpected result: %+v", result)
+		return
+	}
+	if dryResult != result {
+		t.Errorf("dry run %+v != %+v", dryResult, result)
+		return
+	}
+	for _, userID := range []string{amberID, brunoID} {
+		exists, err := redis.Bool(env.KVS.Do("EXISTS", "user:"+userID))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if exists {
+			t.Errorf("%s should be deleted from Redis", userID)
+			return
+		}
+		euResp := usecase.ExistsUser(ctx, input.ExistsUserRequest{UserID: userID}, env).(output.ExistsUserResponse)
+		if !euResp.Exists {
+			t.Errorf("%s should exist", userID)
+			return
+		}
+	}
+	exists, err := redis.Bool(env.KVS.Do("EXISTS", "user:"+chloeID))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !exists {
+		t.Error("chloe should be left in Redis")
+		return
+	}
 }
@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/nasa9084/ident/domain/entity"
+	"github.com/nasa9084/ident/generator"
+	"github.com/nasa9084/ident/infra"
+	"github.com/nasa9084/ident/usecase/input"
+	"github.com/nasa9084/ident/usecase/output"
+)
+
+const otpPurposeEmailVerify = "email_verify"
+
+var errVerificationTokenInvalid = errors.New("verification token is invalid or expired")
+
+// verificationSubject returns the subject to store the verification
+// token for, which binds the token to the pending address.
+// Tokens sent to an address are not valid for another address.
+func verificationSubject(u entity.User) string {
+	return u.ID + " " + u.PendingEmail
+}
+
+// sendVerification sends the verification mail to the pending email
+// of the user. Links sent before are no longer valid.
+func sendVerification(ctx context.Context, env *infra.Environment, u entity.User) error {
+	token, err := generator.NewVerificationToken()
+	if err != nil {
+		return err
+	}
+	if err := env.GetOTPRepository().SaveOTP(ctx, otpPurposeEmailVerify, verificationSubject(u), token, env.GetEmailVerifyTTL()); err != nil {
+		return err
+	}
+	return env.SendVerifyMail(u.PendingEmail, u.ID, token)
+}
+
+// GetEmailVerification returns the email of the user, and whether
+// verification of the pending email is pending or not.
+func GetEmailVerification(ctx context.Context, req input.GetEmailVerificationRequest, env *infra.Environment) output.Response {
+	var resp output.GetEmailVerificationResponse
+	u, err := env.GetUserRepository().FindUserBySessionID(ctx, req.SessionID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Email = u.Email
+	resp.EmailVerified = u.EmailVerified
+	resp.PendingEmail = u.PendingEmail
+	resp.Pending = u.PendingEmail != ""
+	resp.Status = http.StatusOK
+	return resp
+}
+
+// ResendEmailVerification sends the verification mail again
+// to the pending email of the user.
+// Resending is limited to once per env.EmailResendCooldown.
+func ResendEmailVerification(ctx context.Context, req input.ResendEmailVerificationRequest, env *infra.Environment) output.Response {
+	var resp output.ResendEmailVerificationResponse
+	u, err := env.GetUserRepository().FindUserBySessionID(ctx, req.SessionID)
+	if err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	if u.PendingEmail == "" {
+		resp.Err = errors.New("no email address is waiting for verification")
+		resp.Status = http.StatusBadRequest
+		return resp
+	}
+	if env.EmailResendCooldown > 0 {
+		count, err := env.GetRateLimitRepository().Incr(ctx, "verify_mail:"+u.ID, env.EmailResendCooldown)
+		if err != nil {
+			resp.Err = err
+			resp.Status = statusFromError(err)
+			return resp
+		}
+		if count > 1 {
+			resp.Err = errors.New("verification mail has been sent recently")
+			resp.Status = http.StatusTooManyRequests
+			resp.RetryAfter = int((env.EmailResendCooldown + time.Second - 1) / time.Second)
+			return resp
+		}
+	}
+	if err := sendVerification(ctx, env, u); err != nil {
+		resp.Err = err
+		resp.Status = statusFromError(err)
+		return resp
+	}
+	resp.Status = http.StatusOK
+	return resp
+}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	maxDomainLength = 253
+	maxLabelLength  = 63
+)
+
+// ToASCIIDomain converts the domain name to ASCII in lower case
+// with the IDNA lookup profile (UTS #46), so that a domain name
+// has only one form.
+func ToASCIIDomain(domain string) (string, error) {
+	domain, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", err
+	}
+	if domain == "" {
+		return "", errors.New("domain is empty")
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" {
+			return "", errors.New("domain has an empty label")
+		}
+		if len(label) > maxLabelLength {
+			return "", fmt.Errorf("domain label is too long: %s", label)
+		}
+	}
+	if len(domain) > maxDomainLength {
+		return "", errors.New("domain is too long")
+	}
+	return domain, nil
+}
@@ -0,0 +1,42 @@
+package util_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nasa9084/ident/util"
+)
+
+func TestToASCIIDomain(t *testing.T) {
+	candidates := []struct {
+		in       string
+		expected string
+		hasErr   bool
+	}{
+		{"example.com", "example.com", false},
+		{"Example.COM", "example.com", false},
+		{"bücher.example", "xn--bcher-kva.example", false},
+		{"BÜCHER.example", "xn--bcher-kva.example", false},
+		{"münchen.de", "xn--mnchen-3ya.de", false},
+		{"日本語。jp", "xn--wgv71a119e.jp", false},
+		{"ｅｘａｍｐｌｅ.com", "example.com", false},
+		{"xn--bcher-kva.example", "xn--bcher-kva.example", false},
+		{"", "", true},
+		{"example..com", "", true},
+		{"-example.com", "", true},
+		{"exa_mple.com", "", true},
+		{"exa mple.com", "", true},
+		{"☃.example", "xn--n3h.example", false},
+		{strings.Repeat("a", 64) + ".com", "", true},
+	}
+	for _, c := range candidates {
+		out, err := util.ToASCIIDomain(c.in)
+		if (err != nil) != c.hasErr {
+			t.Errorf("unexpected error: %v (in: %s)", err, c.in)
+			continue
+		}
+		if out != c.expected {
+			t.Errorf("%s != %s", out, c.expected)
+		}
+	}
+}
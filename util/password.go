@@ -0,0 +1,457 @@
+package util
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// HashFormat is a format of password hashes made by other systems.
+type HashFormat interface {
+	// Match returns whether the hash is in the format.
+	Match(hash string) bool
+	// Verify returns whether the password matches the hash.
+	Verify(hash, password string) bool
+}
+
+// hashFormats are legacy hash formats accepted by VerifyPassword.
+var hashFormats = []HashFormat{
+	bcryptFormat{},
+	sha512CryptFormat{},
+	md5CryptFormat{},
+	pbkdf2Format{},
+	htpasswdSHA1Format{},
+}
+
+// hashChecker is implemented by hash formats which can reject hashes
+// before verifying passwords.
+type hashChecker interface {
+	// Check returns an error if the hash cannot be verified,
+	// e.g. it is malformed or costs too much to verify.
+	Check(hash string) error
+}
+
+// CheckHash returns an error if the hash cannot be verified by
+// VerifyPassword: it is neither made by Hash nor in one of the
+// registered legacy formats, or it is malformed or too costly.
+func CheckHash(hashed string) error {
+	if isCurrentHash(hashed) {
+		return nil
+	}
+	for _, f := range hashFormats {
+		if !f.Match(hashed) {
+			continue
+		}
+		if c, ok := f.(hashChecker); ok {
+			return c.Check(hashed)
+		}
+		return nil
+	}
+	return errors.New("unknown password hash format")
+}
+
+// RegisterHashFormat registers a legacy hash format accepted by VerifyPassword.
+func RegisterHashFormat(f HashFormat) {
+	hashFormats = append(hashFormats, f)
+}
+
+// VerifyPassword verifies the password against the hash made by Hash
+// with the salt, or in one of the registered legacy formats.
+// rehash is true if the password is verified using a legacy hash,
+// which should be replaced with the hash made by Hash.
+func VerifyPassword(hashed, password, salt string) (ok, rehash bool) {
+	if isCurrentHash(hashed) {
+		return secureCompare(hashed, Hash(password, salt)), false
+	}
+	for _, f := range hashFormats {
+		if f.Match(hashed) {
+			ok := f.Verify(hashed, password)
+			return ok, ok
+		}
+	}
+	return false, false
+}
+
+// isCurrentHash returns whether the hash is made by Hash,
+// which is a hex digest of SHA512.
+func isCurrentHash(hashed string) bool {
+	if len(hashed) != sha512.Size*2 {
+		return false
+	}
+	for _, c := range hashed {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// bcryptFormat is bcrypt ($2a$, $2b$ and $2y$).
+type bcryptFormat struct{}
+
+// bcryptMaxCost is lower than bcrypt.MaxCost, since each increment
+// doubles the CPU time to verify. Hashes with higher cost are rejected.
+const bcryptMaxCost = 16
+
+func (bcryptFormat) Match(hashed string) bool {
+	return strings.HasPrefix(hashed, "$2a$") || strings.HasPrefix(hashed, "$2b$") || strings.HasPrefix(hashed, "$2y$")
+}
+
+func (f bcryptFormat) Verify(hashed, password string) bool {
+	if f.Check(hashed) != nil {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password)) == nil
+}
+
+func (bcryptFormat) Check(hashed string) error {
+	cost, err := bcrypt.Cost([]byte(hashed))
+	if err != nil {
+		return err
+	}
+	if cost > bcryptMaxCost {
+		return fmt.Errorf("too high cost of bcrypt: %d (max: %d)", cost, bcryptMaxCost)
+	}
+	return nil
+}
+
+// htpasswdSHA1Format is Apache htpasswd SHA1 format ({SHA}).
+type htpasswdSHA1Format struct{}
+
+func (htpasswdSHA1Format) Match(hashed string) bool { return strings.HasPrefix(hashed, "{SHA}") }
+
+func (htpasswdSHA1Format) Verify(hashed, password string) bool {
+	sum := sha1.Sum([]byte(password))
+	return secureCompare(hashed, "{SHA}"+base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+// pbkdf2Format is PBKDF2 in Django format (pbkdf2_sha256$iterations$salt$hash)
+// or passlib format ($pbkdf2-sha256$iterations$salt$hash).
+type pbkdf2Format struct{}
+
+var pbkdf2Digests = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+func (pbkdf2Format) Match(hashed string) bool {
+	return strings.HasPrefix(hashed, "pbkdf2_") || strings.HasPrefix(hashed, "$pbkdf2$") || strings.HasPrefix(hashed, "$pbkdf2-")
+}
+
+// pbkdf2MaxIterations is ten times the default of recent Django.
+// Hashes with more iterations are rejected, since verifying them
+// costs too much CPU time.
+const pbkdf2MaxIterations = 10000000
+
+func (pbkdf2Format) Verify(hashed, password string) bool {
+	p, err := parsePBKDF2(hashed)
+	if err != nil {
+		return false
+	}
+	key := pbkdf2.Key([]byte(password), p.salt, p.iterations, len(p.sum), p.digest)
+	return subtle.ConstantTimeCompare(key, p.sum) == 1
+}
+
+func (pbkdf2Format) Check(hashed string) error {
+	_, err := parsePBKDF2(hashed)
+	return err
+}
+
+type pbkdf2Hash struct {
+	digest     func() hash.Hash
+	iterations int
+	salt       []byte
+	sum        []byte
+}
+
+func parsePBKDF2(hashed string) (pbkdf2Hash, error) {
+	var algorithm, iterations, salt, sum string
+	var decode func(string) ([]byte, error)
+	malformed := errors.New("malformed PBKDF2 hash")
+	if strings.HasPrefix(hashed, "$") {
+		fields := strings.Split(hashed, "$")
+		if len(fields) != 5 {
+			return pbkdf2Hash{}, malformed
+		}
+		algorithm = strings.TrimPrefix(strings.TrimPrefix(fields[1], "pbkdf2"), "-")
+		if algorithm == "" {
+			algorithm = "sha1"
+		}
+		iterations, sum = fields[2], fields[4]
+		// passlib uses "adapted base64", which uses "." instead of "+"
+		// and has no padding
+		decode = func(s string) ([]byte, error) {
+			return base64.RawStdEncoding.DecodeString(strings.Replace(s, ".", "+", -1))
+		}
+		b, err := decode(fields[3])
+		if err != nil {
+			return pbkdf2Hash{}, malformed
+		}
+		salt = string(b)
+	} else {
+		fields := strings.Split(hashed, "$")
+		if len(fields) != 4 {
+			return pbkdf2Hash{}, malformed
+		}
+		algorithm = strings.TrimPrefix(fields[0], "pbkdf2_")
+		iterations, salt, sum = fields[1], fields[2], fields[3]
+		decode = base64.StdEncoding.DecodeString
+	}
+	digest, ok := pbkdf2Digests[algorithm]
+	if !ok {
+		return pbkdf2Hash{}, fmt.Errorf("unsupported digest of PBKDF2: %s", algorithm)
+	}
+	iter, err := strconv.Atoi(iterations)
+	if err != nil || iter <= 0 {
+		return pbkdf2Hash{}, malformed
+	}
+	if iter > pbkdf2MaxIterations {
+		return pbkdf2Hash{}, fmt.Errorf("too many iterations of PBKDF2: %d (max: %d)", iter, pbkdf2MaxIterations)
+	}
+	expected, err := decode(sum)
+	if err != nil || len(expected) == 0 {
+		return pbkdf2Hash{}, malformed
+	}
+	return pbkdf2Hash{digest, iter, []byte(salt), expected}, nil
+}
+
+// cryptBase64 is the alphabet of base64 encoding used by crypt(3).
+const cryptBase64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// encodeCrypt encodes the sum in the byte order used by crypt(3).
+// Every three indices make four characters, and the last group may have
+// fewer indices.
+func encodeCrypt(sum []byte, order [][]int) string {
+	var b strings.Builder
+	for _, group := range order {
+		var w uint
+		for _, i := range group {
+			w = w<<8 | uint(sum[i])
+		}
+		for n := len(group) + 1; n > 0; n-- {
+			b.WriteByte(cryptBase64[w&0x3f])
+			w >>= 6
+		}
+	}
+	return b.String()
+}
+
+// md5CryptFormat is MD5-based crypt ($1$) and its Apache variant ($apr1$).
+type md5CryptFormat struct{}
+
+var md5CryptOrder = [][]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}, {11}}
+
+func (md5CryptFormat) Match(hashed string) bool {
+	return strings.HasPrefix(hashed, "$1$") || strings.HasPrefix(hashed, "$apr1$")
+}
+
+func (md5CryptFormat) Verify(hashed, password string) bool {
+	fields := strings.Split(hashed, "$")
+	if len(fields) != 4 {
+		return false
+	}
+	magic, salt := "$"+fields[1]+"$", fields[2]
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	return secureCompare(hashed, md5Crypt(password, salt, magic))
+}
+
+func md5Crypt(password, salt, magic string) string {
+	alt := md5.Sum([]byte(password + salt + password))
+	h := md5.New()
+	h.Write([]byte(password + magic + salt))
+	for n := len(password); n > 0; n -= md5.Size {
+		if n > md5.Size {
+			h.Write(alt[:])
+		} else {
+			h.Write(alt[:n])
+		}
+	}
+	for n := len(password); n > 0; n >>= 1 {
+		if n&1 == 1 {
+			h.Write([]byte{0})
+		} else {
+			h.Write([]byte{password[0]})
+		}
+	}
+	sum := h.Sum(nil)
+	for i := 0; i < 1000; i++ {
+		h := md5.New()
+		if i&1 == 1 {
+			h.Write([]byte(password))
+		} else {
+			h.Write(sum)
+		}
+		if i%3 != 0 {
+			h.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			h.Write([]byte(password))
+		}
+		if i&1 == 1 {
+			h.Write(sum)
+		} else {
+			h.Write([]byte(password))
+		}
+		sum = h.Sum(nil)
+	}
+	return magic + salt + "$" + encodeCrypt(sum, md5CryptOrder)
+}
+
+// sha512CryptFormat is SHA512-based crypt ($6$).
+type sha512CryptFormat struct{}
+
+const (
+	sha512CryptDefaultRounds = 5000
+	sha512CryptMinRounds     = 1000
+	// sha512CryptMaxRounds is lower than 999999999 allowed by the spec,
+	// since verifying such hash takes minutes of CPU time.
+	// Hashes with more rounds are rejected.
+	sha512CryptMaxRounds = 1000000
+	sha512CryptMaxSalt   = 16
+)
+
+var sha512CryptOrder = [][]int{
+	{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4},
+	{47, 5, 26}, {6, 27, 48}, {28, 49, 7}, {50, 8, 29}, {9, 30, 51},
+	{31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13}, {56, 14, 35},
+	{15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19},
+	{62, 20, 41}, {63},
+}
+
+func (sha512CryptFormat) Match(hashed string) bool { return strings.HasPrefix(hashed, "$6$") }
+
+func (sha512CryptFormat) Verify(hashed, password string) bool {
+	rounds, custom, fields, err := parseSHA512Crypt(hashed)
+	if err != nil {
+		return false
+	}
+	salt := fields[0]
+	if len(salt) > sha512CryptMaxSalt {
+		salt = salt[:sha512CryptMaxSalt]
+	}
+	return secureCompare(hashed, sha512Crypt(password, salt, rounds, custom))
+}
+
+func (sha512CryptFormat) Check(hashed string) error {
+	_, _, _, err := parseSHA512Crypt(hashed)
+	return err
+}
+
+// parseSHA512Crypt returns the rounds, whether the rounds are specified,
+// and the salt and the hash.
+func parseSHA512Crypt(hashed string) (int, bool, []string, error) {
+	fields := strings.Split(strings.TrimPrefix(hashed, "$6$"), "$")
+	rounds, custom := sha512CryptDefaultRounds, false
+	if len(fields) == 3 && strings.HasPrefix(fields[0], "rounds=") {
+		n, err := strconv.Atoi(strings.TrimPrefix(fields[0], "rounds="))
+		if err != nil {
+			return 0, false, nil, err
+		}
+		if n > sha512CryptMaxRounds {
+			return 0, false, nil, fmt.Errorf("too many rounds of SHA512-crypt: %d (max: %d)", n, sha512CryptMaxRounds)
+		}
+		if n < sha512CryptMinRounds {
+			n = sha512CryptMinRounds
+		}
+		rounds, custom = n, true
+		fields = fields[1:]
+	}
+	if len(fields) != 2 {
+		return 0, false, nil, errors.New("malformed SHA512-crypt hash")
+	}
+	return rounds, custom, fields, nil
+}
+
+func sha512Crypt(password, salt string, rounds int, custom bool) string {
+	pw, s := []byte(password), []byte(salt)
+
+	alt := sha512.Sum512(append(append(append([]byte{}, pw...), s...), pw...))
+	h := sha512.New()
+	h.Write(pw)
+	h.Write(s)
+	for n := len(pw); n > 0; n -= sha512.Size {
+		if n > sha512.Size {
+			h.Write(alt[:])
+		} else {
+			h.Write(alt[:n])
+		}
+	}
+	for n := len(pw); n > 0; n >>= 1 {
+		if n&1 == 1 {
+			h.Write(alt[:])
+		} else {
+			h.Write(pw)
+		}
+	}
+	sum := h.Sum(nil)
+
+	h = sha512.New()
+	for i := 0; i < len(pw); i++ {
+		h.Write(pw)
+	}
+	p := repeatBytes(h.Sum(nil), len(pw))
+
+	h = sha512.New()
+	for i := 0; i < 16+int(sum[0]); i++ {
+		h.Write(s)
+	}
+	ss := repeatBytes(h.Sum(nil), len(s))
+
+	for i := 0; i < rounds; i++ {
+		h := sha512.New()
+		if i&1 == 1 {
+			h.Write(p)
+		} else {
+			h.Write(sum)
+		}
+		if i%3 != 0 {
+			h.Write(ss)
+		}
+		if i%7 != 0 {
+			h.Write(p)
+		}
+		if i&1 == 1 {
+			h.Write(sum)
+		} else {
+			h.Write(p)
+		}
+		sum = h.Sum(nil)
+	}
+
+	prefix := "$6$"
+	if custom {
+		prefix += "rounds=" + strconv.Itoa(rounds) + "$"
+	}
+	return prefix + salt + "$" + encodeCrypt(sum, sha512CryptOrder)
+}
+
+// repeatBytes repeats b to make n bytes.
+func repeatBytes(b []byte, n int) []byte {
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		rest := n - len(out)
+		if rest > len(b) {
+			rest = len(b)
+		}
+		out = append(out, b[:rest]...)
+	}
+	return out
+}
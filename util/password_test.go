@@ -0,0 +1,88 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/nasa9084/ident/util"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	candidates := []struct {
+		hash     string
+		password string
+		ok       bool
+		rehash   bool
+	}{
+		{expected, password, true, false},
+		{expected, "wrong", false, false},
+		{"$2a$04$n/h5Fns1cNijdUIto39gk.6qMT1qTVU9mJ/HjqiCzg.7flUkTo4sO", "password", true, true},
+		{"$2a$04$n/h5Fns1cNijdUIto39gk.6qMT1qTVU9mJ/HjqiCzg.7flUkTo4sO", "wrong", false, false},
+		{"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1", "Hello world!", true, true},
+		{"$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v.", "Hello world!", true, true},
+		{"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1", "hello world!", false, false},
+		{"$apr1$saltsalt$yAAkm4libquA.ZWLHbSBq/", "password", true, true},
+		{"$apr1$saltsalt$yAAkm4libquA.ZWLHbSBq/", "wrong", false, false},
+		{"$1$saltsalt$qjXMvbEw8oaL.CzflDtaK/", "password", true, true},
+		{"{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=", "password", true, true},
+		{"{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=", "wrong", false, false},
+		{"pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=", "password", true, true},
+		{"pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=", "wrong", false, false},
+		{"$pbkdf2-sha512$1000$c2FsdHktc2FsdA$oWwKM9CHwqR0EmxXHYNbgQ.Bos7o935vSrqM9SHyeNLERGL.XmjnwyZ0W0xeGjZZfCOTuZ9rIA8cwFoc29UMGg", "password", true, true},
+		{"$pbkdf2$1000$c2FsdHktc2FsdA$BgH9toseUjF2tiqgYkxdAe.y2IA", "password", true, true},
+		{"pbkdf2_md5$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=", "password", false, false},
+		{"$6$rounds=1000001$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1", "Hello world!", false, false},
+		{"unknown", "unknown", false, false},
+		{"", "", false, false},
+	}
+	for _, c := range candidates {
+		ok, rehash := util.VerifyPassword(c.hash, c.password, salt)
+		if ok != c.ok || rehash != c.rehash {
+			t.Errorf("(%t, %t) != (%t, %t) (hash: %s, password: %s)", ok, rehash, c.ok, c.rehash, c.hash, c.password)
+		}
+	}
+}
+
+func TestCheckHash(t *testing.T) {
+	candidates := []struct {
+		hash string
+		ok   bool
+	}{
+		{expected, true},
+		{"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1", true},
+		{"$6$rounds=1000000$saltstring$hash", true},
+		{"$6$rounds=999999999$saltstring$hash", false},
+		{"$6$rounds=many$saltstring$hash", false},
+		{"$6$malformed", false},
+		{"$2a$04$n/h5Fns1cNijdUIto39gk.6qMT1qTVU9mJ/HjqiCzg.7flUkTo4sO", true},
+		{"$2a$31$n/h5Fns1cNijdUIto39gk.6qMT1qTVU9mJ/HjqiCzg.7flUkTo4sO", false},
+		{"$2a$malformed", false},
+		{"pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=", true},
+		{"pbkdf2_sha256$999999999$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=", false},
+		{"pbkdf2_md5$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=", false},
+		{"$pbkdf2$1000$malformed", false},
+		{"{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=", true},
+		{"unknown", false},
+		{"", false},
+	}
+	for _, c := range candidates {
+		if err := util.CheckHash(c.hash); (err == nil) != c.ok {
+			t.Errorf("unexpected result: %v (hash: %s)", err, c.hash)
+		}
+	}
+}
+
+type plainFormat struct{}
+
+func (plainFormat) Match(hash string) bool            { return len(hash) > 7 && hash[:7] == "{PLAIN}" }
+func (plainFormat) Verify(hash, password string) bool { return hash[7:] == password }
+
+func TestRegisterHashFormat(t *testing.T) {
+	if ok, _ := util.VerifyPassword("{PLAIN}password", "password", salt); ok {
+		t.Error("unregistered format should not be verified")
+		return
+	}
+	util.RegisterHashFormat(plainFormat{})
+	if ok, rehash := util.VerifyPassword("{PLAIN}password", "password", salt); !ok || !rehash {
+		t.Errorf("(%t, %t) != (true, true)", ok, rehash)
+	}
+}
@@ -3,6 +3,10 @@ package util
 import (
 	"crypto/sha512"
 	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
 	"unicode"
 
 	"github.com/lestrrat-go/bufferpool"
@@ -40,3 +44,101 @@ func IsDigit(s string) bool {
 	}
 	return true
 }
+
+// NormalizePhoneNumber normalizes given phone number into E.164 format.
+// Separators (spaces, hyphens, dots and parentheses) are removed and
+// international call prefix "00" is replaced with "+".
+func NormalizePhoneNumber(s string) (string, error) {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, s)
+	if strings.HasPrefix(s, "00") {
+		s = "+" + s[2:]
+	}
+	if !strings.HasPrefix(s, "+") {
+		return "", errors.New("phone number must start with country code")
+	}
+	digits := s[1:]
+	switch {
+	case len(digits) < 8 || 15 < len(digits):
+		return "", errors.New("length of phone number is not valid")
+	case !IsDigit(digits):
+		return "", errors.New("phone number must be digit")
+	case digits[0] == '0':
+		return "", errors.New("country code must not start with 0")
+	}
+	return s, nil
+}
+
+// NegotiateContentType returns the best content type in offers for
+// given Accept header value. Offers are preferred in given order when
+// they have the same quality. Empty string is returned if none of
+// offers is acceptable. If accept is empty, the first offer is returned.
+func NegotiateContentType(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+	type candidate struct {
+		offer string
+		q     float64
+		index int
+	}
+	var candidates []candidate
+	for i, offer := range offers {
+		q := acceptQuality(accept, offer)
+		if q > 0 {
+			candidates = append(candidates, candidate{offer, q, i})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].offer
+}
+
+// acceptQuality returns the quality of the offer in Accept header value.
+// The most specific media range matching the offer is used.
+func acceptQuality(accept, offer string) float64 {
+	offerType := strings.SplitN(offer, "/", 2)[0]
+	var q float64
+	specificity := -1
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		var s int
+		switch mediaType {
+		case offer:
+			s = 2
+		case offerType + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s < specificity {
+			continue
+		}
+		specificity = s
+		q = 1
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && kv[0] == "q" {
+				if v, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					q = v
+				}
+			}
+		}
+	}
+	return q
+}
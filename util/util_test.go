@@ -44,3 +44,56 @@ func TestIsDigit(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	candidates := []struct {
+		in       string
+		expected string
+		hasErr   bool
+	}{
+		{"+819012345678", "+819012345678", false},
+		{"+81 90-1234-5678", "+819012345678", false},
+		{"+1 (415) 555.2671", "+14155552671", false},
+		{"0081 90 1234 5678", "+819012345678", false},
+		{"090-1234-5678", "", true},
+		{"+0123456789", "", true},
+		{"+81abc12345678", "", true},
+		{"+1234567", "", true},
+		{"+1234567890123456", "", true},
+		{"", "", true},
+	}
+	for _, c := range candidates {
+		out, err := util.NormalizePhoneNumber(c.in)
+		if c.hasErr != (err != nil) {
+			t.Errorf("unexpected error state: %v (input: %s)", err, c.in)
+			continue
+		}
+		if out != c.expected {
+			t.Errorf("%s != %s (input: %s)", out, c.expected, c.in)
+		}
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{"image/png", "image/svg+xml", "application/json"}
+	candidates := []struct {
+		accept   string
+		expected string
+	}{
+		{"", "image/png"},
+		{"*/*", "image/png"},
+		{"application/json", "application/json"},
+		{"image/*", "image/png"},
+		{"image/svg+xml, image/*;q=0.5", "image/svg+xml"},
+		{"image/png;q=0.5, application/json", "application/json"},
+		{"text/html, */*;q=0.1", "image/png"},
+		{"image/*, image/png;q=0", "image/svg+xml"},
+		{"text/html", ""},
+	}
+	for _, c := range candidates {
+		out := util.NegotiateContentType(c.accept, offers)
+		if out != c.expected {
+			t.Errorf("%s != %s (input: %s)", out, c.expected, c.accept)
+		}
+	}
+}